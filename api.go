@@ -0,0 +1,973 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Top-Ranger/auth/data"
+	"github.com/Top-Ranger/discussiongo/authtoken"
+	"github.com/Top-Ranger/discussiongo/database"
+	"github.com/Top-Ranger/discussiongo/files"
+)
+
+// apiPrefix is the path of the current version of the API.
+const apiPrefix = "/api/v1"
+
+// apiMaxBody is the maximal size of a JSON request body.
+const apiMaxBody = 1 << 20
+
+// apiTokenHeader is the header containing the token for changing requests authenticated by the session cookie.
+// It can be obtained through GET /api/v1/me.
+// Requests authenticated by a personal token don't need it.
+const apiTokenHeader = "X-Token"
+
+type apiErrorResponse struct {
+	Error string `json:"error"`
+}
+
+type apiTopic struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Creator      string    `json:"creator"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Closed       bool      `json:"closed"`
+	Pinned       bool      `json:"pinned"`
+	Category     string    `json:"category"`
+}
+
+type apiCategory struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Position    int    `json:"position"`
+	AdminOnly   bool   `json:"adminOnly"`
+}
+
+type apiPost struct {
+	ID      string    `json:"id"`
+	Topic   string    `json:"topic"`
+	Poster  string    `json:"poster"`
+	Content string    `json:"content"`
+	Date    time.Time `json:"date"`
+}
+
+type apiFile struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	User        string    `json:"user"`
+	Topic       string    `json:"topic"`
+	Date        time.Time `json:"date"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"contentType,omitempty"`
+}
+
+type apiProfile struct {
+	Name    string     `json:"name"`
+	Comment string     `json:"comment"`
+	Topics  []apiTopic `json:"topics"`
+	Posts   []apiPost  `json:"posts"`
+	Files   []apiFile  `json:"files"`
+}
+
+type apiMe struct {
+	Name   string   `json:"name"`
+	Admin  bool     `json:"admin"`
+	Token  string   `json:"token,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+type apiNewTopic struct {
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+}
+
+type apiNewPost struct {
+	Content string `json:"content"`
+}
+
+type apiSetClosed struct {
+	Closed *bool `json:"closed"`
+}
+
+type apiSetPinned struct {
+	Pinned *bool `json:"pinned"`
+}
+
+type apiSetCategory struct {
+	Category *string `json:"category"`
+}
+
+// apiSession contains the authentication state of an API request.
+// If the request was authenticated by a personal token, IsAdmin is only true if the token has the admin scope.
+type apiSession struct {
+	LoggedIn      bool
+	User          string
+	IsAdmin       bool
+	PersonalToken *authtoken.PersonalToken
+}
+
+func init() {
+	http.HandleFunc(apiPrefix+"/", apiNotFoundHandleFunc)
+	http.HandleFunc(apiPrefix+"/me", apiMeHandleFunc)
+	http.HandleFunc(apiPrefix+"/topics", apiTopicsHandleFunc)
+	http.HandleFunc(apiPrefix+"/topics/{id}", apiTopicHandleFunc)
+	http.HandleFunc(apiPrefix+"/topics/{id}/close", apiCloseTopicHandleFunc)
+	http.HandleFunc(apiPrefix+"/topics/{id}/pin", apiPinTopicHandleFunc)
+	http.HandleFunc(apiPrefix+"/topics/{id}/rename", apiRenameTopicHandleFunc)
+	http.HandleFunc(apiPrefix+"/topics/{id}/move", apiMoveTopicHandleFunc)
+	http.HandleFunc(apiPrefix+"/topics/{id}/posts", apiTopicPostsHandleFunc)
+	http.HandleFunc(apiPrefix+"/topics/{id}/files", apiTopicFilesHandleFunc)
+	http.HandleFunc(apiPrefix+"/categories", apiCategoriesHandleFunc)
+	http.HandleFunc(apiPrefix+"/posts/{id}", apiPostHandleFunc)
+	http.HandleFunc(apiPrefix+"/files/{id}", apiFileHandleFunc)
+	http.HandleFunc(apiPrefix+"/files/{id}/content", apiFileContentHandleFunc)
+	http.HandleFunc(apiPrefix+"/users/{name}", apiUserHandleFunc)
+}
+
+// apiWrite writes v as JSON response with the given status code.
+func apiWrite(rw http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Println("api: can not marshal response:", err)
+		status = http.StatusInternalServerError
+		b = []byte(`{"error":"can not create response"}`)
+	}
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	rw.WriteHeader(status)
+	rw.Write(b)
+}
+
+// apiError writes a JSON error body with the given status code.
+func apiError(rw http.ResponseWriter, status int, message string) {
+	apiWrite(rw, status, apiErrorResponse{Error: message})
+}
+
+// apiMethodNotAllowed writes an error for a request with an unsupported method.
+func apiMethodNotAllowed(rw http.ResponseWriter, allowed ...string) {
+	rw.Header().Set("Allow", strings.Join(allowed, ", "))
+	apiError(rw, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// apiAuthenticate returns the authentication state of the request.
+// scope is the scope of personal tokens needed for the request (one of the authtoken.Scope* constants).
+// Requests authenticated by the session cookie need a valid token in the X-Token header for all scopes except authtoken.ScopeRead.
+// Reading is possible without authentication if the forum can be read without registration.
+// If false is returned, an error was already written.
+func apiAuthenticate(rw http.ResponseWriter, r *http.Request, scope string) (apiSession, bool) {
+	var s apiSession
+
+	if bearerToken(r) != "" {
+		ok, user, p := TestPersonalToken(r)
+		if !ok {
+			rw.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			apiError(rw, http.StatusUnauthorized, "invalid personal token")
+			return s, false
+		}
+		if !p.HasScope(scope) {
+			apiError(rw, http.StatusForbidden, fmt.Sprintf("personal token lacks scope '%s'", scope))
+			return s, false
+		}
+
+		isAdmin, err := database.IsAdmin(user)
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return s, false
+		}
+		s = apiSession{LoggedIn: true, User: user, IsAdmin: isAdmin && p.HasScope(authtoken.ScopeAdmin), PersonalToken: &p}
+		return s, true
+	}
+
+	loggedIn, user := TestUser(r, rw)
+	s = apiSession{LoggedIn: loggedIn, User: user}
+	write := scope != authtoken.ScopeRead
+
+	if write && !loggedIn {
+		apiError(rw, http.StatusUnauthorized, "authentication required")
+		return s, false
+	}
+	if !canRead(loggedIn) {
+		apiError(rw, http.StatusUnauthorized, "authentication required")
+		return s, false
+	}
+
+	if write {
+		token := r.Header.Get(apiTokenHeader)
+		if token == "" || !data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration) {
+			apiError(rw, http.StatusForbidden, "invalid token")
+			return s, false
+		}
+	}
+
+	if loggedIn {
+		var err error
+		s.IsAdmin, err = database.IsAdmin(user)
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return s, false
+		}
+	}
+	return s, true
+}
+
+// apiDecode decodes the JSON body of a request into v.
+// If false is returned, an error was already written.
+func apiDecode(rw http.ResponseWriter, r *http.Request, v interface{}) bool {
+	d := json.NewDecoder(http.MaxBytesReader(rw, r.Body, apiMaxBody))
+	d.DisallowUnknownFields()
+	err := d.Decode(v)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			apiError(rw, http.StatusRequestEntityTooLarge, "request body too large")
+			return false
+		}
+		apiError(rw, http.StatusBadRequest, fmt.Sprintf("invalid request body: %s", err.Error()))
+		return false
+	}
+	return true
+}
+
+// apiGetTopic returns the topic given in the path of the request.
+// If false is returned, an error was already written.
+func apiGetTopic(rw http.ResponseWriter, r *http.Request) (database.Topic, bool) {
+	topic, err := database.GetTopic(r.PathValue("id"))
+	if err != nil {
+		apiError(rw, http.StatusNotFound, "topic not found")
+		return topic, false
+	}
+	return topic, true
+}
+
+func newAPITopic(t database.Topic) apiTopic {
+	return apiTopic{
+		ID:           t.ID,
+		Name:         t.Name,
+		Creator:      t.Creator,
+		Created:      t.Created,
+		LastModified: t.LastModified,
+		Closed:       t.Closed,
+		Pinned:       t.Pinned,
+		Category:     t.Category,
+	}
+}
+
+func newAPICategory(c database.Category) apiCategory {
+	return apiCategory{
+		ID:          c.ID,
+		Name:        c.Name,
+		Description: c.Description,
+		Position:    c.Position,
+		AdminOnly:   c.AdminOnly,
+	}
+}
+
+func newAPIPost(p database.Post) apiPost {
+	return apiPost{
+		ID:      p.ID,
+		Topic:   p.TopicID,
+		Poster:  p.Poster,
+		Content: p.Content,
+		Date:    p.Time,
+	}
+}
+
+func newAPIFile(f files.File) apiFile {
+	return apiFile{
+		ID:          f.ID,
+		Name:        f.Name,
+		User:        f.User,
+		Topic:       f.Topic,
+		Date:        f.Date,
+		Size:        f.Length,
+		ContentType: f.ContentType,
+	}
+}
+
+func apiNotFoundHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	apiError(rw, http.StatusNotFound, "not found")
+}
+
+func apiMeHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiMethodNotAllowed(rw, http.MethodGet)
+		return
+	}
+
+	s, ok := apiAuthenticate(rw, r, authtoken.ScopeRead)
+	if !ok {
+		return
+	}
+	if !s.LoggedIn {
+		apiError(rw, http.StatusUnauthorized, "authentication required")
+		return
+	}
+
+	me := apiMe{Name: s.User, Admin: s.IsAdmin}
+	if s.PersonalToken != nil {
+		me.Scopes = s.PersonalToken.Scopes
+	} else {
+		token, err := data.GetStringsTimed(time.Now(), fmt.Sprintf("%s;Token", s.User))
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+		me.Token = token
+	}
+
+	apiWrite(rw, http.StatusOK, me)
+}
+
+func apiTopicsHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		_, ok := apiAuthenticate(rw, r, authtoken.ScopeRead)
+		if !ok {
+			return
+		}
+
+		topics, err := database.GetTopics()
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		result := make([]apiTopic, len(topics))
+		for i := range topics {
+			result[i] = newAPITopic(topics[i])
+		}
+		apiWrite(rw, http.StatusOK, result)
+	case http.MethodPost:
+		s, ok := apiAuthenticate(rw, r, authtoken.ScopePost)
+		if !ok {
+			return
+		}
+
+		var nt apiNewTopic
+		if !apiDecode(rw, r, &nt) {
+			return
+		}
+		if len(strings.TrimSpace(nt.Name)) == 0 {
+			apiError(rw, http.StatusBadRequest, "name must not be empty")
+			return
+		}
+		if nt.Category == "" {
+			nt.Category = database.NoCategory
+		}
+
+		category, err := database.GetCategory(nt.Category)
+		if err != nil {
+			apiError(rw, http.StatusBadRequest, "category not found")
+			return
+		}
+
+		if !canCreateTopic(s.LoggedIn, s.IsAdmin, category) {
+			apiError(rw, http.StatusForbidden, "category is restricted to administrators")
+			return
+		}
+
+		id, err := createTopic(s.User, nt.Name, category.ID)
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		topic, err := database.GetTopic(id)
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		rw.Header().Set("Location", fmt.Sprintf("%s%s/topics/%s", config.ServerPath, apiPrefix, id))
+		apiWrite(rw, http.StatusCreated, newAPITopic(topic))
+	default:
+		apiMethodNotAllowed(rw, http.MethodGet, http.MethodPost)
+	}
+}
+
+func apiTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiMethodNotAllowed(rw, http.MethodGet)
+		return
+	}
+
+	_, ok := apiAuthenticate(rw, r, authtoken.ScopeRead)
+	if !ok {
+		return
+	}
+
+	topic, ok := apiGetTopic(rw, r)
+	if !ok {
+		return
+	}
+
+	apiWrite(rw, http.StatusOK, newAPITopic(topic))
+}
+
+func apiCloseTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiMethodNotAllowed(rw, http.MethodPost)
+		return
+	}
+
+	s, ok := apiAuthenticate(rw, r, authtoken.ScopePost)
+	if !ok {
+		return
+	}
+
+	topic, ok := apiGetTopic(rw, r)
+	if !ok {
+		return
+	}
+
+	var sc apiSetClosed
+	if !apiDecode(rw, r, &sc) {
+		return
+	}
+	if sc.Closed == nil {
+		apiError(rw, http.StatusBadRequest, "closed must be set")
+		return
+	}
+
+	if !canCloseTopic(s.User, s.LoggedIn, s.IsAdmin, topic) {
+		apiError(rw, http.StatusForbidden, "not allowed to close or open this topic")
+		return
+	}
+
+	err := setTopicClosed(s.User, topic.ID, *sc.Closed)
+	if err != nil {
+		apiError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	topic.Closed = *sc.Closed
+	apiWrite(rw, http.StatusOK, newAPITopic(topic))
+}
+
+func apiPinTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiMethodNotAllowed(rw, http.MethodPost)
+		return
+	}
+
+	s, ok := apiAuthenticate(rw, r, authtoken.ScopeAdmin)
+	if !ok {
+		return
+	}
+
+	topic, ok := apiGetTopic(rw, r)
+	if !ok {
+		return
+	}
+
+	var sp apiSetPinned
+	if !apiDecode(rw, r, &sp) {
+		return
+	}
+	if sp.Pinned == nil {
+		apiError(rw, http.StatusBadRequest, "pinned must be set")
+		return
+	}
+
+	if !canPinTopic(s.IsAdmin) {
+		apiError(rw, http.StatusForbidden, "not allowed to pin topics")
+		return
+	}
+
+	err := setTopicPinned(s.User, topic.ID, *sp.Pinned)
+	if err != nil {
+		apiError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	topic.Pinned = *sp.Pinned
+	apiWrite(rw, http.StatusOK, newAPITopic(topic))
+}
+
+func apiMoveTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiMethodNotAllowed(rw, http.MethodPost)
+		return
+	}
+
+	s, ok := apiAuthenticate(rw, r, authtoken.ScopeAdmin)
+	if !ok {
+		return
+	}
+
+	topic, ok := apiGetTopic(rw, r)
+	if !ok {
+		return
+	}
+
+	var sc apiSetCategory
+	if !apiDecode(rw, r, &sc) {
+		return
+	}
+	if sc.Category == nil {
+		apiError(rw, http.StatusBadRequest, "category must be set")
+		return
+	}
+
+	if !canMoveTopic(s.IsAdmin) {
+		apiError(rw, http.StatusForbidden, "not allowed to move topics")
+		return
+	}
+
+	category, err := database.GetCategory(*sc.Category)
+	if err != nil {
+		apiError(rw, http.StatusBadRequest, "category not found")
+		return
+	}
+
+	if category.ID != topic.Category {
+		err = moveTopic(s.User, topic, category)
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	topic.Category = category.ID
+	apiWrite(rw, http.StatusOK, newAPITopic(topic))
+}
+
+func apiRenameTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiMethodNotAllowed(rw, http.MethodPost)
+		return
+	}
+
+	s, ok := apiAuthenticate(rw, r, authtoken.ScopePost)
+	if !ok {
+		return
+	}
+
+	topic, ok := apiGetTopic(rw, r)
+	if !ok {
+		return
+	}
+
+	var nt apiNewTopic
+	if !apiDecode(rw, r, &nt) {
+		return
+	}
+	if len(strings.TrimSpace(nt.Name)) == 0 {
+		apiError(rw, http.StatusBadRequest, "name must not be empty")
+		return
+	}
+
+	if !canRenameTopic(s.User, s.LoggedIn, s.IsAdmin, topic) {
+		apiError(rw, http.StatusForbidden, "not allowed to rename this topic")
+		return
+	}
+
+	err := renameTopic(s.User, topic, nt.Name)
+	if err != nil {
+		apiError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	topic.Name = nt.Name
+	apiWrite(rw, http.StatusOK, newAPITopic(topic))
+}
+
+func apiTopicPostsHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		_, ok := apiAuthenticate(rw, r, authtoken.ScopeRead)
+		if !ok {
+			return
+		}
+
+		topic, ok := apiGetTopic(rw, r)
+		if !ok {
+			return
+		}
+
+		posts, err := database.GetPosts(topic.ID)
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		result := make([]apiPost, len(posts))
+		for i := range posts {
+			result[i] = newAPIPost(posts[i])
+		}
+		apiWrite(rw, http.StatusOK, result)
+	case http.MethodPost:
+		s, ok := apiAuthenticate(rw, r, authtoken.ScopePost)
+		if !ok {
+			return
+		}
+
+		topic, ok := apiGetTopic(rw, r)
+		if !ok {
+			return
+		}
+
+		var np apiNewPost
+		if !apiDecode(rw, r, &np) {
+			return
+		}
+		if len(strings.TrimSpace(np.Content)) == 0 {
+			apiError(rw, http.StatusBadRequest, "content must not be empty")
+			return
+		}
+
+		if topic.Closed {
+			apiError(rw, http.StatusForbidden, "topic is closed")
+			return
+		}
+
+		category, err := database.GetCategory(topic.Category)
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if !canPost(s.LoggedIn, s.IsAdmin, topic, category) {
+			apiError(rw, http.StatusForbidden, "category is restricted to administrators")
+			return
+		}
+
+		postID, err := createPost(s.User, topic.ID, np.Content)
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		post, err := database.GetSinglePost(postID)
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		rw.Header().Set("Location", fmt.Sprintf("%s%s/posts/%s", config.ServerPath, apiPrefix, postID))
+		apiWrite(rw, http.StatusCreated, newAPIPost(post))
+	default:
+		apiMethodNotAllowed(rw, http.MethodGet, http.MethodPost)
+	}
+}
+
+func apiCategoriesHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiMethodNotAllowed(rw, http.MethodGet)
+		return
+	}
+
+	_, ok := apiAuthenticate(rw, r, authtoken.ScopeRead)
+	if !ok {
+		return
+	}
+
+	categories, err := database.GetCategories()
+	if err != nil {
+		apiError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result := make([]apiCategory, len(categories))
+	for i := range categories {
+		result[i] = newAPICategory(categories[i])
+	}
+	apiWrite(rw, http.StatusOK, result)
+}
+
+func apiPostHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		_, ok := apiAuthenticate(rw, r, authtoken.ScopeRead)
+		if !ok {
+			return
+		}
+
+		post, err := database.GetSinglePost(r.PathValue("id"))
+		if err != nil {
+			apiError(rw, http.StatusNotFound, "post not found")
+			return
+		}
+
+		apiWrite(rw, http.StatusOK, newAPIPost(post))
+	case http.MethodDelete:
+		s, ok := apiAuthenticate(rw, r, authtoken.ScopePost)
+		if !ok {
+			return
+		}
+
+		post, err := database.GetSinglePost(r.PathValue("id"))
+		if err != nil {
+			apiError(rw, http.StatusNotFound, "post not found")
+			return
+		}
+
+		if !canDeletePost(s.User, s.LoggedIn, s.IsAdmin, post) {
+			apiError(rw, http.StatusForbidden, "not allowed to delete this post")
+			return
+		}
+
+		err = deletePost(s.User, post)
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		apiMethodNotAllowed(rw, http.MethodGet, http.MethodDelete)
+	}
+}
+
+func apiTopicFilesHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		_, ok := apiAuthenticate(rw, r, authtoken.ScopeRead)
+		if !ok {
+			return
+		}
+
+		topic, ok := apiGetTopic(rw, r)
+		if !ok {
+			return
+		}
+
+		fs, err := files.GetFileMetadataOfTopic(topic.ID)
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		result := make([]apiFile, len(fs))
+		for i := range fs {
+			result[i] = newAPIFile(fs[i])
+		}
+		apiWrite(rw, http.StatusOK, result)
+	case http.MethodPost:
+		s, ok := apiAuthenticate(rw, r, authtoken.ScopeUpload)
+		if !ok {
+			return
+		}
+
+		if !canUploadFiles(s.LoggedIn, s.IsAdmin) {
+			apiError(rw, http.StatusForbidden, "not allowed to upload files")
+			return
+		}
+
+		topic, ok := apiGetTopic(rw, r)
+		if !ok {
+			return
+		}
+		if topic.Closed {
+			apiError(rw, http.StatusForbidden, "topic is closed")
+			return
+		}
+
+		category, err := database.GetCategory(topic.Category)
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if !canPost(s.LoggedIn, s.IsAdmin, topic, category) {
+			apiError(rw, http.StatusForbidden, "category is restricted to administrators")
+			return
+		}
+
+		maxSize := int64(config.FileMaxMB) * 1000000
+		// Leave some room for the multipart overhead, the size of the file itself is checked below
+		r.Body = http.MaxBytesReader(rw, r.Body, maxSize+apiMaxBody)
+		err = r.ParseMultipartForm(uploadMemoryLimit)
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				apiError(rw, http.StatusRequestEntityTooLarge, "file too large")
+				return
+			}
+			apiError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		fileReader, meta, err := r.FormFile("file")
+		if err != nil {
+			apiError(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+		defer fileReader.Close()
+
+		if meta.Size > maxSize {
+			apiError(rw, http.StatusRequestEntityTooLarge, "file too large")
+			return
+		}
+
+		fileID, err := saveFile(s.User, topic.ID, meta.Filename, fileReader, meta.Size)
+		switch {
+		case errors.Is(err, errFileTypeNotAllowed):
+			apiError(rw, http.StatusUnsupportedMediaType, "file type not allowed")
+			return
+		case errors.Is(err, errUserQuotaExceeded), errors.Is(err, errTopicQuotaExceeded):
+			apiError(rw, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		case errors.Is(err, errFileInfected):
+			apiError(rw, http.StatusUnprocessableEntity, errFileInfected.Error())
+			return
+		case errors.Is(err, errFileScanFailed):
+			apiError(rw, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		f, err := files.GetFileMetadata(fileID)
+		if err != nil {
+			apiError(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		rw.Header().Set("Location", fmt.Sprintf("%s%s/files/%s", config.ServerPath, apiPrefix, fileID))
+		apiWrite(rw, http.StatusCreated, newAPIFile(f))
+	default:
+		apiMethodNotAllowed(rw, http.MethodGet, http.MethodPost)
+	}
+}
+
+func apiFileHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiMethodNotAllowed(rw, http.MethodGet)
+		return
+	}
+
+	s, ok := apiAuthenticate(rw, r, authtoken.ScopeRead)
+	if !ok {
+		return
+	}
+
+	f, err := files.GetFileMetadata(r.PathValue("id"))
+	if err != nil {
+		apiError(rw, http.StatusNotFound, "file not found")
+		return
+	}
+
+	access, err := canAccessFile(s.User, s.LoggedIn, f)
+	if err != nil {
+		apiError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !access {
+		apiError(rw, http.StatusNotFound, "file not found")
+		return
+	}
+
+	apiWrite(rw, http.StatusOK, newAPIFile(f))
+}
+
+func apiFileContentHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiMethodNotAllowed(rw, http.MethodGet)
+		return
+	}
+
+	s, ok := apiAuthenticate(rw, r, authtoken.ScopeRead)
+	if !ok {
+		return
+	}
+
+	// Test if file exists to distinguish errors
+	meta, err := files.GetFileMetadata(r.PathValue("id"))
+	if err != nil {
+		apiError(rw, http.StatusNotFound, "file not found")
+		return
+	}
+
+	access, err := canAccessFile(s.User, s.LoggedIn, meta)
+	if err != nil {
+		apiError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !access {
+		apiError(rw, http.StatusNotFound, "file not found")
+		return
+	}
+
+	writeFile(rw, r, meta)
+}
+
+func apiUserHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiMethodNotAllowed(rw, http.MethodGet)
+		return
+	}
+
+	_, ok := apiAuthenticate(rw, r, authtoken.ScopeRead)
+	if !ok {
+		return
+	}
+
+	u, err := database.GetUser(r.PathValue("name"))
+	if err != nil {
+		apiError(rw, http.StatusNotFound, "user not found")
+		return
+	}
+
+	topics, err := database.GetTopicsByUser(u.Name)
+	if err != nil {
+		apiError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	posts, err := database.GetPostsByUser(u.Name)
+	if err != nil {
+		apiError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	fs, err := files.GetFileMetadataForUser(u.Name)
+	if err != nil {
+		apiError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	fs = withoutConversationFiles(fs)
+
+	p := apiProfile{
+		Name:    u.Name,
+		Comment: u.Comment,
+		Topics:  make([]apiTopic, len(topics)),
+		Posts:   make([]apiPost, len(posts)),
+		Files:   make([]apiFile, len(fs)),
+	}
+	for i := range topics {
+		p.Topics[i] = newAPITopic(topics[i])
+	}
+	for i := range posts {
+		p.Posts[i] = newAPIPost(posts[i])
+	}
+	for i := range fs {
+		p.Files[i] = newAPIFile(fs[i])
+	}
+
+	apiWrite(rw, http.StatusOK, p)
+}
@@ -0,0 +1,295 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Top-Ranger/discussiongo/database"
+	"github.com/Top-Ranger/discussiongo/files"
+)
+
+var transcriptTemplate *template.Template
+
+type transcriptTemplateData struct {
+	ServerPath   string
+	ServerPrefix string
+	ForumName    string
+	Topic        string
+	TopicID      string
+	Posts        []transcriptPost
+	Files        []transcriptFile
+	Translation  Translation
+}
+
+type transcriptPost struct {
+	ID      string
+	Poster  string
+	Date    string
+	Content template.HTML
+}
+
+type transcriptFile struct {
+	Path string
+	Name string
+	User string
+	Date string
+	Size string
+}
+
+func init() {
+	var err error
+
+	transcriptTemplate, err = template.ParseFS(templateFiles, "template/transcript.html")
+	if err != nil {
+		panic(err)
+	}
+
+	http.HandleFunc("/downloadTopic.html", downloadTopicHandleFunc)
+}
+
+// downloadTopicHandleFunc sends all files of a topic as ZIP archive.
+// If the query contains 'transcript=markdown' or 'transcript=html', the posts are included as well.
+// The files are streamed one after another from the storage, so that they are never kept in memory together.
+func downloadTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	loggedIn, _ := TestUser(r, rw)
+
+	if !canRead(loggedIn) {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	q := r.URL.Query()
+	id := q.Get("id")
+	if id == "" {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	if _, ok := conversationOfFile(id); ok {
+		// Files of conversations are private
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	transcript := q.Get("transcript")
+	switch transcript {
+	case "", "markdown", "html":
+	default:
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(GetDefaultTranslation().InvalidRequest))
+		return
+	}
+
+	topic, err := database.GetTopic(id)
+	if err != nil {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	fs, err := files.GetFileMetadataOfTopic(id)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	paths := archiveFilePaths(fs)
+
+	// The transcript is created before the download starts so that errors can still be reported
+	var transcriptName string
+	var transcriptContent []byte
+	if transcript != "" {
+		posts, err := database.GetPosts(id)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+
+		if transcript == "markdown" {
+			transcriptName = "transcript.md"
+			transcriptContent = markdownTranscript(topic, posts, fs, paths)
+		} else {
+			transcriptName = "transcript.html"
+			transcriptContent, err = htmlTranscript(topic, posts, fs, paths)
+			if err != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+				rw.Write([]byte(err.Error()))
+				return
+			}
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/zip")
+	rw.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fmt.Sprintf("topic-%s.zip", topic.ID)}))
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// From here on, errors can only be logged as the response has already started
+	zw := zip.NewWriter(rw)
+
+	if transcriptName != "" {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: transcriptName, Method: zip.Deflate, Modified: time.Now()})
+		if err == nil {
+			_, err = w.Write(transcriptContent)
+		}
+		if err != nil {
+			log.Printf("Can not write transcript of topic %s: %s", topic.ID, err.Error())
+			return
+		}
+	}
+
+	for i := range fs {
+		err = writeArchiveFile(zw, fs[i], paths[fs[i].ID])
+		if err != nil {
+			// The archive is left incomplete so that the client notices the error
+			log.Printf("Can not write file %s to archive of topic %s: %s", fs[i].ID, topic.ID, err.Error())
+			return
+		}
+	}
+
+	err = zw.Close()
+	if err != nil {
+		log.Printf("Can not finish archive of topic %s: %s", topic.ID, err.Error())
+	}
+}
+
+// writeArchiveFile copies the content of a file from the storage into the archive.
+func writeArchiveFile(zw *zip.Writer, f files.File, path string) error {
+	content, err := files.OpenFile(f)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	method := zip.Deflate
+	if strings.HasPrefix(mediaType(f.ContentType), "image/") || mediaType(f.ContentType) == "application/zip" {
+		// Already compressed
+		method = zip.Store
+	}
+
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: path, Method: method, Modified: f.Date})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, content)
+	return err
+}
+
+// archiveFilePaths returns the paths of the files inside the archive by their ID.
+// The ID is part of the path, so files with the same name do not overwrite each other.
+func archiveFilePaths(fs []files.File) map[string]string {
+	paths := make(map[string]string, len(fs))
+	for i := range fs {
+		paths[fs[i].ID] = fmt.Sprintf("files/%s_%s", fs[i].ID, archiveFileName(fs[i].Name))
+	}
+	return paths
+}
+
+// archiveFileName returns a version of name which can be safely used as file name inside an archive.
+func archiveFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		return "file"
+	}
+	return name
+}
+
+// markdownTranscript returns the posts of a topic as Markdown document.
+func markdownTranscript(topic database.Topic, posts []database.Post, fs []files.File, paths map[string]string) []byte {
+	t := GetDefaultTranslation()
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "# %s\n\n", topic.Name)
+	fmt.Fprintf(&buf, "%s%s/topic.html?id=%s\n\n", config.ServerPrefix, config.ServerPath, topic.ID)
+
+	fmt.Fprintf(&buf, "## %s\n\n", t.Posts)
+	for i := range posts {
+		fmt.Fprintf(&buf, "**%s** - %s\n\n", posts[i].Poster, posts[i].Time.Format(time.RFC822))
+		buf.WriteString(strings.TrimSpace(posts[i].Content))
+		buf.WriteString("\n\n---\n\n")
+	}
+
+	fmt.Fprintf(&buf, "## %s\n\n", t.Files)
+	for i := range fs {
+		fmt.Fprintf(&buf, "- [%s](<%s>) (%s - %s - %s)\n", fs[i].Name, paths[fs[i].ID], fileLengthToString(int(fs[i].Length)), fs[i].User, fs[i].Date.Format(time.RFC822))
+	}
+	return buf.Bytes()
+}
+
+// htmlTranscript returns the posts of a topic as HTML document.
+// Images of the topic shown in posts are replaced by the copies inside the archive.
+func htmlTranscript(topic database.Topic, posts []database.Post, fs []files.File, paths map[string]string) ([]byte, error) {
+	td := transcriptTemplateData{
+		ServerPath:   config.ServerPath,
+		ServerPrefix: config.ServerPrefix,
+		ForumName:    config.ForumName,
+		Topic:        topic.Name,
+		TopicID:      topic.ID,
+		Posts:        make([]transcriptPost, 0, len(posts)),
+		Files:        make([]transcriptFile, 0, len(fs)),
+		Translation:  GetDefaultTranslation(),
+	}
+
+	replace := make([]string, 0, 2*len(fs))
+	for i := range fs {
+		replace = append(replace, fmt.Sprintf("src=\"%s/getFile.html?id=%s\"", html.EscapeString(config.ServerPath), fs[i].ID), fmt.Sprintf("src=\"%s\"", html.EscapeString(paths[fs[i].ID])))
+		td.Files = append(td.Files, transcriptFile{
+			Path: paths[fs[i].ID],
+			Name: fs[i].Name,
+			User: fs[i].User,
+			Date: fs[i].Date.Format(time.RFC822),
+			Size: fileLengthToString(int(fs[i].Length)),
+		})
+	}
+	images := strings.NewReplacer(replace...)
+
+	tf := newTopicFiles(fs)
+	for i := range posts {
+		td.Posts = append(td.Posts, transcriptPost{
+			ID:      posts[i].ID,
+			Poster:  posts[i].Poster,
+			Date:    posts[i].Time.Format(time.RFC822),
+			Content: template.HTML(images.Replace(string(formatTopicPost(posts[i].Content, tf)))),
+		})
+	}
+
+	var buf bytes.Buffer
+	err := transcriptTemplate.Execute(&buf, td)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
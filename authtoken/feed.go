@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authtoken
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// FeedToken represents the secret token of a user which allows feed readers to access the feeds.
+// Each user has at most one feed token. It only grants read access.
+// Like Authtoken, only a keyed hash of the token is stored in the database.
+// Therefore, ID contains the usable token only if returned by NewFeedToken and the hash otherwise.
+type FeedToken struct {
+	ID       string
+	User     string
+	Created  time.Time
+	LastUsed time.Time
+}
+
+// NewFeedToken creates a new feed token for the user and returns it.
+// An existing feed token of the user is replaced.
+func NewFeedToken(user string) (FeedToken, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return FeedToken{}, err
+	}
+	token := base32.StdEncoding.EncodeToString(b)
+	now := time.Now()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return FeedToken{}, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM feedtoken WHERE user=?", user)
+	if err != nil {
+		return FeedToken{}, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	_, err = tx.Exec("INSERT INTO feedtoken (id, user, created, lastUsed) VALUES (?, ?, ?, ?)", hashToken(token), user, now.Unix(), 0)
+	if err != nil {
+		return FeedToken{}, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return FeedToken{}, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	return FeedToken{
+		ID:      token,
+		User:    user,
+		Created: now,
+	}, nil
+}
+
+// CheckFeedToken checks whether a feed token is valid and returns it.
+// The ID of the returned token is the hash.
+// The last usage of valid tokens is updated.
+func CheckFeedToken(token string) (FeedToken, bool) {
+	if token == "" {
+		return FeedToken{}, false
+	}
+
+	id := hashToken(token)
+	rows, err := db.Query("SELECT id,user,created,lastUsed FROM feedtoken WHERE id=?", id)
+	if err != nil {
+		log.Println("authtoken: error while validating feed token:", err)
+		return FeedToken{}, false
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return FeedToken{}, false
+	}
+
+	f, err := scanFeedToken(rows.Scan)
+	if err != nil {
+		log.Println("authtoken: error while validating feed token:", err)
+		return FeedToken{}, false
+	}
+	rows.Close()
+
+	now := time.Now()
+	if now.Sub(f.LastUsed) >= lastUsedResolution {
+		_, err = db.Exec("UPDATE feedtoken SET lastUsed=? WHERE id=?", now.Unix(), id)
+		if err != nil {
+			log.Println("authtoken: can not update last usage:", err)
+		}
+		f.LastUsed = now
+	}
+	return f, true
+}
+
+// GetFeedTokenOfUser returns the feed token of a user.
+// The bool is false if the user has no feed token.
+// The ID of the returned token is the hash, which can not be used to access the feeds.
+func GetFeedTokenOfUser(user string) (FeedToken, bool, error) {
+	rows, err := db.Query("SELECT id,user,created,lastUsed FROM feedtoken WHERE user=?", user)
+	if err != nil {
+		return FeedToken{}, false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return FeedToken{}, false, nil
+	}
+
+	f, err := scanFeedToken(rows.Scan)
+	if err != nil {
+		return FeedToken{}, false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return f, true, nil
+}
+
+// DeleteUserFeedToken removes the feed token of a user.
+// It returns the number of deleted tokens.
+func DeleteUserFeedToken(user string) (int64, error) {
+	r, err := db.Exec("DELETE FROM feedtoken WHERE user=?", user)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+	return count, nil
+}
+
+// scanFeedToken reads a feed token from a row containing id,user,created,lastUsed.
+func scanFeedToken(scan func(dest ...interface{}) error) (FeedToken, error) {
+	f := FeedToken{}
+	var created, lastUsed int64
+	err := scan(&f.ID, &f.User, &created, &lastUsed)
+	if err != nil {
+		return f, err
+	}
+	f.Created = time.Unix(created, 0)
+	if lastUsed != 0 {
+		f.LastUsed = time.Unix(lastUsed, 0)
+	}
+	return f, nil
+}
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2024 Marcus Soll
+// Copyright 2024,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -17,7 +17,9 @@ package authtoken
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base32"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
@@ -25,18 +27,41 @@ import (
 	"time"
 )
 
+const (
+	// lastUsedResolution is the minimal time between two updates of the last usage of a token.
+	// This avoids writing to the database on every request.
+	lastUsedResolution = time.Minute
+
+	// maxUserAgentLength is the maximal number of characters of a saved user agent.
+	maxUserAgentLength = 600
+)
+
 var cleanupStarted = sync.Once{}
 
 // Authtoken represents an authtoken for user validation.
+// Only a keyed hash of the token is stored in the database.
+// Therefore, ID contains the usable token only if returned by GetNewToken and the hash otherwise.
+// IP and UserAgent are the values of the client which requested the token.
 type Authtoken struct {
 	ID         string
 	User       string
 	ValidUntil time.Time
+	Created    time.Time
+	LastUsed   time.Time
+	IP         string
+	UserAgent  string
+}
+
+// Session returns an identifier of the token which can be shown to the user.
+// It must only be called on authtoken returned by GetAuthtokenOfUser.
+func (a Authtoken) Session() string {
+	h := sha256.Sum256([]byte(a.ID))
+	return hex.EncodeToString(h[:12])
 }
 
 // DeleteToken removes a single token fom database.
 func DeleteToken(authtoken string) error {
-	_, err := db.Exec("DELETE FROM authtoken WHERE id=?", authtoken)
+	_, err := db.Exec("DELETE FROM authtoken WHERE id=?", hashToken(authtoken))
 	if err != nil {
 		return errors.New(fmt.Sprintln("Database error:", err))
 	}
@@ -58,19 +83,61 @@ func DeleteUserToken(user string) (int64, error) {
 	return count, nil
 }
 
+// DeleteUserTokenExcept removes all authtoken associated by a user except the given one.
+// It returns the number of deleted tokens.
+func DeleteUserTokenExcept(user, authtoken string) (int64, error) {
+	r, err := db.Exec("DELETE FROM authtoken WHERE user=? AND id<>?", user, hashToken(authtoken))
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+	return count, nil
+}
+
+// DeleteSession removes the token of a user identified by Authtoken.Session.
+// It returns whether a token was deleted.
+func DeleteSession(user, session string) (bool, error) {
+	tokens, err := GetAuthtokenOfUser(user)
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	for i := range tokens {
+		if tokens[i].Session() != session {
+			continue
+		}
+		_, err = db.Exec("DELETE FROM authtoken WHERE id=?", tokens[i].ID)
+		if err != nil {
+			return false, errors.New(fmt.Sprintln("Database error:", err))
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
 // GetNewToken inserts an authtoken into the database and returns it.
 // The token will be generated uniquely.
-func GetNewToken(user string, minutesValid int) (Authtoken, error) {
+// ip and userAgent describe the client and are only stored for display.
+func GetNewToken(user string, minutesValid int, ip, userAgent string) (Authtoken, error) {
 	b := make([]byte, 35)
 	_, err := rand.Read(b)
 	if err != nil {
 		return Authtoken{}, err
 	}
-	validUntil := time.Now().Add(time.Duration(minutesValid) * time.Minute)
+	if r := []rune(userAgent); len(r) > maxUserAgentLength {
+		userAgent = string(r[:maxUserAgentLength])
+	}
+
+	now := time.Now()
+	validUntil := now.Add(time.Duration(minutesValid) * time.Minute)
 	authtoken := base32.StdEncoding.EncodeToString(b)
 	intDate := validUntil.Unix()
 
-	_, err = db.Exec("INSERT INTO authtoken (id, user, validUntil) VALUES (?, ?, ?)", authtoken, user, intDate)
+	_, err = db.Exec("INSERT INTO authtoken (id, user, validUntil, created, lastUsed, ip, userAgent) VALUES (?, ?, ?, ?, ?, ?, ?)", hashToken(authtoken), user, intDate, now.Unix(), now.Unix(), ip, userAgent)
 	if err != nil {
 		return Authtoken{}, err
 	}
@@ -79,14 +146,32 @@ func GetNewToken(user string, minutesValid int) (Authtoken, error) {
 		ID:         authtoken,
 		User:       user,
 		ValidUntil: validUntil,
+		Created:    now,
+		LastUsed:   now,
+		IP:         ip,
+		UserAgent:  userAgent,
 	}, nil
 
 }
 
+// ExtendToken sets the validity of an existing token to minutesValid from now.
+// It returns the new time until the token is valid.
+func ExtendToken(authtoken string, minutesValid int) (time.Time, error) {
+	validUntil := time.Now().Add(time.Duration(minutesValid) * time.Minute)
+
+	_, err := db.Exec("UPDATE authtoken SET validUntil=? WHERE id=?", validUntil.Unix(), hashToken(authtoken))
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return validUntil, nil
+}
+
 // CheckUser checks whether an authstring belongs to a user and the user is valid.
 // It will also check valid until.
+// The last usage of valid tokens is updated.
 func CheckUser(authtoken string) (string, time.Time, bool) {
-	rows, err := db.Query("SELECT user,validUntil FROM authtoken WHERE id=?", authtoken)
+	id := hashToken(authtoken)
+	rows, err := db.Query("SELECT user,validUntil,lastUsed FROM authtoken WHERE id=?", id)
 	if err != nil {
 		return "", time.Time{}, false
 	}
@@ -97,20 +182,35 @@ func CheckUser(authtoken string) (string, time.Time, bool) {
 	}
 
 	var user string
-	var intDate int64
-	err = rows.Scan(&user, &intDate)
+	var intDate, lastUsed int64
+	err = rows.Scan(&user, &intDate, &lastUsed)
 	if err != nil {
 		log.Println("authtoken: error while validating user:", err)
 		return "", time.Time{}, false
 	}
-	return user, time.Unix(intDate, 0), intDate > time.Now().Unix()
+	rows.Close()
+
+	now := time.Now()
+	if intDate <= now.Unix() {
+		return user, time.Unix(intDate, 0), false
+	}
+
+	if now.Sub(time.Unix(lastUsed, 0)) >= lastUsedResolution {
+		_, err = db.Exec("UPDATE authtoken SET lastUsed=? WHERE id=?", now.Unix(), id)
+		if err != nil {
+			log.Println("authtoken: can not update last usage:", err)
+		}
+	}
+	return user, time.Unix(intDate, 0), true
 }
 
 // GetAuthtokenOfUser returns all auth token associated by a user.
+// The ID of the returned token is the hash, which can not be used to log in.
+// The token are sorted by their last usage, starting with the most recent one.
 func GetAuthtokenOfUser(user string) ([]Authtoken, error) {
 	authtoken := make([]Authtoken, 0)
 
-	rows, err := db.Query("SELECT id,user,validUntil FROM authtoken WHERE user=?", user)
+	rows, err := db.Query("SELECT id,user,validUntil,created,lastUsed,ip,userAgent FROM authtoken WHERE user=? ORDER BY lastUsed DESC", user)
 	if err != nil {
 		return nil, err
 	}
@@ -118,12 +218,14 @@ func GetAuthtokenOfUser(user string) ([]Authtoken, error) {
 
 	for rows.Next() {
 		a := Authtoken{}
-		var intDate int64
-		err = rows.Scan(&a.ID, &a.User, &intDate)
+		var intDate, created, lastUsed int64
+		err = rows.Scan(&a.ID, &a.User, &intDate, &created, &lastUsed, &a.IP, &a.UserAgent)
 		if err != nil {
 			return authtoken, err
 		}
 		a.ValidUntil = time.Unix(intDate, 0)
+		a.Created = time.Unix(created, 0)
+		a.LastUsed = time.Unix(lastUsed, 0)
 		authtoken = append(authtoken, a)
 	}
 	return authtoken, nil
@@ -134,10 +236,14 @@ func StartCleanupWorker() {
 		go func() {
 			for {
 				now := time.Now().Unix()
-				_, err := db.Exec("DELETE FROM times WHERE validUntil < ?", now)
+				_, err := db.Exec("DELETE FROM authtoken WHERE validUntil < ?", now)
 				if err != nil {
 					log.Println("authtoken: error during cleanup:", err)
 				}
+				_, err = db.Exec("DELETE FROM personaltoken WHERE validUntil <> 0 AND validUntil < ?", now)
+				if err != nil {
+					log.Println("authtoken: error during cleanup of personal token:", err)
+				}
 				time.Sleep(1 * time.Hour)
 			}
 		}()
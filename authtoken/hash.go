@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authtoken
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// hashKeyFile is the file containing the key used for hashing the authtoken.
+// It is intentionally stored outside of the database so that a leaked database can not be used to create sessions.
+const hashKeyFile = "./authtoken.key"
+
+// hashKeyLength is the length of a newly generated hash key in bytes.
+const hashKeyLength = 32
+
+// loadHashKey loads the hash key from path.
+// If the file doesn't exist, a new random key will be created.
+func loadHashKey(path string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, hashKeyLength)
+		_, err = rand.Read(key)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)), 0600)
+		if err != nil {
+			return fmt.Errorf("authtoken: can not write hash key '%s': %w", path, err)
+		}
+		hashKey = key
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("authtoken: can not read hash key '%s': %w", path, err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return fmt.Errorf("authtoken: invalid hash key '%s': %w", path, err)
+	}
+	if len(key) < hashKeyLength {
+		return fmt.Errorf("authtoken: hash key '%s' is too short (%d bytes, need %d)", path, len(key), hashKeyLength)
+	}
+	hashKey = key
+	return nil
+}
+
+// hashToken returns the keyed hash of an authtoken, which is used as the id in the database.
+func hashToken(authtoken string) string {
+	mac := hmac.New(sha256.New, hashKey)
+	mac.Write([]byte(authtoken))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SessionOfToken returns the session identifier of an authtoken as returned by Authtoken.Session.
+func SessionOfToken(authtoken string) string {
+	return Authtoken{ID: hashToken(authtoken)}.Session()
+}
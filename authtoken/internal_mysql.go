@@ -1,7 +1,7 @@
 //go:build mysql
 
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2021,2022,2024 Marcus Soll
+// Copyright 2021,2022,2024,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -25,12 +25,17 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 )
 
-const databaseVersion = "MySQL-2"
+const databaseVersion = "MySQL-20"
 
 // InitDB initialises the database.
 // Must be called before any other function.
 // Config expects a DSN.
 func InitDB(config string) error {
+	err := loadHashKey(hashKeyFile)
+	if err != nil {
+		return err
+	}
+
 	newDb, err := sql.Open("mysql", config)
 	if err != nil {
 		return fmt.Errorf("authtoken: can not open '%s': %w", config, err)
@@ -1,7 +1,7 @@
 //go:build sqlite
 
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2021,2022,2024 Marcus Soll
+// Copyright 2021,2022,2024,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -29,6 +29,10 @@ import (
 // Must be called before any other function.
 // SQLite will ignore all config.
 func InitDB(config string) error {
+	err := loadHashKey(hashKeyFile)
+	if err != nil {
+		return err
+	}
 	return connectToDB("./authtoken.sqlite3")
 }
 
@@ -62,7 +66,7 @@ func connectToDB(path string) error {
 			return err
 		}
 
-		_, err = tx.Exec("INSERT INTO meta VALUES ('version', 1)")
+		_, err = tx.Exec("INSERT INTO meta VALUES ('version', 5)")
 		if err != nil {
 			return err
 		}
@@ -72,7 +76,7 @@ func connectToDB(path string) error {
 			return err
 		}
 
-		_, err = tx.Exec("CREATE TABLE authtoken (id VARCHAR(600) NOT NULL PRIMARY KEY, user TEXT NOT NULL, validUntil INTEGER NOT NULL)")
+		_, err = tx.Exec("CREATE TABLE authtoken (id VARCHAR(600) NOT NULL PRIMARY KEY, user TEXT NOT NULL, validUntil INTEGER NOT NULL, created INTEGER DEFAULT 0, lastUsed INTEGER DEFAULT 0, ip TEXT DEFAULT '', userAgent TEXT DEFAULT '')")
 		if err != nil {
 			return err
 		}
@@ -82,6 +86,16 @@ func connectToDB(path string) error {
 			return err
 		}
 
+		_, err = tx.Exec("CREATE TABLE personaltoken (id VARCHAR(600) NOT NULL PRIMARY KEY, user TEXT NOT NULL, name TEXT NOT NULL, scopes TEXT NOT NULL, created INTEGER DEFAULT 0, lastUsed INTEGER DEFAULT 0, validUntil INTEGER DEFAULT 0)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE TABLE feedtoken (id VARCHAR(600) NOT NULL PRIMARY KEY, user VARCHAR(600) NOT NULL UNIQUE, created INTEGER DEFAULT 0, lastUsed INTEGER DEFAULT 0)")
+		if err != nil {
+			return err
+		}
+
 		err = tx.Commit()
 		if err != nil {
 			return err
@@ -112,6 +126,139 @@ func connectToDB(path string) error {
 
 		// Upgrade
 		switch versionNr {
+		case 1:
+			log.Println("Upgrade authtoken database 1 -> 2")
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("ALTER TABLE authtoken ADD COLUMN created INTEGER DEFAULT 0")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("ALTER TABLE authtoken ADD COLUMN lastUsed INTEGER DEFAULT 0")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("ALTER TABLE authtoken ADD COLUMN ip TEXT DEFAULT ''")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("ALTER TABLE authtoken ADD COLUMN userAgent TEXT DEFAULT ''")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=2 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+			log.Println("Upgrade done")
+			fallthrough
+		case 2:
+			log.Println("Upgrade authtoken database 2 -> 3")
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			rows, err := tx.Query("SELECT id FROM authtoken")
+			if err != nil {
+				return err
+			}
+
+			ids := make([]string, 0)
+			for rows.Next() {
+				var id string
+				err = rows.Scan(&id)
+				if err != nil {
+					rows.Close()
+					return err
+				}
+				ids = append(ids, id)
+			}
+			rows.Close()
+
+			// Replace plain token with their hash
+			for i := range ids {
+				_, err = tx.Exec("UPDATE authtoken SET id=? WHERE id=?", hashToken(ids[i]), ids[i])
+				if err != nil {
+					return err
+				}
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=3 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
+			// Plain token might still be present in free pages
+			_, err = newDB.Exec("VACUUM")
+			if err != nil {
+				return err
+			}
+			log.Println("Upgrade done")
+			fallthrough
+		case 3:
+			log.Println("Upgrade authtoken database 3 -> 4")
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE TABLE personaltoken (id VARCHAR(600) NOT NULL PRIMARY KEY, user TEXT NOT NULL, name TEXT NOT NULL, scopes TEXT NOT NULL, created INTEGER DEFAULT 0, lastUsed INTEGER DEFAULT 0, validUntil INTEGER DEFAULT 0)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=4 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+			log.Println("Upgrade done")
+			fallthrough
+		case 4:
+			log.Println("Upgrade authtoken database 4 -> 5")
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE TABLE feedtoken (id VARCHAR(600) NOT NULL PRIMARY KEY, user VARCHAR(600) NOT NULL UNIQUE, created INTEGER DEFAULT 0, lastUsed INTEGER DEFAULT 0)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=5 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+			log.Println("Upgrade done")
+			fallthrough
 		default:
 			log.Println("Database is on newest version")
 		}
@@ -0,0 +1,257 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authtoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Scopes of personal tokens.
+const (
+	ScopeRead   = "read"
+	ScopePost   = "post"
+	ScopeUpload = "upload"
+	ScopeAdmin  = "admin"
+)
+
+// Scopes contains all valid scopes of personal tokens.
+var Scopes = []string{ScopeRead, ScopePost, ScopeUpload, ScopeAdmin}
+
+const (
+	// personalTokenPrefix is prepended to all personal tokens so they can be recognised (e.g. by secret scanners).
+	personalTokenPrefix = "dgo_"
+
+	// maxPersonalTokenNameLength is the maximal number of characters of the name of a personal token.
+	maxPersonalTokenNameLength = 100
+)
+
+// PersonalToken represents a long-lived token used by scripts and bots to access the API.
+// Like Authtoken, only a keyed hash of the token is stored in the database.
+// Therefore, ID contains the usable token only if returned by NewPersonalToken and the hash otherwise.
+// A zero ValidUntil means that the token does not expire.
+type PersonalToken struct {
+	ID         string
+	User       string
+	Name       string
+	Scopes     []string `xml:"Scopes>Scope"`
+	Created    time.Time
+	LastUsed   time.Time
+	ValidUntil time.Time
+}
+
+// Identifier returns an identifier of the token which can be shown to the user.
+// It must only be called on token returned by GetPersonalTokenOfUser.
+func (p PersonalToken) Identifier() string {
+	h := sha256.Sum256([]byte(p.ID))
+	return hex.EncodeToString(h[:12])
+}
+
+// HasScope returns whether the token was granted the scope.
+func (p PersonalToken) HasScope(scope string) bool {
+	for i := range p.Scopes {
+		if p.Scopes[i] == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPersonalToken returns whether token looks like a personal token.
+// It does not check whether the token is valid.
+func IsPersonalToken(token string) bool {
+	return strings.HasPrefix(token, personalTokenPrefix)
+}
+
+// NewPersonalToken inserts a new personal token into the database and returns it.
+// All scopes must be valid. A zero validUntil creates a token which does not expire.
+func NewPersonalToken(user, name string, scopes []string, validUntil time.Time) (PersonalToken, error) {
+	if len(scopes) == 0 {
+		return PersonalToken{}, errors.New("no scope given")
+	}
+	for i := range scopes {
+		valid := false
+		for j := range Scopes {
+			if scopes[i] == Scopes[j] {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return PersonalToken{}, fmt.Errorf("unknown scope '%s'", scopes[i])
+		}
+	}
+
+	name = strings.TrimSpace(name)
+	if r := []rune(name); len(r) > maxPersonalTokenNameLength {
+		name = string(r[:maxPersonalTokenNameLength])
+	}
+
+	b := make([]byte, 35)
+	_, err := rand.Read(b)
+	if err != nil {
+		return PersonalToken{}, err
+	}
+	token := personalTokenPrefix + base32.StdEncoding.EncodeToString(b)
+
+	now := time.Now()
+	var intValidUntil int64
+	if !validUntil.IsZero() {
+		intValidUntil = validUntil.Unix()
+	}
+
+	_, err = db.Exec("INSERT INTO personaltoken (id, user, name, scopes, created, lastUsed, validUntil) VALUES (?, ?, ?, ?, ?, ?, ?)", hashToken(token), user, name, strings.Join(scopes, ","), now.Unix(), 0, intValidUntil)
+	if err != nil {
+		return PersonalToken{}, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	return PersonalToken{
+		ID:         token,
+		User:       user,
+		Name:       name,
+		Scopes:     scopes,
+		Created:    now,
+		ValidUntil: validUntil,
+	}, nil
+}
+
+// CheckPersonalToken checks whether a personal token is valid and returns it.
+// The ID of the returned token is the hash.
+// The last usage of valid tokens is updated.
+func CheckPersonalToken(token string) (PersonalToken, bool) {
+	if !IsPersonalToken(token) {
+		return PersonalToken{}, false
+	}
+
+	id := hashToken(token)
+	rows, err := db.Query("SELECT id,user,name,scopes,created,lastUsed,validUntil FROM personaltoken WHERE id=?", id)
+	if err != nil {
+		log.Println("authtoken: error while validating personal token:", err)
+		return PersonalToken{}, false
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return PersonalToken{}, false
+	}
+
+	p, err := scanPersonalToken(rows.Scan)
+	if err != nil {
+		log.Println("authtoken: error while validating personal token:", err)
+		return PersonalToken{}, false
+	}
+	rows.Close()
+
+	now := time.Now()
+	if !p.ValidUntil.IsZero() && !p.ValidUntil.After(now) {
+		return p, false
+	}
+
+	if now.Sub(p.LastUsed) >= lastUsedResolution {
+		_, err = db.Exec("UPDATE personaltoken SET lastUsed=? WHERE id=?", now.Unix(), id)
+		if err != nil {
+			log.Println("authtoken: can not update last usage:", err)
+		}
+		p.LastUsed = now
+	}
+	return p, true
+}
+
+// GetPersonalTokenOfUser returns all personal token of a user.
+// Expired token are included until they are removed by the cleanup worker.
+// The ID of the returned token is the hash, which can not be used to access the API.
+// The token are sorted by their creation, starting with the most recent one.
+func GetPersonalTokenOfUser(user string) ([]PersonalToken, error) {
+	token := make([]PersonalToken, 0)
+
+	rows, err := db.Query("SELECT id,user,name,scopes,created,lastUsed,validUntil FROM personaltoken WHERE user=? ORDER BY created DESC", user)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanPersonalToken(rows.Scan)
+		if err != nil {
+			return token, errors.New(fmt.Sprintln("Database error:", err))
+		}
+		token = append(token, p)
+	}
+	return token, nil
+}
+
+// DeletePersonalToken removes the personal token of a user identified by PersonalToken.Identifier.
+// It returns whether a token was deleted.
+func DeletePersonalToken(user, identifier string) (bool, error) {
+	token, err := GetPersonalTokenOfUser(user)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range token {
+		if token[i].Identifier() != identifier {
+			continue
+		}
+		_, err = db.Exec("DELETE FROM personaltoken WHERE id=?", token[i].ID)
+		if err != nil {
+			return false, errors.New(fmt.Sprintln("Database error:", err))
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// DeleteUserPersonalToken removes all personal token associated by a user.
+// It returns the number of deleted tokens.
+func DeleteUserPersonalToken(user string) (int64, error) {
+	r, err := db.Exec("DELETE FROM personaltoken WHERE user=?", user)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+	return count, nil
+}
+
+// scanPersonalToken reads a personal token from a row containing id,user,name,scopes,created,lastUsed,validUntil.
+func scanPersonalToken(scan func(dest ...interface{}) error) (PersonalToken, error) {
+	p := PersonalToken{}
+	var scopes string
+	var created, lastUsed, validUntil int64
+	err := scan(&p.ID, &p.User, &p.Name, &scopes, &created, &lastUsed, &validUntil)
+	if err != nil {
+		return p, err
+	}
+	p.Scopes = strings.Split(scopes, ",")
+	p.Created = time.Unix(created, 0)
+	if lastUsed != 0 {
+		p.LastUsed = time.Unix(lastUsed, 0)
+	}
+	if validUntil != 0 {
+		p.ValidUntil = time.Unix(validUntil, 0)
+	}
+	return p, nil
+}
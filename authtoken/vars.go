@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2022 Marcus Soll
+// Copyright 2022,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -19,5 +19,6 @@ package authtoken
 import "database/sql"
 
 var (
-	db *sql.DB
+	db      *sql.DB
+	hashKey []byte
 )
@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package broadcast provides an in-process broadcaster for changes of topics, posts, files and events.
+// The write paths of the other packages publish all changes, which can then be delivered to open clients.
+// Delivery is best effort: slow subscribers will miss messages instead of blocking the publisher.
+package broadcast
+
+import "sync"
+
+// Types of messages.
+const (
+	TopicCreated   = "topic-created"
+	TopicDeleted   = "topic-deleted"
+	TopicRenamed   = "topic-renamed"
+	TopicClosed    = "topic-closed"
+	TopicOpened    = "topic-opened"
+	TopicPinned    = "topic-pinned"
+	TopicUnpinned  = "topic-unpinned"
+	TopicMoved     = "topic-moved"
+	TopicTagged    = "topic-tagged"
+	PostCreated    = "post-created"
+	PostEdited     = "post-edited"
+	PostDeleted    = "post-deleted"
+	FileUploaded   = "file-uploaded"
+	FileDeleted    = "file-deleted"
+	EventCreated   = "event-created"
+	EventDeleted   = "event-deleted"
+	ContentRemoved = "content-removed"
+	CategoryChange = "category-changed"
+	TagChange      = "tag-changed"
+)
+
+// subscriberBuffer is the number of messages buffered per subscriber.
+const subscriberBuffer = 50
+
+// Message represents a single change.
+// Topic is empty if the change does not belong to a single topic (e.g. when a user and all associated content is deleted).
+type Message struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
+	ID    string `json:"id"`
+}
+
+var (
+	subscribers      = make(map[chan Message]bool)
+	subscribersMutex = sync.RWMutex{}
+)
+
+// Publish sends a message to all subscribers.
+// It never blocks. If the buffer of a subscriber is full, the message is dropped for that subscriber.
+func Publish(m Message) {
+	subscribersMutex.RLock()
+	defer subscribersMutex.RUnlock()
+	for c := range subscribers {
+		select {
+		case c <- m:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving all published messages.
+// The returned function must be called once the subscriber is no longer interested in messages.
+// It will close the channel.
+func Subscribe() (<-chan Message, func()) {
+	c := make(chan Message, subscriberBuffer)
+
+	subscribersMutex.Lock()
+	subscribers[c] = true
+	subscribersMutex.Unlock()
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			subscribersMutex.Lock()
+			delete(subscribers, c)
+			subscribersMutex.Unlock()
+			close(c)
+		})
+	}
+}
@@ -0,0 +1,387 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Top-Ranger/auth/data"
+	"github.com/Top-Ranger/discussiongo/database"
+	"github.com/Top-Ranger/discussiongo/events"
+)
+
+// maxCategoryNameLength is the maximal number of characters of the name of a category.
+const maxCategoryNameLength = 200
+
+type categoryData struct {
+	ID          string
+	Name        string
+	Description string
+	Position    int
+	AdminOnly   bool
+	Topics      int
+	New         bool
+	Modified    string
+	Selected    bool
+
+	lastModified time.Time
+}
+
+func init() {
+	http.HandleFunc("/category.html", categoryHandleFunc)
+	http.HandleFunc("/moveTopic.html", moveTopicHandleFunc)
+	http.HandleFunc("/adminAddCategory.html", adminAddCategoryHandleFunc)
+	http.HandleFunc("/adminEditCategory.html", adminEditCategoryHandleFunc)
+	http.HandleFunc("/adminDeleteCategory.html", adminDeleteCategoryHandleFunc)
+}
+
+// newCategoryData converts a category into its template representation.
+func newCategoryData(c database.Category, t Translation) categoryData {
+	cd := categoryData{
+		ID:          c.ID,
+		Name:        c.Name,
+		Description: c.Description,
+		Position:    c.Position,
+		AdminOnly:   c.AdminOnly,
+	}
+	if c.ID == database.NoCategory {
+		cd.Name = t.NoCategory
+	}
+	return cd
+}
+
+// parseCategoryForm reads the properties of a category from a form.
+// It returns false if the form is invalid.
+func parseCategoryForm(r *http.Request) (name, description string, position int, adminOnly, ok bool) {
+	name = strings.TrimSpace(r.Form.Get("name"))
+	if name == "" {
+		return
+	}
+	if n := []rune(name); len(n) > maxCategoryNameLength {
+		name = string(n[:maxCategoryNameLength])
+	}
+
+	description = strings.TrimSpace(r.Form.Get("description"))
+
+	if p := strings.TrimSpace(r.Form.Get("position")); p != "" {
+		var err error
+		position, err = strconv.Atoi(p)
+		if err != nil {
+			return
+		}
+	}
+
+	adminOnly = r.Form.Get("adminonly") != ""
+	ok = true
+	return
+}
+
+// moveTopic moves a topic into a category and saves the corresponding event.
+// Permissions must be checked by the caller.
+func moveTopic(user string, topic database.Topic, category database.Category) error {
+	// The old category might have been deleted concurrently, the move is still valid in that case
+	var oldName string
+	old, err := database.GetCategory(topic.Category)
+	if err == nil {
+		oldName = old.Name
+	}
+
+	err = database.TopicSetCategory(topic.ID, category.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = saveEvent(events.Event{
+		Type:  EventTopicMoved,
+		User:  user,
+		Topic: topic.ID,
+		Date:  time.Now(),
+		Data:  eventCreateTopicMovedData(oldName, category.Name),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+	return nil
+}
+
+func categoryHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+	serveTopicList(rw, r, id)
+}
+
+func moveTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !canMoveTopic(isAdmin) {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	token := r.Form.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	topic, err := database.GetTopic(r.Form.Get("id"))
+	if err != nil {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	category, err := database.GetCategory(r.Form.Get("category"))
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	if category.ID != topic.Category {
+		err = moveTopic(user, topic, category)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/topic.html?id=%s", config.ServerPath, topic.ID), http.StatusFound)
+}
+
+func adminAddCategoryHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	token := r.Form.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	name, description, position, adminOnly, ok := parseCategoryForm(r)
+	if !ok {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	id, err := database.AddCategory(name, description, position, adminOnly)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("%s added category %s (%s)", user, id, name)
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/usermanagement.html#categories", config.ServerPath), http.StatusFound)
+}
+
+func adminEditCategoryHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	token := r.Form.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	id := r.Form.Get("id")
+	if id == "" || id == database.NoCategory {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	name, description, position, adminOnly, ok := parseCategoryForm(r)
+	if !ok {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	err = database.EditCategory(id, name, description, position, adminOnly)
+	if err != nil {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/usermanagement.html#category%s", config.ServerPath, id), http.StatusFound)
+}
+
+func adminDeleteCategoryHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	q := r.URL.Query()
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	id := q.Get("id")
+	if id == "" || id == database.NoCategory {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	err = database.DeleteCategory(id)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("%s deleted category %s", user, id)
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/usermanagement.html#categories", config.ServerPath), http.StatusFound)
+}
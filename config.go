@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021,2022 Marcus Soll
+// Copyright 2020,2021,2022,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -20,9 +20,12 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"strings"
 	"time"
+
+	"github.com/Top-Ranger/discussiongo/files"
 )
 
 type configData struct {
@@ -44,11 +47,54 @@ type configData struct {
 	EnableFileUpload              bool
 	EnableFileUploadAdmin         bool
 	FileMaxMB                     int
+	FileAllowedTypes              []string
+	FileDeniedTypes               []string
+	FileQuotaUserMB               int
+	FileQuotaTopicMB              int
 	FileUploadMessage             string
 	AdminEventDuration            string
 	EveryoneCanCloseAndOpenTopics bool
 	DatabaseConfig                string
 	InsecureAllowCookiesOverHTTP  bool
+	RateLimit                     rateLimitConfig
+	TrustedProxies                []string
+	FileStorage                   fileStorageConfig
+	UploadScanner                 uploadScannerConfig
+
+	trustedProxies []*net.IPNet
+}
+
+// rateLimitConfig holds the thresholds for failed logins, registrations and invitations.
+// Durations are parsed with time.ParseDuration.
+type rateLimitConfig struct {
+	MaxFailuresIP      int
+	MaxFailuresAccount int
+	MaxRegistrationsIP int
+	BaseDelay          string
+	MaxDelay           string
+	LockoutDuration    string
+	ResetAfter         string
+}
+
+// fileStorageConfig describes where the content of uploaded files is stored.
+// Type is either 'local' (using Directory) or 's3' (using S3).
+type fileStorageConfig struct {
+	Type      string
+	Directory string
+	S3        files.S3Config
+}
+
+// uploadScannerConfig describes how uploads are checked for malware.
+// Type is either empty (no scan), 'clamd' (using Network and Address) or 'command' (using Command and Arguments).
+// If FailOpen is set, uploads are accepted if the scanner fails. Timeout is parsed with time.ParseDuration.
+type uploadScannerConfig struct {
+	Type      string
+	Network   string
+	Address   string
+	Command   string
+	Arguments []string
+	Timeout   string
+	FailOpen  bool
 }
 
 var config = configData{}
@@ -70,7 +116,25 @@ func loadConfig(path string) (configData, error) {
 		return configData{}, errors.New(fmt.Sprintln("Can not read config.json:", err))
 	}
 
-	c := configData{}
+	c := configData{
+		TrustedProxies: []string{"127.0.0.0/8", "::1/128"},
+		RateLimit: rateLimitConfig{
+			MaxFailuresIP:      50,
+			MaxFailuresAccount: 10,
+			MaxRegistrationsIP: 10,
+			BaseDelay:          "1s",
+			MaxDelay:           "1m",
+			LockoutDuration:    "15m",
+			ResetAfter:         "1h",
+		},
+		FileStorage: fileStorageConfig{
+			Type:      "local",
+			Directory: "./filestorage",
+		},
+		UploadScanner: uploadScannerConfig{
+			Timeout: "1m",
+		},
+	}
 	err = json.Unmarshal(b, &c)
 	if err != nil {
 		return configData{}, errors.New(fmt.Sprintln("Error while parsing config.json:", err))
@@ -84,5 +148,24 @@ func loadConfig(path string) (configData, error) {
 	c.ServerPath = strings.TrimSuffix(c.ServerPath, "/")
 	c.ServerPrefix = strings.TrimSuffix(c.ServerPrefix, "/")
 
+	c.FileAllowedTypes, err = parseFileTypes(c.FileAllowedTypes)
+	if err != nil {
+		return configData{}, errors.New(fmt.Sprintln("Error while parsing FileAllowedTypes:", err))
+	}
+
+	c.FileDeniedTypes, err = parseFileTypes(c.FileDeniedTypes)
+	if err != nil {
+		return configData{}, errors.New(fmt.Sprintln("Error while parsing FileDeniedTypes:", err))
+	}
+
+	if c.FileQuotaUserMB < 0 || c.FileQuotaTopicMB < 0 {
+		return configData{}, errors.New("file quotas must not be negative")
+	}
+
+	c.trustedProxies, err = parseTrustedProxies(c.TrustedProxies)
+	if err != nil {
+		return configData{}, errors.New(fmt.Sprintln("Error while parsing TrustedProxies:", err))
+	}
+
 	return c, nil
 }
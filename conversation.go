@@ -0,0 +1,656 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Top-Ranger/auth/data"
+	"github.com/Top-Ranger/discussiongo/database"
+	"github.com/Top-Ranger/discussiongo/files"
+)
+
+// maxConversationParticipants is the maximal number of users which can be added to a conversation on creation (excluding the creator).
+const maxConversationParticipants = 20
+
+// maxConversationTitleLength is the maximal number of characters of a conversation title.
+const maxConversationTitleLength = 200
+
+// conversationFilesPrefix is used to build the pseudo topic of files attached to a conversation.
+// It can not collide with the ID of a topic.
+const conversationFilesPrefix = "SYSTEM: conversation "
+
+type templateConversationsData struct {
+	ServerPath    string
+	ForumName     string
+	User          string
+	Token         string
+	Participants  string
+	Conversations []conversationData
+	Translation   Translation
+}
+
+type conversationData struct {
+	ID           string
+	Title        string
+	Creator      string
+	Participants []string
+	Modified     string
+	New          bool
+}
+
+type templateConversationData struct {
+	ServerPath        string
+	ForumName         string
+	User              string
+	Token             string
+	Conversation      conversationData
+	Timeline          []conversationTimelineData
+	CanSaveFiles      bool
+	FileUploadMessage string
+	Translation       Translation
+}
+
+type conversationTimelineData struct {
+	Time    time.Time
+	Message *messageData
+	File    *fileData
+}
+
+type messageData struct {
+	ID      string
+	Sender  string
+	Content template.HTML
+	Date    string
+	New     bool
+}
+
+var (
+	conversationsTemplate *template.Template
+	conversationTemplate  *template.Template
+)
+
+func init() {
+	var err error
+
+	conversationsTemplate, err = template.New("conversations").Funcs(evenOddFuncMap).ParseFS(templateFiles, "template/conversations.html")
+	if err != nil {
+		panic(err)
+	}
+
+	conversationTemplate, err = template.New("conversation").Funcs(evenOddFuncMap).ParseFS(templateFiles, "template/conversation.html")
+	if err != nil {
+		panic(err)
+	}
+
+	http.HandleFunc("/conversations.html", conversationsHandleFunc)
+	http.HandleFunc("/conversation.html", conversationHandleFunc)
+	http.HandleFunc("/newConversation.html", newConversationHandleFunc)
+	http.HandleFunc("/newMessage.html", newMessageHandleFunc)
+	http.HandleFunc("/conversationFile.html", conversationFileHandleFunc)
+	http.HandleFunc("/leaveConversation.html", leaveConversationHandleFunc)
+}
+
+// conversationFilesTopic returns the pseudo topic used for the files of a conversation.
+func conversationFilesTopic(id string) string {
+	return conversationFilesPrefix + id
+}
+
+// conversationOfFile returns the conversation a file is attached to.
+// It returns false if the file belongs to a normal topic.
+func conversationOfFile(topic string) (string, bool) {
+	return strings.CutPrefix(topic, conversationFilesPrefix)
+}
+
+// withoutConversationFiles returns all files which are not attached to a conversation.
+func withoutConversationFiles(fs []files.File) []files.File {
+	return slices.DeleteFunc(fs, func(f files.File) bool {
+		_, ok := conversationOfFile(f.Topic)
+		return ok
+	})
+}
+
+// parseParticipants parses a list of user names (one per line).
+// The returned names are free of duplicates and do not contain the creator.
+func parseParticipants(s, creator string) []string {
+	participants := make([]string, 0)
+	for _, p := range strings.Split(s, "\n") {
+		p = strings.TrimSpace(p)
+		if p == "" || p == creator || slices.Contains(participants, p) {
+			continue
+		}
+		participants = append(participants, p)
+	}
+	return participants
+}
+
+// newConversationData converts a conversation into its template representation.
+// The current user is not included in the participants.
+func newConversationData(c database.Conversation, user string, lastRead time.Time) conversationData {
+	cd := conversationData{
+		ID:           c.ID,
+		Title:        c.Title,
+		Creator:      c.Creator,
+		Participants: make([]string, 0, len(c.Participants)),
+		Modified:     c.LastModified.Format(time.RFC822),
+		New:          c.LastModified.After(lastRead),
+	}
+	for i := range c.Participants {
+		if c.Participants[i] != user {
+			cd.Participants = append(cd.Participants, c.Participants[i])
+		}
+	}
+	return cd
+}
+
+// getConversationForUser returns the conversation with the given ID if the user can access it.
+// It writes an error to rw and returns false otherwise.
+func getConversationForUser(rw http.ResponseWriter, id, user string) (database.Conversation, bool) {
+	c, err := database.GetConversation(id)
+	if err != nil {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(GetDefaultTranslation().ConversationNotFound))
+		return database.Conversation{}, false
+	}
+	if !canAccessConversation(user, true, c) {
+		// Do not reveal whether the conversation exists
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(GetDefaultTranslation().ConversationNotFound))
+		return database.Conversation{}, false
+	}
+	return c, true
+}
+
+func conversationsHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	conversations, err := database.GetConversationsOfUser(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	readTimes, err := database.GetConversationReadTimes(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	lastRead := make(map[string]time.Time, len(readTimes))
+	for i := range readTimes {
+		lastRead[readTimes[i].Conversation] = readTimes[i].LastRead
+	}
+
+	token, err := data.GetStringsTimed(time.Now(), fmt.Sprintf("%s;Token", user))
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	td := templateConversationsData{
+		ServerPath:    config.ServerPath,
+		ForumName:     config.ForumName,
+		User:          user,
+		Token:         token,
+		Participants:  r.URL.Query().Get("to"),
+		Conversations: make([]conversationData, 0, len(conversations)),
+		Translation:   GetDefaultTranslation(),
+	}
+
+	for i := range conversations {
+		td.Conversations = append(td.Conversations, newConversationData(conversations[i], user, lastRead[conversations[i].ID]))
+	}
+
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	err = conversationsTemplate.ExecuteTemplate(rw, "conversations.html", td)
+	if err != nil {
+		log.Println("Error executing conversations template:", err)
+	}
+}
+
+func conversationHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Redirect(rw, r, fmt.Sprintf("%s/conversations.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	c, ok := getConversationForUser(rw, id, user)
+	if !ok {
+		return
+	}
+
+	readTimes, err := database.GetConversationReadTimes(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	var lastRead time.Time
+	for i := range readTimes {
+		if readTimes[i].Conversation == c.ID {
+			lastRead = readTimes[i].LastRead
+			break
+		}
+	}
+
+	messages, err := database.GetMessages(c.ID)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	fs, err := files.GetFileMetadataOfTopic(conversationFilesTopic(c.ID))
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	tf := newTopicFiles(fs)
+
+	token, err := data.GetStringsTimed(time.Now(), fmt.Sprintf("%s;Token", user))
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	td := templateConversationData{
+		ServerPath:        config.ServerPath,
+		ForumName:         config.ForumName,
+		User:              user,
+		Token:             token,
+		Conversation:      newConversationData(c, user, lastRead),
+		Timeline:          make([]conversationTimelineData, 0, len(messages)+len(fs)),
+		CanSaveFiles:      canUploadFiles(loggedIn, isAdmin),
+		FileUploadMessage: config.FileUploadMessage,
+		Translation:       GetDefaultTranslation(),
+	}
+
+	for i := range messages {
+		m := messageData{
+			ID:      messages[i].ID,
+			Sender:  messages[i].Sender,
+			Content: formatTopicPost(messages[i].Content, tf),
+			Date:    messages[i].Time.Format(time.RFC822),
+			New:     messages[i].Time.After(lastRead) && messages[i].Sender != user,
+		}
+		td.Timeline = append(td.Timeline, conversationTimelineData{Time: messages[i].Time, Message: &m})
+	}
+
+	for i := range fs {
+		f := newFileData(fs[i], user, loggedIn, isAdmin)
+		f.New = fs[i].Date.After(lastRead) && fs[i].User != user
+		td.Timeline = append(td.Timeline, conversationTimelineData{Time: fs[i].Date, File: &f})
+	}
+
+	sort.SliceStable(td.Timeline, func(i, j int) bool { return td.Timeline[i].Time.Before(td.Timeline[j].Time) })
+
+	err = database.SetConversationRead(c.ID, user, time.Now())
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	err = conversationTemplate.ExecuteTemplate(rw, "conversation.html", td)
+	if err != nil {
+		log.Println("Error executing conversation template:", err)
+	}
+}
+
+func newConversationHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Redirect(rw, r, fmt.Sprintf("%s/conversations.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	token := r.Form.Get("token")
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	title := strings.TrimSpace(r.Form.Get("title"))
+	message := r.Form.Get("message")
+	if title == "" || strings.TrimSpace(message) == "" {
+		http.Redirect(rw, r, fmt.Sprintf("%s/conversations.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	if utf8.RuneCountInString(title) > maxConversationTitleLength {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.ConversationTitleTooLong))
+		return
+	}
+
+	participants := parseParticipants(r.Form.Get("participants"), user)
+	if len(participants) == 0 {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.NoParticipants))
+		return
+	}
+	if len(participants) > maxConversationParticipants {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.TooManyParticipants))
+		return
+	}
+
+	for i := range participants {
+		exists, err := database.UserExists(participants[i])
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		if !exists {
+			rw.WriteHeader(http.StatusBadRequest)
+			rw.Write([]byte(fmt.Sprintf("%s: %s", t.UnknownParticipant, participants[i])))
+			return
+		}
+	}
+
+	id, err := database.AddConversation(title, user, participants)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	messageID, err := database.AddMessage(id, user, message)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/conversation.html?id=%s#message%s", config.ServerPath, url.QueryEscape(id), url.QueryEscape(messageID)), http.StatusFound)
+}
+
+func newMessageHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Redirect(rw, r, fmt.Sprintf("%s/conversations.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	token := r.Form.Get("token")
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	c, ok := getConversationForUser(rw, r.Form.Get("id"), user)
+	if !ok {
+		return
+	}
+
+	message := r.Form.Get("message")
+	if strings.TrimSpace(message) == "" {
+		http.Redirect(rw, r, fmt.Sprintf("%s/conversation.html?id=%s", config.ServerPath, url.QueryEscape(c.ID)), http.StatusFound)
+		return
+	}
+
+	messageID, err := database.AddMessage(c.ID, user, message)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/conversation.html?id=%s#message%s", config.ServerPath, url.QueryEscape(c.ID), url.QueryEscape(messageID)), http.StatusFound)
+}
+
+// conversationFileHandleFunc attaches a file to a conversation.
+// Unlike files in topics, no events or webhooks are created since conversations are private.
+func conversationFileHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	if !canUploadFiles(loggedIn, isAdmin) {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	err = r.ParseMultipartForm(uploadMemoryLimit)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	token := r.Form.Get("token")
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	c, ok := getConversationForUser(rw, r.Form.Get("id"), user)
+	if !ok {
+		return
+	}
+
+	fileReader, meta, err := r.FormFile("file")
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if meta.Size > int64(config.FileMaxMB)*1000000 {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.FileTooLarge))
+		return
+	}
+
+	defer fileReader.Close()
+
+	fileID, err := saveFileContent(files.File{
+		Name:   meta.Filename,
+		User:   user,
+		Topic:  conversationFilesTopic(c.ID),
+		Length: meta.Size,
+	}, fileReader)
+	if message, ok := uploadErrorMessage(err, t); ok {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(message))
+		return
+	}
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	err = database.ConversationModifyTime(c.ID)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	err = database.SetConversationRead(c.ID, user, time.Now())
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/conversation.html?id=%s#file%s", config.ServerPath, url.QueryEscape(c.ID), url.QueryEscape(fileID)), http.StatusFound)
+}
+
+// leaveConversationHandleFunc removes the current user from a conversation.
+// The conversation including all attached files is deleted once the last participant has left.
+func leaveConversationHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	q := r.URL.Query()
+	token := q.Get("token")
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	c, ok := getConversationForUser(rw, q.Get("id"), user)
+	if !ok {
+		return
+	}
+
+	deleted, err := database.LeaveConversation(c.ID, user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if deleted {
+		_, err = files.DeleteTopicFiles(conversationFilesTopic(c.ID))
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/conversations.html", config.ServerPath), http.StatusFound)
+}
+
+// deleteAbandonedConversationFiles removes the files of all conversations which have no participants left.
+// conversations must be the conversations of a deleted user as returned by database.GetConversationsOfUser before the deletion.
+// It returns the number of deleted files.
+func deleteAbandonedConversationFiles(conversations []database.Conversation) (int64, error) {
+	count := int64(0)
+	for i := range conversations {
+		if len(conversations[i].Participants) > 1 {
+			continue
+		}
+		c, err := files.DeleteTopicFiles(conversationFilesTopic(conversations[i].ID))
+		if err != nil {
+			return count, err
+		}
+		count += c
+	}
+	return count, nil
+}
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021 Marcus Soll
+// Copyright 2020,2021,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -18,6 +18,8 @@ package database
 import (
 	"errors"
 	"fmt"
+
+	"github.com/Top-Ranger/discussiongo/broadcast"
 )
 
 // DeleteUser removes a user and all associated information from the database.
@@ -32,7 +34,7 @@ func DeleteUser(user string) (int64, error) {
 		return 0, errors.New("User not found")
 	}
 
-	defer SetLastUpdateTopicPost()
+	defer SetLastUpdateAll()
 
 	userData, err := GetUser(user)
 	if err != nil {
@@ -52,7 +54,8 @@ func DeleteUser(user string) (int64, error) {
 
 	countAll := int64(0)
 
-	r, err := tx.Exec("DELETE FROM post WHERE poster=?", user)
+	// Revisions of posts written by the user as well as of all posts in topics created by the user
+	r, err := tx.Exec("DELETE FROM postrevision WHERE post IN (SELECT id FROM post WHERE poster=? OR topic IN (SELECT id FROM topic WHERE creator=?))", user, user)
 	if err != nil {
 		return 0, errors.New(fmt.Sprintln("Database error:", err))
 	}
@@ -64,6 +67,28 @@ func DeleteUser(user string) (int64, error) {
 
 	countAll += count
 
+	r, err = tx.Exec("DELETE FROM post WHERE poster=?", user)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err = r.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+
+	countAll += count
+
+	_, err = tx.Exec("DELETE FROM topictag WHERE topic IN (SELECT id FROM topic WHERE creator=?)", user)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	err = deleteUnusedTags(tx)
+	if err != nil {
+		return 0, err
+	}
+
 	r, err = tx.Exec("DELETE FROM topic WHERE creator=?", user)
 	if err != nil {
 		return 0, errors.New(fmt.Sprintln("Database error:", err))
@@ -101,6 +126,72 @@ func DeleteUser(user string) (int64, error) {
 		return 0, errors.New(fmt.Sprintln("Database count error:", err))
 	}
 
+	// Private conversations
+	r, err = tx.Exec("DELETE FROM message WHERE sender=?", user)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err = r.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+
+	countAll += count
+
+	r, err = tx.Exec("DELETE FROM conversationparticipant WHERE user=?", user)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err = r.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+
+	countAll += count
+
+	_, err = tx.Exec("UPDATE conversation SET creator='' WHERE creator=?", user)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	r, err = tx.Exec("DELETE FROM message WHERE conversation NOT IN (SELECT conversation FROM conversationparticipant)")
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err = r.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+
+	countAll += count
+
+	r, err = tx.Exec("DELETE FROM conversation WHERE id NOT IN (SELECT conversation FROM conversationparticipant)")
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err = r.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+
+	countAll += count
+
+	r, err = tx.Exec("DELETE FROM recoverycode WHERE user=?", user)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err = r.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+
+	countAll += count
+
 	r, err = tx.Exec("DELETE FROM user WHERE name=?", user)
 	if err != nil {
 		return 0, errors.New(fmt.Sprintln("Database error:", err))
@@ -118,6 +209,8 @@ func DeleteUser(user string) (int64, error) {
 		return 0, errors.New(fmt.Sprintln("Database error:", err))
 	}
 
+	broadcast.Publish(broadcast.Message{Type: broadcast.ContentRemoved})
+
 	err = nil
 
 	return countAll, err
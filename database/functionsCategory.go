@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Top-Ranger/discussiongo/broadcast"
+)
+
+// NoCategory is the category of all topics which do not belong to a category.
+const NoCategory = "0"
+
+// GetCategories returns all categories sorted by their position.
+func GetCategories() ([]Category, error) {
+	rows, err := db.Query("SELECT id, name, description, position, adminonly FROM category ORDER BY position ASC, id ASC")
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	categories := make([]Category, 0)
+
+	for rows.Next() {
+		c := Category{}
+		var intID int64
+		err = rows.Scan(&intID, &c.Name, &c.Description, &c.Position, &c.AdminOnly)
+		if err != nil {
+			return nil, err
+		}
+		c.ID = strconv.FormatInt(intID, 10)
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+// GetCategory returns the category associated with the given ID.
+// For NoCategory, an unnamed category without restrictions is returned.
+func GetCategory(ID string) (Category, error) {
+	if ID == NoCategory {
+		return Category{ID: NoCategory}, nil
+	}
+
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return Category{}, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	rows, err := db.Query("SELECT id, name, description, position, adminonly FROM category WHERE id=?", intID)
+	if err != nil {
+		return Category{}, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Category{}, errors.New("No such category")
+	}
+
+	c := Category{}
+	err = rows.Scan(&intID, &c.Name, &c.Description, &c.Position, &c.AdminOnly)
+	if err != nil {
+		return Category{}, err
+	}
+	c.ID = strconv.FormatInt(intID, 10)
+	return c, nil
+}
+
+// AddCategory adds a new category to the database.
+func AddCategory(name, description string, position int, adminOnly bool) (string, error) {
+	defer SetLastUpdateTopicList()
+	r, err := db.Exec("INSERT INTO category (name, description, position, adminonly) VALUES (?, ?, ?, ?)", name, description, position, adminOnly)
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	id, err := r.LastInsertId()
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database id error:", err))
+	}
+
+	broadcast.Publish(broadcast.Message{Type: broadcast.CategoryChange, ID: strconv.FormatInt(id, 10)})
+	return strconv.FormatInt(id, 10), nil
+}
+
+// EditCategory changes all properties of a category.
+func EditCategory(ID, name, description string, position int, adminOnly bool) error {
+	defer SetLastUpdateTopicList()
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	// Do not use RowsAffected since MySQL does not count unchanged rows
+	_, err = GetCategory(ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE category SET name=?, description=?, position=?, adminonly=? WHERE id=?", name, description, position, adminOnly, intID)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	broadcast.Publish(broadcast.Message{Type: broadcast.CategoryChange, ID: ID})
+	return nil
+}
+
+// DeleteCategory removes a category from the database.
+// All topics of the category are moved to NoCategory.
+func DeleteCategory(ID string) error {
+	defer SetLastUpdateAll()
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE topic SET category=0 WHERE category=?", intID)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	r, err := tx.Exec("DELETE FROM category WHERE id=?", intID)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database count error:", err))
+	}
+
+	if count != 1 {
+		return errors.New(fmt.Sprintln("Delete count is", count))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	broadcast.Publish(broadcast.Message{Type: broadcast.CategoryChange, ID: ID})
+	return nil
+}
+
+// TopicSetCategory moves a topic into a category.
+// category must be NoCategory or the ID of an existing category.
+// It does not affect the modification time.
+func TopicSetCategory(ID, category string) error {
+	defer SetLastUpdate(ID)
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	categoryIntID, err := strconv.ParseInt(category, 10, 64)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Can not convert category ID:", err))
+	}
+
+	_, err = db.Exec("UPDATE topic SET category=? WHERE id=?", categoryIntID, intID)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	broadcast.Publish(broadcast.Message{Type: broadcast.TopicMoved, Topic: ID, ID: ID})
+	return nil
+}
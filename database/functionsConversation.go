@@ -0,0 +1,369 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// AddConversation adds a new conversation to the database.
+// The creator is always added as a participant. All participants must exist.
+// It returns the ID of the conversation.
+func AddConversation(title, creator string, participants []string) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer tx.Rollback()
+
+	date := time.Now().Unix()
+	r, err := tx.Exec("INSERT INTO conversation (title, creator, created, lastmodified) VALUES (?, ?, ?, ?)", title, creator, date, date)
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	id, err := r.LastInsertId()
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database id error:", err))
+	}
+
+	_, err = tx.Exec("INSERT INTO conversationparticipant (conversation, user, lastread) VALUES (?, ?, ?)", id, creator, date)
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	for _, p := range participants {
+		if p == creator {
+			continue
+		}
+		_, err = tx.Exec("INSERT INTO conversationparticipant (conversation, user, lastread) VALUES (?, ?, ?)", id, p, 0)
+		if err != nil {
+			return "", errors.New(fmt.Sprintln("Database error:", err))
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	return strconv.FormatInt(id, 10), nil
+}
+
+// GetConversation returns the conversation associated with the ID.
+func GetConversation(ID string) (Conversation, error) {
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return Conversation{}, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	c := Conversation{}
+	var created, modified int64
+	err = db.QueryRow("SELECT id, title, creator, created, lastmodified FROM conversation WHERE id=?", intID).Scan(&intID, &c.Title, &c.Creator, &created, &modified)
+	if err != nil {
+		return Conversation{}, errors.New("Can not read conversation data")
+	}
+	c.ID = strconv.FormatInt(intID, 10)
+	c.Created = time.Unix(created, 0)
+	c.LastModified = time.Unix(modified, 0)
+
+	rows, err := db.Query("SELECT user FROM conversationparticipant WHERE conversation=? ORDER BY user ASC", intID)
+	if err != nil {
+		return Conversation{}, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	c.Participants = make([]string, 0)
+	for rows.Next() {
+		var user string
+		err = rows.Scan(&user)
+		if err != nil {
+			return Conversation{}, err
+		}
+		c.Participants = append(c.Participants, user)
+	}
+	return c, nil
+}
+
+// GetConversationsOfUser returns all conversations the user participates in, starting with the most recently modified.
+func GetConversationsOfUser(user string) ([]Conversation, error) {
+	rows, err := db.Query("SELECT conversation.id, conversation.title, conversation.creator, conversation.created, conversation.lastmodified FROM conversation JOIN conversationparticipant ON conversation.id=conversationparticipant.conversation WHERE conversationparticipant.user=? ORDER BY conversation.lastmodified DESC, conversation.id DESC", user)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	conversations := make([]Conversation, 0)
+	index := make(map[string]int)
+
+	for rows.Next() {
+		c := Conversation{Participants: make([]string, 0)}
+		var intID, created, modified int64
+		err = rows.Scan(&intID, &c.Title, &c.Creator, &created, &modified)
+		if err != nil {
+			return nil, err
+		}
+		c.ID = strconv.FormatInt(intID, 10)
+		c.Created = time.Unix(created, 0)
+		c.LastModified = time.Unix(modified, 0)
+		index[c.ID] = len(conversations)
+		conversations = append(conversations, c)
+	}
+	rows.Close()
+
+	rows, err = db.Query("SELECT conversation, user FROM conversationparticipant WHERE conversation IN (SELECT conversation FROM conversationparticipant WHERE user=?) ORDER BY user ASC", user)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var intID int64
+		var participant string
+		err = rows.Scan(&intID, &participant)
+		if err != nil {
+			return nil, err
+		}
+		i, ok := index[strconv.FormatInt(intID, 10)]
+		if !ok {
+			continue
+		}
+		conversations[i].Participants = append(conversations[i].Participants, participant)
+	}
+	return conversations, nil
+}
+
+// IsConversationParticipant returns whether the user currently participates in the conversation.
+func IsConversationParticipant(ID, user string) (bool, error) {
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM conversationparticipant WHERE conversation=? AND user=?", intID, user).Scan(&count)
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return count != 0, nil
+}
+
+// LeaveConversation removes the user from the conversation.
+// If no participants are left, the conversation and all messages are removed.
+// It returns whether the conversation was removed.
+func LeaveConversation(ID, user string) (bool, error) {
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer tx.Rollback()
+
+	r, err := tx.Exec("DELETE FROM conversationparticipant WHERE conversation=? AND user=?", intID, user)
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+
+	if count != 1 {
+		return false, errors.New(fmt.Sprintln("Delete count is", count))
+	}
+
+	var remaining int
+	err = tx.QueryRow("SELECT COUNT(*) FROM conversationparticipant WHERE conversation=?", intID).Scan(&remaining)
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	if remaining == 0 {
+		_, err = tx.Exec("DELETE FROM message WHERE conversation=?", intID)
+		if err != nil {
+			return false, errors.New(fmt.Sprintln("Database error:", err))
+		}
+
+		_, err = tx.Exec("DELETE FROM conversation WHERE id=?", intID)
+		if err != nil {
+			return false, errors.New(fmt.Sprintln("Database error:", err))
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return remaining == 0, nil
+}
+
+// ConversationModifyTime sets the modification time of a conversation to the current time.
+func ConversationModifyTime(ID string) error {
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	_, err = db.Exec("UPDATE conversation SET lastmodified=? WHERE id=?", time.Now().Unix(), intID)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return nil
+}
+
+// AddMessage adds a new message to a conversation.
+// The modification time of the conversation is set to the current time and the conversation is marked as read for the sender.
+// It returns the ID of the message.
+func AddMessage(conversation, sender, content string) (string, error) {
+	intID, err := strconv.ParseInt(conversation, 10, 64)
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer tx.Rollback()
+
+	date := time.Now().Unix()
+	r, err := tx.Exec("INSERT INTO message (conversation, sender, content, time) VALUES (?, ?, ?, ?)", intID, sender, content, date)
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	id, err := r.LastInsertId()
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database id error:", err))
+	}
+
+	_, err = tx.Exec("UPDATE conversation SET lastmodified=? WHERE id=?", date, intID)
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	_, err = tx.Exec("UPDATE conversationparticipant SET lastread=? WHERE conversation=? AND user=?", date, intID, sender)
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	return strconv.FormatInt(id, 10), nil
+}
+
+// GetMessages returns all messages of a conversation, starting with the oldest one.
+func GetMessages(conversation string) ([]Message, error) {
+	intID, err := strconv.ParseInt(conversation, 10, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	rows, err := db.Query("SELECT id, conversation, sender, content, time FROM message WHERE conversation=? ORDER BY time ASC, id ASC", intID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	return scanMessages(rows.Next, rows.Scan)
+}
+
+// GetMessagesByUser returns all messages sent by the user.
+func GetMessagesByUser(user string) ([]Message, error) {
+	rows, err := db.Query("SELECT id, conversation, sender, content, time FROM message WHERE sender=? ORDER BY time ASC, id ASC", user)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	return scanMessages(rows.Next, rows.Scan)
+}
+
+// scanMessages reads all messages of a query.
+func scanMessages(next func() bool, scan func(dest ...any) error) ([]Message, error) {
+	messages := make([]Message, 0)
+	for next() {
+		m := Message{}
+		var intID, conversationID, date int64
+		err := scan(&intID, &conversationID, &m.Sender, &m.Content, &date)
+		if err != nil {
+			return nil, err
+		}
+		m.ID = strconv.FormatInt(intID, 10)
+		m.ConversationID = strconv.FormatInt(conversationID, 10)
+		m.Time = time.Unix(date, 0)
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// SetConversationRead sets the time the user has last read the conversation.
+func SetConversationRead(ID, user string, t time.Time) error {
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	_, err = db.Exec("UPDATE conversationparticipant SET lastread=? WHERE conversation=? AND user=?", t.Unix(), intID, user)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return nil
+}
+
+// GetConversationReadTimes returns the times the user has last read all conversations the user participates in.
+func GetConversationReadTimes(user string) ([]ConversationReadTime, error) {
+	rows, err := db.Query("SELECT conversation, user, lastread FROM conversationparticipant WHERE user=?", user)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	times := make([]ConversationReadTime, 0)
+	for rows.Next() {
+		t := ConversationReadTime{}
+		var intID, lastRead int64
+		err = rows.Scan(&intID, &t.User, &lastRead)
+		if err != nil {
+			return nil, err
+		}
+		t.Conversation = strconv.FormatInt(intID, 10)
+		t.LastRead = time.Unix(lastRead, 0)
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// CountUnreadConversations returns the number of conversations with changes the user has not read yet.
+func CountUnreadConversations(user string) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM conversationparticipant JOIN conversation ON conversation.id=conversationparticipant.conversation WHERE conversationparticipant.user=? AND conversation.lastmodified>conversationparticipant.lastread", user).Scan(&count)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return count, nil
+}
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021 Marcus Soll
+// Copyright 2020,2021,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -16,10 +16,15 @@
 package database
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"math"
+	"slices"
 	"strconv"
 	"time"
+
+	"github.com/Top-Ranger/discussiongo/broadcast"
 )
 
 // GetPosts returns all posts of a topic from the database.
@@ -119,9 +124,200 @@ func GetPostsByUser(user string) ([]Post, error) {
 	return posts, nil
 }
 
+// GetLatestPosts returns the most recent posts of all topics, starting with the newest one.
+func GetLatestPosts(limit int) ([]Post, error) {
+	rows, err := db.Query("SELECT * FROM post ORDER BY time DESC, id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make([]Post, 0, limit)
+
+	for rows.Next() {
+		p := Post{}
+		var timeInt int64
+		var topicInt int64
+		var intID int64
+		err = rows.Scan(&intID, &p.Content, &p.Poster, &timeInt, &topicInt)
+		if err != nil {
+			return nil, err
+		}
+		p.ID = strconv.FormatInt(intID, 10)
+		p.TopicID = strconv.FormatInt(topicInt, 10)
+		p.Time = time.Unix(timeInt, 0)
+		posts = append(posts, p)
+	}
+	return posts, nil
+}
+
+// GetPostsAfter returns up to limit posts of a topic which are ordered after the post at the given time and ID, oldest post first.
+// Posts are ordered by time and ID, which allows keyset pagination of the topic timeline.
+// Use the zero time and ID "0" to start with the first post of the topic.
+func GetPostsAfter(topicID string, t time.Time, postID string, limit int) ([]Post, error) {
+	topicIntID, err := strconv.ParseInt(topicID, 10, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+	postIntID, err := strconv.ParseInt(postID, 10, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	rows, err := db.Query("SELECT * FROM post WHERE topic=? AND (time>? OR (time=? AND id>?)) ORDER BY time ASC, id ASC LIMIT ?", topicIntID, t.Unix(), t.Unix(), postIntID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make([]Post, 0, limit)
+
+	for rows.Next() {
+		p := Post{}
+		var timeInt int64
+		var topicInt int64
+		var intID int64
+		err = rows.Scan(&intID, &p.Content, &p.Poster, &timeInt, &topicInt)
+		if err != nil {
+			return nil, err
+		}
+		p.ID = strconv.FormatInt(intID, 10)
+		p.TopicID = strconv.FormatInt(topicInt, 10)
+		p.Time = time.Unix(timeInt, 0)
+		posts = append(posts, p)
+	}
+	return posts, nil
+}
+
+// GetPostsBefore returns up to limit posts of a topic which are ordered before the post at the given time and ID, oldest post first.
+// These are the posts directly preceding the given post.
+func GetPostsBefore(topicID string, t time.Time, postID string, limit int) ([]Post, error) {
+	topicIntID, err := strconv.ParseInt(topicID, 10, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+	postIntID, err := strconv.ParseInt(postID, 10, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	rows, err := db.Query("SELECT * FROM post WHERE topic=? AND (time<? OR (time=? AND id<?)) ORDER BY time DESC, id DESC LIMIT ?", topicIntID, t.Unix(), t.Unix(), postIntID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make([]Post, 0, limit)
+
+	for rows.Next() {
+		p := Post{}
+		var timeInt int64
+		var topicInt int64
+		var intID int64
+		err = rows.Scan(&intID, &p.Content, &p.Poster, &timeInt, &topicInt)
+		if err != nil {
+			return nil, err
+		}
+		p.ID = strconv.FormatInt(intID, 10)
+		p.TopicID = strconv.FormatInt(topicInt, 10)
+		p.Time = time.Unix(timeInt, 0)
+		posts = append(posts, p)
+	}
+	slices.Reverse(posts)
+	return posts, nil
+}
+
+// GetPostAtPosition returns the post at the given position (starting with 0) of a topic.
+// Posts are ordered by time and ID.
+func GetPostAtPosition(topicID string, position int) (Post, error) {
+	topicIntID, err := strconv.ParseInt(topicID, 10, 64)
+	if err != nil {
+		return Post{}, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	p := Post{}
+	var timeInt int64
+	var topicInt int64
+	var intID int64
+	err = db.QueryRow("SELECT * FROM post WHERE topic=? ORDER BY time ASC, id ASC LIMIT 1 OFFSET ?", topicIntID, position).Scan(&intID, &p.Content, &p.Poster, &timeInt, &topicInt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Post{}, errors.New("No such post")
+	}
+	if err != nil {
+		return Post{}, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	p.ID = strconv.FormatInt(intID, 10)
+	p.TopicID = strconv.FormatInt(topicInt, 10)
+	p.Time = time.Unix(timeInt, 0)
+	return p, nil
+}
+
+// GetFirstPostAfter returns the oldest post of a topic which was created after t.
+// The returned bool is false if no such post exists.
+func GetFirstPostAfter(topicID string, t time.Time) (Post, bool, error) {
+	// No post can have a higher ID, so all posts created at t are skipped
+	posts, err := GetPostsAfter(topicID, t, strconv.FormatInt(math.MaxInt64, 10), 1)
+	if err != nil {
+		return Post{}, false, err
+	}
+	if len(posts) == 0 {
+		return Post{}, false, nil
+	}
+	return posts[0], true, nil
+}
+
+// CountPosts returns the number of posts of a topic.
+func CountPosts(topicID string) (int, error) {
+	topicIntID, err := strconv.ParseInt(topicID, 10, 64)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM post WHERE topic=?", topicIntID).Scan(&count)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return count, nil
+}
+
+// CountPostsBefore returns the number of posts of a topic which are ordered before the post at the given time and ID.
+func CountPostsBefore(topicID string, t time.Time, postID string) (int, error) {
+	topicIntID, err := strconv.ParseInt(topicID, 10, 64)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+	postIntID, err := strconv.ParseInt(postID, 10, 64)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM post WHERE topic=? AND (time<? OR (time=? AND id<?))", topicIntID, t.Unix(), t.Unix(), postIntID).Scan(&count)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return count, nil
+}
+
+// CountPostsUntil returns the number of posts of a topic which were created at or before t.
+func CountPostsUntil(topicID string, t time.Time) (int, error) {
+	topicIntID, err := strconv.ParseInt(topicID, 10, 64)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM post WHERE topic=? AND time<=?", topicIntID, t.Unix()).Scan(&count)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return count, nil
+}
+
 // AddPost saves a post to the database.
 func AddPost(topicID, user, content string) (string, error) {
-	defer SetLastUpdateTopicPost()
+	defer SetLastUpdate(topicID)
 	topicIntID, err := strconv.ParseInt(topicID, 10, 64)
 	if err != nil {
 		return "", errors.New(fmt.Sprintln("Can not convert ID:", err))
@@ -138,12 +334,14 @@ func AddPost(topicID, user, content string) (string, error) {
 		return "", errors.New(fmt.Sprintln("Database id error:", err))
 	}
 
+	broadcast.Publish(broadcast.Message{Type: broadcast.PostCreated, Topic: topicID, ID: strconv.FormatInt(id, 10)})
 	return strconv.FormatInt(id, 10), nil
 }
 
 // DeletePost removes a post completely from the database. This action can not be undone.
+// All revisions of the post are removed as well.
 func DeletePost(topicID, ID string) error {
-	defer SetLastUpdateTopicPost()
+	defer SetLastUpdate(topicID)
 	topicIntID, err := strconv.ParseInt(topicID, 10, 64)
 	if err != nil {
 		return errors.New(fmt.Sprintln("Can not convert ID:", err))
@@ -153,7 +351,18 @@ func DeletePost(topicID, ID string) error {
 		return errors.New(fmt.Sprintln("Can not convert ID:", err))
 	}
 
-	r, err := db.Exec("DELETE FROM post WHERE id=? AND topic=?", postIntID, topicIntID)
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	r, err := tx.Exec("DELETE FROM post WHERE id=? AND topic=?", postIntID, topicIntID)
 	if err != nil {
 		return errors.New(fmt.Sprintln("Database error:", err))
 	}
@@ -164,7 +373,213 @@ func DeletePost(topicID, ID string) error {
 	}
 
 	if count != 1 {
-		return errors.New(fmt.Sprintln("Delete count is", count))
+		err = errors.New(fmt.Sprintln("Delete count is", count))
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM postrevision WHERE post=?", postIntID)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	broadcast.Publish(broadcast.Message{Type: broadcast.PostDeleted, Topic: topicID, ID: ID})
+	return nil
+}
+
+// EditPost replaces the content of a post.
+// The old content is kept as a revision of the post. Editing a post does not change its creation time.
+// If the content is unchanged, no revision is created.
+func EditPost(topicID, ID, content string) error {
+	defer SetLastUpdate(topicID)
+	topicIntID, err := strconv.ParseInt(topicID, 10, 64)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+	postIntID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rows, err := tx.Query("SELECT content FROM post WHERE id=? AND topic=?", postIntID, topicIntID)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	var oldContent string
+	if !rows.Next() {
+		rows.Close()
+		err = errors.New("No such post")
+		return err
 	}
+	err = rows.Scan(&oldContent)
+	rows.Close()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	if oldContent == content {
+		err = tx.Commit()
+		if err != nil {
+			return errors.New(fmt.Sprintln("Database error:", err))
+		}
+		return nil
+	}
+
+	_, err = tx.Exec("INSERT INTO postrevision (post, content, time) VALUES (?, ?, ?)", postIntID, oldContent, time.Now().Unix())
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	_, err = tx.Exec("UPDATE post SET content=? WHERE id=?", content, postIntID)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	broadcast.Publish(broadcast.Message{Type: broadcast.PostEdited, Topic: topicID, ID: ID})
 	return nil
 }
+
+// GetPostRevisions returns all revisions of a post, oldest revision first.
+// The current content of the post is not included.
+func GetPostRevisions(ID string) ([]PostRevision, error) {
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	rows, err := db.Query("SELECT id, post, content, time FROM postrevision WHERE post=? ORDER BY time ASC, id ASC", intID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]PostRevision, 0)
+
+	for rows.Next() {
+		r := PostRevision{}
+		var timeInt int64
+		var postInt int64
+		var intID int64
+		err = rows.Scan(&intID, &postInt, &r.Content, &timeInt)
+		if err != nil {
+			return nil, err
+		}
+		r.ID = strconv.FormatInt(intID, 10)
+		r.PostID = strconv.FormatInt(postInt, 10)
+		r.Replaced = time.Unix(timeInt, 0)
+		revisions = append(revisions, r)
+	}
+	return revisions, nil
+}
+
+// GetPostRevisionsOfTopic returns the revisions of all posts of a topic, oldest revision first.
+func GetPostRevisionsOfTopic(topicID string) ([]PostRevision, error) {
+	topicIntID, err := strconv.ParseInt(topicID, 10, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	rows, err := db.Query("SELECT postrevision.id, postrevision.post, postrevision.content, postrevision.time FROM postrevision INNER JOIN post ON postrevision.post=post.id WHERE post.topic=? ORDER BY postrevision.time ASC, postrevision.id ASC", topicIntID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]PostRevision, 0)
+
+	for rows.Next() {
+		r := PostRevision{}
+		var timeInt int64
+		var postInt int64
+		var intID int64
+		err = rows.Scan(&intID, &postInt, &r.Content, &timeInt)
+		if err != nil {
+			return nil, err
+		}
+		r.ID = strconv.FormatInt(intID, 10)
+		r.PostID = strconv.FormatInt(postInt, 10)
+		r.Replaced = time.Unix(timeInt, 0)
+		revisions = append(revisions, r)
+	}
+	return revisions, nil
+}
+
+// GetPostRevisionsOfPostsBetween returns the revisions of all posts of a topic which were created between from and to (both inclusive), oldest revision first.
+func GetPostRevisionsOfPostsBetween(topicID string, from, to time.Time) ([]PostRevision, error) {
+	topicIntID, err := strconv.ParseInt(topicID, 10, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	rows, err := db.Query("SELECT postrevision.id, postrevision.post, postrevision.content, postrevision.time FROM postrevision INNER JOIN post ON postrevision.post=post.id WHERE post.topic=? AND post.time>=? AND post.time<=? ORDER BY postrevision.time ASC, postrevision.id ASC", topicIntID, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]PostRevision, 0)
+
+	for rows.Next() {
+		r := PostRevision{}
+		var timeInt int64
+		var postInt int64
+		var intID int64
+		err = rows.Scan(&intID, &postInt, &r.Content, &timeInt)
+		if err != nil {
+			return nil, err
+		}
+		r.ID = strconv.FormatInt(intID, 10)
+		r.PostID = strconv.FormatInt(postInt, 10)
+		r.Replaced = time.Unix(timeInt, 0)
+		revisions = append(revisions, r)
+	}
+	return revisions, nil
+}
+
+// GetPostRevisionsByUser returns the revisions of all posts of a user, oldest revision first.
+func GetPostRevisionsByUser(user string) ([]PostRevision, error) {
+	rows, err := db.Query("SELECT postrevision.id, postrevision.post, postrevision.content, postrevision.time FROM postrevision INNER JOIN post ON postrevision.post=post.id WHERE post.poster=? ORDER BY postrevision.time ASC, postrevision.id ASC", user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]PostRevision, 0)
+
+	for rows.Next() {
+		r := PostRevision{}
+		var timeInt int64
+		var postInt int64
+		var intID int64
+		err = rows.Scan(&intID, &postInt, &r.Content, &timeInt)
+		if err != nil {
+			return nil, err
+		}
+		r.ID = strconv.FormatInt(intID, 10)
+		r.PostID = strconv.FormatInt(postInt, 10)
+		r.Replaced = time.Unix(timeInt, 0)
+		revisions = append(revisions, r)
+	}
+	return revisions, nil
+}
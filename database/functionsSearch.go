@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxSearchTerms is the maximum number of terms used for a single search.
+const maxSearchTerms = 10
+
+// SearchTerms splits a user provided query into search terms.
+// All characters with a special meaning in one of the full text query languages are removed.
+// The result can be used with SearchTopics, SearchPosts as well as files.SearchFiles.
+func SearchTerms(query string) []string {
+	terms := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, f := range strings.Fields(query) {
+		f = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, f)
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		terms = append(terms, f)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// SearchTopics returns up to limit topics whose name contains all terms.
+// The topics are sorted by relevance.
+func SearchTopics(terms []string, limit int) ([]Topic, error) {
+	if len(terms) == 0 {
+		return []Topic{}, nil
+	}
+
+	rows, err := db.Query(searchTopicsQuery, searchMatchExpression(terms), limit)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	topics := make([]Topic, 0)
+
+	for rows.Next() {
+		t := Topic{}
+		var createdInt, lastModifiedInt int64
+		var intID int64
+		var category int64
+		err = rows.Scan(&intID, &t.Name, &t.Creator, &createdInt, &lastModifiedInt, &t.Closed, &t.Pinned, &category)
+		if err != nil {
+			return nil, err
+		}
+		t.ID = strconv.FormatInt(intID, 10)
+		t.Category = strconv.FormatInt(category, 10)
+		t.Created = time.Unix(createdInt, 0)
+		t.LastModified = time.Unix(lastModifiedInt, 0)
+		topics = append(topics, t)
+	}
+	return topics, nil
+}
+
+// SearchPosts returns up to limit posts containing all terms.
+// The posts are sorted by relevance.
+func SearchPosts(terms []string, limit int) ([]Post, error) {
+	if len(terms) == 0 {
+		return []Post{}, nil
+	}
+
+	rows, err := db.Query(searchPostsQuery, searchMatchExpression(terms), limit)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	posts := make([]Post, 0)
+
+	for rows.Next() {
+		p := Post{}
+		var timeInt int64
+		var topicInt int64
+		var intID int64
+		err = rows.Scan(&intID, &p.Content, &p.Poster, &timeInt, &topicInt)
+		if err != nil {
+			return nil, err
+		}
+		p.ID = strconv.FormatInt(intID, 10)
+		p.TopicID = strconv.FormatInt(topicInt, 10)
+		p.Time = time.Unix(timeInt, 0)
+		posts = append(posts, p)
+	}
+	return posts, nil
+}
+
+// RebuildSearchIndex rebuilds the full text index of topics and posts.
+// This is only needed for existing databases or if the index is suspected to be inconsistent.
+func RebuildSearchIndex() error {
+	for _, q := range rebuildSearchIndexQueries {
+		_, err := db.Exec(q)
+		if err != nil {
+			return errors.New(fmt.Sprintln("Database error:", err))
+		}
+	}
+	return nil
+}
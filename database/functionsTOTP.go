@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// hashRecoveryCode returns the representation of a recovery code stored in the database.
+// Recovery codes are random, so a fast hash is sufficient.
+// Case, spaces and dashes are ignored.
+func hashRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(code))
+	h := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(h[:])
+}
+
+// GetTOTPSecret returns the TOTP secret of a user.
+// An empty string is returned if the user has not enabled two-factor authentication.
+// Returns an error if the user does not exist.
+func GetTOTPSecret(user string) (string, error) {
+	rows, err := db.Query("SELECT totpsecret FROM user WHERE name=?", user)
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	var secret string
+	if rows.Next() {
+		err = rows.Scan(&secret)
+		if err != nil {
+			return "", errors.New(fmt.Sprintln("Database error:", err))
+		}
+	} else {
+		return "", errors.New("User does not exist")
+	}
+	return secret, nil
+}
+
+// EnableTOTP sets the TOTP secret of a user and replaces all recovery codes.
+// Only the hashes of the recovery codes are stored.
+func EnableTOTP(user, secret string, recoveryCodes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	r, err := tx.Exec("UPDATE user SET totpsecret=?, totplaststep=? WHERE name=?", secret, 0, user)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database count error:", err))
+	}
+
+	if count != 1 {
+		err = errors.New("User does not exist")
+		return err
+	}
+
+	err = replaceRecoveryCodes(tx, user, recoveryCodes)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return nil
+}
+
+// DisableTOTP removes the TOTP secret as well as all recovery codes of a user.
+func DisableTOTP(user string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec("UPDATE user SET totpsecret=?, totplaststep=? WHERE name=?", "", 0, user)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	_, err = tx.Exec("DELETE FROM recoverycode WHERE user=?", user)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return nil
+}
+
+// SetRecoveryCodes replaces all recovery codes of a user.
+// Only the hashes of the recovery codes are stored.
+func SetRecoveryCodes(user string, recoveryCodes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	err = replaceRecoveryCodes(tx, user, recoveryCodes)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, user string, recoveryCodes []string) error {
+	_, err := tx.Exec("DELETE FROM recoverycode WHERE user=?", user)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	for i := range recoveryCodes {
+		_, err = tx.Exec("INSERT INTO recoverycode (user, code) VALUES (?, ?)", user, hashRecoveryCode(recoveryCodes[i]))
+		if err != nil {
+			return errors.New(fmt.Sprintln("Database error:", err))
+		}
+	}
+	return nil
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes of a user.
+func CountRecoveryCodes(user string) (int, error) {
+	rows, err := db.Query("SELECT COUNT(*) FROM recoverycode WHERE user=?", user)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	var count int
+	if rows.Next() {
+		err = rows.Scan(&count)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintln("Database error:", err))
+		}
+	}
+	return count, nil
+}
+
+// UseTOTPStep marks a TOTP time step as used by a user.
+// It returns false if the time step (or a later one) was already used, which means the code must be rejected to prevent replay attacks.
+func UseTOTPStep(user string, step int64) (bool, error) {
+	r, err := db.Exec("UPDATE user SET totplaststep=? WHERE name=? AND totplaststep<?", step, user, step)
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+	return count == 1, nil
+}
+
+// UseRecoveryCode checks whether a recovery code is valid for a user and removes it.
+// Each recovery code can only be used once.
+func UseRecoveryCode(user, code string) (bool, error) {
+	r, err := db.Exec("DELETE FROM recoverycode WHERE user=? AND code=?", user, hashRecoveryCode(code))
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+	return count == 1, nil
+}
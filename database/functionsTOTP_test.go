@@ -0,0 +1,65 @@
+//go:build sqlite
+
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+
+	"github.com/Top-Ranger/discussiongo/accesstimes"
+)
+
+func TestUseTOTPStep(t *testing.T) {
+	t.Chdir(t.TempDir())
+	err := accesstimes.InitDB("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = InitDB("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = AddUser("user", "password", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		user string
+		step int64
+		want bool
+	}{
+		{name: "first use", user: "user", step: 41152263, want: true},
+		{name: "same step", user: "user", step: 41152263, want: false},
+		{name: "earlier step", user: "user", step: 41152262, want: false},
+		{name: "later step", user: "user", step: 41152264, want: true},
+		{name: "reuse of later step", user: "user", step: 41152264, want: false},
+		{name: "unknown user", user: "unknown", step: 41152265, want: false},
+	}
+
+	// The cases depend on each other and must run in order
+	for _, tc := range tests {
+		got, err := UseTOTPStep(tc.user, tc.step)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: got %t, expected %t", tc.name, got, tc.want)
+		}
+	}
+}
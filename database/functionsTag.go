@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Top-Ranger/discussiongo/broadcast"
+)
+
+// GetTags returns all tags which are used by at least one topic, sorted by name.
+func GetTags() ([]Tag, error) {
+	rows, err := db.Query("SELECT tag.name, COUNT(topictag.topic) FROM tag JOIN topictag ON tag.id=topictag.tag GROUP BY tag.id, tag.name ORDER BY tag.name ASC")
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	tags := make([]Tag, 0)
+
+	for rows.Next() {
+		t := Tag{}
+		err = rows.Scan(&t.Name, &t.Topics)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, nil
+}
+
+// GetTopicTags returns the tags of a topic sorted by name.
+func GetTopicTags(ID string) ([]string, error) {
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	rows, err := db.Query("SELECT tag.name FROM topictag JOIN tag ON tag.id=topictag.tag WHERE topictag.topic=? ORDER BY tag.name ASC", intID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	tags := make([]string, 0)
+
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, name)
+	}
+	return tags, nil
+}
+
+// GetTagsOfTopics returns the tags of the given topics, indexed by the ID of the topic.
+// Topics without tags are not included.
+func GetTagsOfTopics(IDs []string) (map[string][]string, error) {
+	tags := make(map[string][]string)
+	if len(IDs) == 0 {
+		return tags, nil
+	}
+
+	placeholder := make([]string, len(IDs))
+	args := make([]interface{}, len(IDs))
+	for i := range IDs {
+		intID, err := strconv.ParseInt(IDs[i], 10, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintln("Can not convert ID:", err))
+		}
+		placeholder[i] = "?"
+		args[i] = intID
+	}
+
+	rows, err := db.Query(fmt.Sprintf("SELECT topictag.topic, tag.name FROM topictag JOIN tag ON tag.id=topictag.tag WHERE topictag.topic IN (%s) ORDER BY tag.name ASC", strings.Join(placeholder, ",")), args...)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var intID int64
+		var name string
+		err = rows.Scan(&intID, &name)
+		if err != nil {
+			return nil, err
+		}
+		ID := strconv.FormatInt(intID, 10)
+		tags[ID] = append(tags[ID], name)
+	}
+	return tags, nil
+}
+
+// SetTopicTags replaces all tags of a topic.
+// Tags which do not exist are created, tags no longer used by any topic are removed.
+// It does not affect the modification time.
+func SetTopicTags(ID string, tags []string) error {
+	defer SetLastUpdate(ID)
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM topictag WHERE topic=?", intID)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	for _, name := range tags {
+		var tagID int64
+		err = tx.QueryRow("SELECT id FROM tag WHERE name=?", name).Scan(&tagID)
+		if errors.Is(err, sql.ErrNoRows) {
+			var r sql.Result
+			r, err = tx.Exec("INSERT INTO tag (name) VALUES (?)", name)
+			if err != nil {
+				return errors.New(fmt.Sprintln("Database error:", err))
+			}
+			tagID, err = r.LastInsertId()
+			if err != nil {
+				return errors.New(fmt.Sprintln("Database id error:", err))
+			}
+		} else if err != nil {
+			return errors.New(fmt.Sprintln("Database error:", err))
+		}
+
+		_, err = tx.Exec("INSERT INTO topictag (topic, tag) VALUES (?, ?)", intID, tagID)
+		if err != nil {
+			return errors.New(fmt.Sprintln("Database error:", err))
+		}
+	}
+
+	err = deleteUnusedTags(tx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	broadcast.Publish(broadcast.Message{Type: broadcast.TopicTagged, Topic: ID, ID: ID})
+	return nil
+}
+
+// RenameTag renames a tag for all topics.
+// If a tag with the new name already exists, both tags are merged.
+// It returns whether the tags were merged.
+func RenameTag(oldName, newName string) (bool, error) {
+	defer SetLastUpdateAll()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer tx.Rollback()
+
+	var oldID int64
+	err = tx.QueryRow("SELECT id FROM tag WHERE name=?", oldName).Scan(&oldID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, errors.New("Tag does not exist")
+	} else if err != nil {
+		return false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	merged := true
+	var newID int64
+	err = tx.QueryRow("SELECT id FROM tag WHERE name=?", newName).Scan(&newID)
+	if errors.Is(err, sql.ErrNoRows) {
+		merged = false
+	} else if err != nil {
+		return false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	if merged && oldID == newID {
+		// Nothing to do
+		return false, nil
+	}
+
+	if !merged {
+		_, err = tx.Exec("UPDATE tag SET name=? WHERE id=?", newName, oldID)
+		if err != nil {
+			return false, errors.New(fmt.Sprintln("Database error:", err))
+		}
+	} else {
+		// Topics having both tags must only keep one of them
+		_, err = tx.Exec("INSERT INTO topictag (topic, tag) SELECT topic, ? FROM topictag WHERE tag=? AND topic NOT IN (SELECT topic FROM topictag WHERE tag=?)", newID, oldID, newID)
+		if err != nil {
+			return false, errors.New(fmt.Sprintln("Database error:", err))
+		}
+
+		_, err = tx.Exec("DELETE FROM topictag WHERE tag=?", oldID)
+		if err != nil {
+			return false, errors.New(fmt.Sprintln("Database error:", err))
+		}
+
+		_, err = tx.Exec("DELETE FROM tag WHERE id=?", oldID)
+		if err != nil {
+			return false, errors.New(fmt.Sprintln("Database error:", err))
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	broadcast.Publish(broadcast.Message{Type: broadcast.TagChange, ID: newName})
+	return merged, nil
+}
+
+// deleteUnusedTags removes all tags which are not used by any topic.
+func deleteUnusedTags(tx *sql.Tx) error {
+	_, err := tx.Exec("DELETE FROM tag WHERE id NOT IN (SELECT tag FROM topictag)")
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return nil
+}
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021 Marcus Soll
+// Copyright 2020,2021,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -19,7 +19,10 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/Top-Ranger/discussiongo/broadcast"
 )
 
 // GetTopics returns all topics currently saved in the database.
@@ -37,11 +40,193 @@ func GetTopics() ([]Topic, error) {
 		var created int64
 		var modified int64
 		var intID int64
-		err = rows.Scan(&intID, &t.Name, &t.Creator, &created, &modified, &t.Closed, &t.Pinned)
+		var category int64
+		err = rows.Scan(&intID, &t.Name, &t.Creator, &created, &modified, &t.Closed, &t.Pinned, &category)
+		if err != nil {
+			return nil, err
+		}
+		t.ID = strconv.FormatInt(intID, 10)
+		t.Category = strconv.FormatInt(category, 10)
+		t.Created = time.Unix(created, 0)
+		t.LastModified = time.Unix(modified, 0)
+		topics = append(topics, t)
+	}
+	return topics, nil
+}
+
+// unreadCondition matches topics modified after the last access of a user. The user is its only argument.
+var unreadCondition = fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s AS access WHERE access.name=? AND access.topic=topic.id AND access.time>=topic.lastmodified)", accessTimesTable)
+
+// topicFilterQuery returns the WHERE clause (including the keyword, if needed) and the arguments for the given filter.
+func topicFilterQuery(f TopicFilter) (string, []interface{}, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if f.Category != "" {
+		intID, err := strconv.ParseInt(f.Category, 10, 64)
+		if err != nil {
+			return "", nil, errors.New(fmt.Sprintln("Can not convert ID:", err))
+		}
+		if f.Category == NoCategory {
+			conditions = append(conditions, "(category=? OR category NOT IN (SELECT id FROM category))")
+		} else {
+			conditions = append(conditions, "category=?")
+		}
+		args = append(args, intID)
+	}
+	if f.Tag != "" {
+		conditions = append(conditions, "id IN (SELECT topictag.topic FROM topictag JOIN tag ON tag.id=topictag.tag WHERE tag.name=?)")
+		args = append(args, f.Tag)
+	}
+	if f.Creator != "" {
+		conditions = append(conditions, "creator=?")
+		args = append(args, f.Creator)
+	}
+	if f.Participant != "" {
+		conditions = append(conditions, "(creator=? OR id IN (SELECT topic FROM post WHERE poster=?))")
+		args = append(args, f.Participant, f.Participant)
+	}
+	if f.UnreadBy != "" {
+		conditions = append(conditions, unreadCondition)
+		args = append(args, f.UnreadBy)
+	}
+	if f.OnlyOpen {
+		conditions = append(conditions, "closed=0")
+	}
+	if f.OnlyClosed {
+		conditions = append(conditions, "closed<>0")
+	}
+	if f.OnlyPinned {
+		conditions = append(conditions, "pinned<>0")
+	}
+
+	if len(conditions) == 0 {
+		return "", args, nil
+	}
+	return fmt.Sprintf(" WHERE %s", strings.Join(conditions, " AND ")), args, nil
+}
+
+// GetTopicsFiltered returns at most limit topics matching the filter, skipping the first offset topics.
+// Pinned topics are always returned before other topics.
+func GetTopicsFiltered(f TopicFilter, sort TopicSort, offset, limit int) ([]Topic, error) {
+	where, args, err := topicFilterQuery(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var order string
+	switch sort {
+	case SortLastModified:
+		order = "lastmodified DESC, id DESC"
+	case SortCreated:
+		order = "created DESC, id DESC"
+	case SortCreator:
+		order = "creator ASC, lastmodified DESC, id DESC"
+	case SortPosts:
+		order = "(SELECT COUNT(*) FROM post WHERE post.topic=topic.id) DESC, lastmodified DESC, id DESC"
+	default:
+		return nil, fmt.Errorf("unknown sort order %d", sort)
+	}
+
+	args = append(args, limit, offset)
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM topic%s ORDER BY pinned DESC, %s LIMIT ? OFFSET ?", where, order), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := make([]Topic, 0, limit)
+
+	for rows.Next() {
+		t := Topic{}
+		var created int64
+		var modified int64
+		var intID int64
+		var category int64
+		err = rows.Scan(&intID, &t.Name, &t.Creator, &created, &modified, &t.Closed, &t.Pinned, &category)
+		if err != nil {
+			return nil, err
+		}
+		t.ID = strconv.FormatInt(intID, 10)
+		t.Category = strconv.FormatInt(category, 10)
+		t.Created = time.Unix(created, 0)
+		t.LastModified = time.Unix(modified, 0)
+		topics = append(topics, t)
+	}
+	return topics, nil
+}
+
+// CountTopics returns the number of topics matching the filter.
+func CountTopics(f TopicFilter) (int, error) {
+	where, args, err := topicFilterQuery(f)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM topic%s", where), args...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetCategoryStates returns the number of topics and the last modification for each category containing topics.
+// If unreadBy is not empty, the topics modified after the last access of that user are counted as unread.
+// This is considerably cheaper than loading the topics.
+func GetCategoryStates(unreadBy string) ([]CategoryState, error) {
+	unread := "0"
+	args := make([]interface{}, 0, 1)
+	if unreadBy != "" {
+		unread = fmt.Sprintf("SUM(CASE WHEN %s THEN 1 ELSE 0 END)", unreadCondition)
+		args = append(args, unreadBy)
+	}
+
+	rows, err := db.Query(fmt.Sprintf("SELECT category, COUNT(*), MAX(lastmodified), %s FROM topic GROUP BY category", unread), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make([]CategoryState, 0)
+
+	for rows.Next() {
+		var category int64
+		var modified int64
+		s := CategoryState{}
+		err = rows.Scan(&category, &s.Topics, &modified, &s.Unread)
+		if err != nil {
+			return nil, err
+		}
+		s.Category = strconv.FormatInt(category, 10)
+		s.LastModified = time.Unix(modified, 0)
+		states = append(states, s)
+	}
+	return states, nil
+}
+
+// GetLatestTopics returns the most recently created topics, starting with the newest one.
+func GetLatestTopics(limit int) ([]Topic, error) {
+	rows, err := db.Query("SELECT * FROM topic ORDER BY created DESC, id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := make([]Topic, 0, limit)
+
+	for rows.Next() {
+		t := Topic{}
+		var created int64
+		var modified int64
+		var intID int64
+		var category int64
+		err = rows.Scan(&intID, &t.Name, &t.Creator, &created, &modified, &t.Closed, &t.Pinned, &category)
 		if err != nil {
 			return nil, err
 		}
 		t.ID = strconv.FormatInt(intID, 10)
+		t.Category = strconv.FormatInt(category, 10)
 		t.Created = time.Unix(created, 0)
 		t.LastModified = time.Unix(modified, 0)
 		topics = append(topics, t)
@@ -72,11 +257,13 @@ func GetTopicsByUser(user string) ([]Topic, error) {
 		var created int64
 		var modified int64
 		var intID int64
-		err = rows.Scan(&intID, &t.Name, &t.Creator, &created, &modified, &t.Closed, &t.Pinned)
+		var category int64
+		err = rows.Scan(&intID, &t.Name, &t.Creator, &created, &modified, &t.Closed, &t.Pinned, &category)
 		if err != nil {
 			return nil, err
 		}
 		t.ID = strconv.FormatInt(intID, 10)
+		t.Category = strconv.FormatInt(category, 10)
 		t.Created = time.Unix(created, 0)
 		t.LastModified = time.Unix(modified, 0)
 		topics = append(topics, t)
@@ -102,11 +289,13 @@ func GetTopic(ID string) (Topic, error) {
 		var created int64
 		var modified int64
 		var intID int64
-		err = rows.Scan(&intID, &t.Name, &t.Creator, &created, &modified, &t.Closed, &t.Pinned)
+		var category int64
+		err = rows.Scan(&intID, &t.Name, &t.Creator, &created, &modified, &t.Closed, &t.Pinned, &category)
 		if err != nil {
 			return t, err
 		}
 		t.ID = strconv.FormatInt(intID, 10)
+		t.Category = strconv.FormatInt(category, 10)
 		t.Created = time.Unix(created, 0)
 		t.LastModified = time.Unix(modified, 0)
 	} else {
@@ -117,10 +306,16 @@ func GetTopic(ID string) (Topic, error) {
 
 // AddTopic adds a new topic to the database.
 // The modification time is set to the current time.
-func AddTopic(name, creator string) (string, error) {
-	defer SetLastUpdateTopicPost()
+// category must be NoCategory or the ID of an existing category.
+func AddTopic(name, creator, category string) (string, error) {
+	defer SetLastUpdateTopicList()
+	categoryIntID, err := strconv.ParseInt(category, 10, 64)
+	if err != nil {
+		return "", errors.New(fmt.Sprintln("Can not convert category ID:", err))
+	}
+
 	date := time.Now().Unix()
-	r, err := db.Exec("INSERT INTO topic (name, creator, created, lastmodified) VALUES (?, ?, ?, ?)", name, creator, date, date)
+	r, err := db.Exec("INSERT INTO topic (name, creator, created, lastmodified, category) VALUES (?, ?, ?, ?, ?)", name, creator, date, date, categoryIntID)
 	if err != nil {
 		return "", errors.New(fmt.Sprintln("Database error:", err))
 	}
@@ -130,12 +325,13 @@ func AddTopic(name, creator string) (string, error) {
 		return "", errors.New(fmt.Sprintln("Database id error:", err))
 	}
 
+	broadcast.Publish(broadcast.Message{Type: broadcast.TopicCreated, Topic: strconv.FormatInt(id, 10), ID: strconv.FormatInt(id, 10)})
 	return strconv.FormatInt(id, 10), nil
 }
 
 // TopicModifyTime sets the modification time of a topic to the current time.
 func TopicModifyTime(ID string) error {
-	defer SetLastUpdateTopicPost()
+	defer SetLastUpdate(ID)
 	intID, err := strconv.ParseInt(ID, 10, 64)
 	if err != nil {
 		return errors.New(fmt.Sprintln("Can not convert ID:", err))
@@ -160,7 +356,7 @@ func TopicModifyTime(ID string) error {
 // TopicSetClosed sets the 'closed' property of a topic to the given value.
 // It does not affect the modification time.
 func TopicSetClosed(ID string, closed bool) error {
-	defer SetLastUpdateTopicPost()
+	defer SetLastUpdate(ID)
 	intID, err := strconv.ParseInt(ID, 10, 64)
 	if err != nil {
 		return errors.New(fmt.Sprintln("Can not convert ID:", err))
@@ -179,13 +375,18 @@ func TopicSetClosed(ID string, closed bool) error {
 	if count != 1 {
 		return errors.New(fmt.Sprintln("Delete count is", count))
 	}
+	if closed {
+		broadcast.Publish(broadcast.Message{Type: broadcast.TopicClosed, Topic: ID, ID: ID})
+	} else {
+		broadcast.Publish(broadcast.Message{Type: broadcast.TopicOpened, Topic: ID, ID: ID})
+	}
 	return nil
 }
 
 // TopicSetPinned sets the 'pinned' property of a topic to the given value.
 // It does not affect the modification time.
 func TopicSetPinned(ID string, pinned bool) error {
-	defer SetLastUpdateTopicPost()
+	defer SetLastUpdate(ID)
 	intID, err := strconv.ParseInt(ID, 10, 64)
 	if err != nil {
 		return errors.New(fmt.Sprintln("Can not convert ID:", err))
@@ -204,13 +405,18 @@ func TopicSetPinned(ID string, pinned bool) error {
 	if count != 1 {
 		return errors.New(fmt.Sprintln("Delete count is", count))
 	}
+	if pinned {
+		broadcast.Publish(broadcast.Message{Type: broadcast.TopicPinned, Topic: ID, ID: ID})
+	} else {
+		broadcast.Publish(broadcast.Message{Type: broadcast.TopicUnpinned, Topic: ID, ID: ID})
+	}
 	return nil
 }
 
-// DeleteTopic removes a topic and all associated posts from the database.
+// DeleteTopic removes a topic and all associated posts (including their revisions) from the database.
 // This action can not be undone.
 func DeleteTopic(ID string) error {
-	defer SetLastUpdateTopicPost()
+	defer removeLastUpdate(ID)
 	intID, err := strconv.ParseInt(ID, 10, 64)
 	if err != nil {
 		return errors.New(fmt.Sprintln("Can not convert ID:", err))
@@ -241,16 +447,33 @@ func DeleteTopic(ID string) error {
 		return errors.New(fmt.Sprintln("Delete count is", count))
 	}
 
+	_, err = tx.Exec("DELETE FROM postrevision WHERE post IN (SELECT id FROM post WHERE topic=?)", intID)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
 	_, err = tx.Exec("DELETE FROM post WHERE topic=?", intID)
 	if err != nil {
 		return errors.New(fmt.Sprintln("Database error:", err))
 	}
 
+	_, err = tx.Exec("DELETE FROM topictag WHERE topic=?", intID)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	err = deleteUnusedTags(tx)
+	if err != nil {
+		return err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return errors.New(fmt.Sprintln("Database error:", err))
 	}
 
+	broadcast.Publish(broadcast.Message{Type: broadcast.TopicDeleted, Topic: ID, ID: ID})
+
 	err = nil
 
 	return err
@@ -259,7 +482,7 @@ func DeleteTopic(ID string) error {
 // RenameTopic renames a topic.
 // It does affect the modification time.
 func RenameTopic(ID string, newName string) error {
-	defer SetLastUpdateTopicPost()
+	defer SetLastUpdate(ID)
 	intID, err := strconv.ParseInt(ID, 10, 64)
 	if err != nil {
 		return errors.New(fmt.Sprintln("Can not convert ID:", err))
@@ -278,5 +501,6 @@ func RenameTopic(ID string, newName string) error {
 	if count != 1 {
 		return errors.New(fmt.Sprintln("Update count is", count))
 	}
+	broadcast.Publish(broadcast.Message{Type: broadcast.TopicRenamed, Topic: ID, ID: ID})
 	return nil
 }
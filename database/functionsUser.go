@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020 Marcus Soll
+// Copyright 2020,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -83,7 +83,7 @@ func GetUser(user string) (User, error) {
 		return User{}, errors.New("User does not exist")
 	}
 
-	rows, err := db.Query("SELECT name, admin, comment, invitedby, invitationdirect, lastseen FROM user WHERE name=?", user)
+	rows, err := db.Query("SELECT name, admin, comment, invitedby, invitationdirect, lastseen, totpsecret<>'' FROM user WHERE name=?", user)
 	if err != nil {
 		return User{}, err
 	}
@@ -93,7 +93,7 @@ func GetUser(user string) (User, error) {
 
 	if rows.Next() {
 		var lastSeenInt int64
-		err = rows.Scan(&u.Name, &u.Admin, &u.Comment, &u.InvidedBy, &u.InvitationDirect, &lastSeenInt)
+		err = rows.Scan(&u.Name, &u.Admin, &u.Comment, &u.InvidedBy, &u.InvitationDirect, &lastSeenInt, &u.TOTPEnabled)
 		if err != nil {
 			return User{}, err
 		}
@@ -107,7 +107,7 @@ func GetUser(user string) (User, error) {
 
 // GetAllUser returns all user currently known to the database.
 func GetAllUser() ([]User, error) {
-	rows, err := db.Query("SELECT name, admin, comment, invitedby, invitationdirect, lastseen FROM user ORDER BY name ASC")
+	rows, err := db.Query("SELECT name, admin, comment, invitedby, invitationdirect, lastseen, totpsecret<>'' FROM user ORDER BY name ASC")
 	if err != nil {
 		return nil, err
 	}
@@ -118,7 +118,7 @@ func GetAllUser() ([]User, error) {
 	for rows.Next() {
 		var u User
 		var lastSeenInt int64
-		err = rows.Scan(&u.Name, &u.Admin, &u.Comment, &u.InvidedBy, &u.InvitationDirect, &lastSeenInt)
+		err = rows.Scan(&u.Name, &u.Admin, &u.Comment, &u.InvidedBy, &u.InvitationDirect, &lastSeenInt, &u.TOTPEnabled)
 		if err != nil {
 			return nil, err
 		}
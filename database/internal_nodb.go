@@ -1,7 +1,7 @@
 //go:build !sqlite && !mysql
 
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2021,2022 Marcus Soll
+// Copyright 2021,2022,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -19,6 +19,19 @@ package database
 
 import "errors"
 
+const (
+	searchTopicsQuery = ""
+	searchPostsQuery  = ""
+)
+
+const accessTimesTable = ""
+
+var rebuildSearchIndexQueries = []string{}
+
+func searchMatchExpression(terms []string) string {
+	return ""
+}
+
 // InitDB initialises the database.
 // Must be called before any other function.
 // This stub will return an error if no build tags are set.
@@ -1,7 +1,7 @@
 //go:build sqlite
 
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2022 Marcus Soll
+// Copyright 2020,2022,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -19,15 +19,66 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
-	_ "github.com/mattn/go-sqlite3" // Database driver
+	"github.com/mattn/go-sqlite3"
 )
 
+// sqliteDriver is a SQLite driver which attaches the database of the access times to every connection.
+// The access times are only read by this package, they are written by package accesstimes.
+const sqliteDriver = "sqlite3_database"
+
+// accessTimesTable is the table containing the access times of users.
+const accessTimesTable = "accesstimes.times"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			_, err := conn.Exec("ATTACH DATABASE 'file:./accesstimes.sqlite3?mode=ro' AS accesstimes", nil)
+			return err
+		},
+	})
+}
+
+const (
+	searchTopicsQuery = "SELECT topic.id, topic.name, topic.creator, topic.created, topic.lastmodified, topic.closed, topic.pinned, topic.category FROM topic_search INNER JOIN topic ON topic_search.rowid=topic.id WHERE topic_search MATCH ? ORDER BY topic_search.rank LIMIT ?"
+	searchPostsQuery  = "SELECT post.id, post.content, post.poster, post.time, post.topic FROM post_search INNER JOIN post ON post_search.rowid=post.id WHERE post_search MATCH ? ORDER BY post_search.rank LIMIT ?"
+)
+
+var rebuildSearchIndexQueries = []string{
+	"INSERT INTO topic_search(topic_search) VALUES('rebuild')",
+	"INSERT INTO post_search(post_search) VALUES('rebuild')",
+}
+
+// searchTables contains all statements needed to create the full text search.
+// The index is kept up to date by triggers.
+var searchTables = []string{
+	"CREATE VIRTUAL TABLE topic_search USING fts5(name, content='topic', content_rowid='id')",
+	"CREATE TRIGGER topic_search_insert AFTER INSERT ON topic BEGIN INSERT INTO topic_search(rowid, name) VALUES (new.id, new.name); END",
+	"CREATE TRIGGER topic_search_delete AFTER DELETE ON topic BEGIN INSERT INTO topic_search(topic_search, rowid, name) VALUES ('delete', old.id, old.name); END",
+	"CREATE TRIGGER topic_search_update AFTER UPDATE OF name ON topic BEGIN INSERT INTO topic_search(topic_search, rowid, name) VALUES ('delete', old.id, old.name); INSERT INTO topic_search(rowid, name) VALUES (new.id, new.name); END",
+	"CREATE VIRTUAL TABLE post_search USING fts5(content, content='post', content_rowid='id')",
+	"CREATE TRIGGER post_search_insert AFTER INSERT ON post BEGIN INSERT INTO post_search(rowid, content) VALUES (new.id, new.content); END",
+	"CREATE TRIGGER post_search_delete AFTER DELETE ON post BEGIN INSERT INTO post_search(post_search, rowid, content) VALUES ('delete', old.id, old.content); END",
+	"CREATE TRIGGER post_search_update AFTER UPDATE OF content ON post BEGIN INSERT INTO post_search(post_search, rowid, content) VALUES ('delete', old.id, old.content); INSERT INTO post_search(rowid, content) VALUES (new.id, new.content); END",
+}
+
+// searchMatchExpression returns a FTS5 query matching all terms as prefix.
+func searchMatchExpression(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i := range terms {
+		quoted[i] = fmt.Sprintf("\"%s\"*", strings.ReplaceAll(terms[i], "\"", "\"\""))
+	}
+	return strings.Join(quoted, " ")
+}
+
 // InitDB initialises the database.
 // Must be called before any other function.
 // SQLite will ignore all config.
+// The access times (see package accesstimes) must be initialised before.
 func InitDB(config string) error {
 	return connectToDB("./database.sqlite3")
 }
@@ -45,7 +96,7 @@ func connectToDB(path string) error {
 	}
 
 	// Open database
-	newDB, err := sql.Open("sqlite3", path)
+	newDB, err := sql.Open(sqliteDriver, path)
 	if err != nil {
 		return err
 	}
@@ -62,7 +113,7 @@ func connectToDB(path string) error {
 			return err
 		}
 
-		_, err = tx.Exec("INSERT INTO meta VALUES ('version', 5)")
+		_, err = tx.Exec("INSERT INTO meta VALUES ('version', 13)")
 		if err != nil {
 			return err
 		}
@@ -77,12 +128,22 @@ func connectToDB(path string) error {
 			return err
 		}
 
-		_, err = tx.Exec("CREATE TABLE user (name TEXT NOT NULL PRIMARY KEY, salt TEXT, encodedpasswort TEXT, admin BOOLEAN, comment TEXT DEFAULT '', invitedby TEXT DEFAULT '', invitationdirect BOOL DEFAULT 0, lastseen INTEGER DEFAULT 0)")
+		_, err = tx.Exec("CREATE TABLE user (name TEXT NOT NULL PRIMARY KEY, salt TEXT, encodedpasswort TEXT, admin BOOLEAN, comment TEXT DEFAULT '', invitedby TEXT DEFAULT '', invitationdirect BOOL DEFAULT 0, lastseen INTEGER DEFAULT 0, totpsecret TEXT DEFAULT '', totplaststep INTEGER DEFAULT 0)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE TABLE topic (id INTEGER PRIMARY KEY, name TEXT, creator TEXT, created INTEGER, lastmodified INTEGER, closed BOOL DEFAULT 0, pinned BOOL DEFAULT 0, category INTEGER DEFAULT 0)")
 		if err != nil {
 			return err
 		}
 
-		_, err = tx.Exec("CREATE TABLE topic (id INTEGER PRIMARY KEY, name TEXT, creator TEXT, created INTEGER, lastmodified INTEGER, closed BOOL DEFAULT 0, pinned BOOL DEFAULT 0)")
+		_, err = tx.Exec("CREATE INDEX idx_topic_category ON topic (category)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE TABLE category (id INTEGER PRIMARY KEY, name TEXT NOT NULL, description TEXT DEFAULT '', position INTEGER DEFAULT 0, adminonly BOOL DEFAULT 0)")
 		if err != nil {
 			return err
 		}
@@ -92,6 +153,56 @@ func connectToDB(path string) error {
 			return err
 		}
 
+		_, err = tx.Exec("CREATE INDEX idx_topic_created_desc ON topic (created DESC)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE INDEX idx_topic_creator ON topic (creator)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE TABLE tag (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE TABLE topictag (topic INTEGER NOT NULL, tag INTEGER NOT NULL, PRIMARY KEY(topic, tag), FOREIGN KEY(topic) REFERENCES topic(id) ON UPDATE CASCADE ON DELETE CASCADE, FOREIGN KEY(tag) REFERENCES tag(id) ON UPDATE CASCADE ON DELETE CASCADE)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE INDEX idx_topictag_tag ON topictag (tag)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE TABLE conversation (id INTEGER PRIMARY KEY, title TEXT, creator TEXT, created INTEGER, lastmodified INTEGER)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE TABLE conversationparticipant (conversation INTEGER NOT NULL, user TEXT NOT NULL, lastread INTEGER DEFAULT 0, PRIMARY KEY(conversation, user), FOREIGN KEY(conversation) REFERENCES conversation(id) ON UPDATE CASCADE ON DELETE CASCADE, FOREIGN KEY(user) REFERENCES user(name) ON UPDATE CASCADE ON DELETE CASCADE)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE INDEX idx_conversationparticipant_user ON conversationparticipant (user)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE TABLE message (id INTEGER PRIMARY KEY, conversation INTEGER NOT NULL, sender TEXT NOT NULL, content TEXT, time INTEGER, FOREIGN KEY(conversation) REFERENCES conversation(id) ON UPDATE CASCADE ON DELETE CASCADE)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE INDEX idx_message_conversation_time_asc ON message (conversation, time ASC)")
+		if err != nil {
+			return err
+		}
+
 		_, err = tx.Exec("CREATE TABLE post (id INTEGER PRIMARY KEY, content TEXT, poster TEXT, time INTEGER, topic INTEGER, FOREIGN KEY(topic) REFERENCES topic(id) ON UPDATE CASCADE ON DELETE CASCADE)")
 		if err != nil {
 			return err
@@ -102,11 +213,43 @@ func connectToDB(path string) error {
 			return err
 		}
 
+		_, err = tx.Exec("CREATE INDEX idx_post_poster ON post (poster)")
+		if err != nil {
+			return err
+		}
+
 		_, err = tx.Exec("CREATE TABLE invitations (id TEXT NOT NULL PRIMARY KEY, creator TEXT)")
 		if err != nil {
 			return err
 		}
 
+		_, err = tx.Exec("CREATE TABLE postrevision (id INTEGER PRIMARY KEY, post INTEGER, content TEXT, time INTEGER, FOREIGN KEY(post) REFERENCES post(id) ON UPDATE CASCADE ON DELETE CASCADE)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE INDEX idx_postrevision_post ON postrevision (post)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE TABLE lastupdate (topic INTEGER NOT NULL PRIMARY KEY, time INTEGER)")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("CREATE TABLE recoverycode (user TEXT NOT NULL, code TEXT NOT NULL, PRIMARY KEY(user, code), FOREIGN KEY(user) REFERENCES user(name) ON UPDATE CASCADE ON DELETE CASCADE)")
+		if err != nil {
+			return err
+		}
+
+		for _, q := range searchTables {
+			_, err = tx.Exec(q)
+			if err != nil {
+				return fmt.Errorf("can not create search index (SQLite builds require FTS5, build with -tags=\"sqlite sqlite_fts5\"): %w", err)
+			}
+		}
+
 		err = tx.Commit()
 		if err != nil {
 			return err
@@ -256,6 +399,280 @@ func connectToDB(path string) error {
 				return err
 			}
 
+			log.Println("Upgrade done")
+			fallthrough
+		case 5:
+			log.Println("Upgrade database 5 -> 6")
+
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE TABLE postrevision (id INTEGER PRIMARY KEY, post INTEGER, content TEXT, time INTEGER, FOREIGN KEY(post) REFERENCES post(id) ON UPDATE CASCADE ON DELETE CASCADE)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE INDEX idx_postrevision_post ON postrevision (post)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=6 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
+			log.Println("Upgrade done")
+			fallthrough
+		case 6:
+			log.Println("Upgrade database 6 -> 7")
+
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			for _, q := range searchTables {
+				_, err = tx.Exec(q)
+				if err != nil {
+					return fmt.Errorf("can not create search index (SQLite builds require FTS5, build with -tags=\"sqlite sqlite_fts5\"): %w", err)
+				}
+			}
+
+			for _, q := range rebuildSearchIndexQueries {
+				_, err = tx.Exec(q)
+				if err != nil {
+					return err
+				}
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=7 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
+			log.Println("Upgrade done")
+			fallthrough
+		case 7:
+			log.Println("Upgrade database 7 -> 8")
+
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE TABLE lastupdate (topic INTEGER NOT NULL PRIMARY KEY, time INTEGER)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=8 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
+			log.Println("Upgrade done")
+			fallthrough
+		case 8:
+			log.Println("Upgrade database 8 -> 9")
+
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("ALTER TABLE user ADD COLUMN totpsecret TEXT DEFAULT ''")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("ALTER TABLE user ADD COLUMN totplaststep INTEGER DEFAULT 0")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE TABLE recoverycode (user TEXT NOT NULL, code TEXT NOT NULL, PRIMARY KEY(user, code), FOREIGN KEY(user) REFERENCES user(name) ON UPDATE CASCADE ON DELETE CASCADE)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=9 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
+			log.Println("Upgrade done")
+			fallthrough
+		case 9:
+			log.Println("Upgrade database 9 -> 10")
+
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("ALTER TABLE topic ADD COLUMN category INTEGER DEFAULT 0")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE INDEX idx_topic_category ON topic (category)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE TABLE category (id INTEGER PRIMARY KEY, name TEXT NOT NULL, description TEXT DEFAULT '', position INTEGER DEFAULT 0, adminonly BOOL DEFAULT 0)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=10 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
+			log.Println("Upgrade done")
+			fallthrough
+		case 10:
+			log.Println("Upgrade database 10 -> 11")
+
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE TABLE tag (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE TABLE topictag (topic INTEGER NOT NULL, tag INTEGER NOT NULL, PRIMARY KEY(topic, tag), FOREIGN KEY(topic) REFERENCES topic(id) ON UPDATE CASCADE ON DELETE CASCADE, FOREIGN KEY(tag) REFERENCES tag(id) ON UPDATE CASCADE ON DELETE CASCADE)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE INDEX idx_topictag_tag ON topictag (tag)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=11 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
+			log.Println("Upgrade done")
+			fallthrough
+		case 11:
+			log.Println("Upgrade database 11 -> 12")
+
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE TABLE conversation (id INTEGER PRIMARY KEY, title TEXT, creator TEXT, created INTEGER, lastmodified INTEGER)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE TABLE conversationparticipant (conversation INTEGER NOT NULL, user TEXT NOT NULL, lastread INTEGER DEFAULT 0, PRIMARY KEY(conversation, user), FOREIGN KEY(conversation) REFERENCES conversation(id) ON UPDATE CASCADE ON DELETE CASCADE, FOREIGN KEY(user) REFERENCES user(name) ON UPDATE CASCADE ON DELETE CASCADE)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE INDEX idx_conversationparticipant_user ON conversationparticipant (user)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE TABLE message (id INTEGER PRIMARY KEY, conversation INTEGER NOT NULL, sender TEXT NOT NULL, content TEXT, time INTEGER, FOREIGN KEY(conversation) REFERENCES conversation(id) ON UPDATE CASCADE ON DELETE CASCADE)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE INDEX idx_message_conversation_time_asc ON message (conversation, time ASC)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=12 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
+			log.Println("Upgrade done")
+			fallthrough
+		case 12:
+			log.Println("Upgrade database 12 -> 13")
+
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE INDEX idx_topic_created_desc ON topic (created DESC)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE INDEX idx_topic_creator ON topic (creator)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE INDEX idx_post_poster ON post (poster)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=13 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
 			log.Println("Upgrade done")
 			fallthrough
 		default:
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021 Marcus Soll
+// Copyright 2020,2021,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -16,27 +16,98 @@
 package database
 
 import (
-	"sync"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
 	"time"
 )
 
-var (
-	lastUpdateTopicPost      int64 = time.Now().UnixNano()
-	lastUpdateTopicPostMutex       = sync.RWMutex{}
+// Pseudo topics used to store changes not belonging to a single topic.
+const (
+	lastUpdateTopicList = 0
+	lastUpdateAll       = -1
 )
 
-// SetLastUpdateTopicPost updates the time of the last update of any post/topic.
+// SetLastUpdate marks a topic (and with it the topic list) as changed.
 // It is internally called by the functions of the database package.
-// You only need to call it if you manually update a post (e.g. by adding data to the post which is stored in an other package).
-func SetLastUpdateTopicPost() {
-	lastUpdateTopicPostMutex.Lock()
-	defer lastUpdateTopicPostMutex.Unlock()
-	lastUpdateTopicPost = time.Now().UnixNano()
+// You only need to call it if you manually update a topic (e.g. by adding data to the topic which is stored in an other package).
+// The value is stored in the database so that all instances using the same database see the change.
+func SetLastUpdate(topicID string) {
+	intID, err := strconv.ParseInt(topicID, 10, 64)
+	if err != nil {
+		log.Println("Can not set last update:", err)
+		return
+	}
+	now := time.Now().UnixNano()
+	_, err = db.Exec("REPLACE INTO lastupdate (topic, time) VALUES (?, ?), (?, ?)", intID, now, lastUpdateTopicList, now)
+	if err != nil {
+		log.Println("Can not set last update:", err)
+	}
 }
 
-// GetLastUpdateTopicPost returns the time of the last update of any post/topic as an int64 in nano seconds.
-func GetLastUpdateTopicPost() int64 {
-	lastUpdateTopicPostMutex.RLock()
-	defer lastUpdateTopicPostMutex.RUnlock()
-	return lastUpdateTopicPost
+// SetLastUpdateTopicList marks only the topic list as changed.
+func SetLastUpdateTopicList() {
+	_, err := db.Exec("REPLACE INTO lastupdate (topic, time) VALUES (?, ?)", lastUpdateTopicList, time.Now().UnixNano())
+	if err != nil {
+		log.Println("Can not set last update:", err)
+	}
+}
+
+// SetLastUpdateAll marks all topics as well as the topic list as changed.
+// It should be used if a change affects an unknown number of topics (e.g. deleting a user).
+func SetLastUpdateAll() {
+	_, err := db.Exec("REPLACE INTO lastupdate (topic, time) VALUES (?, ?)", lastUpdateAll, time.Now().UnixNano())
+	if err != nil {
+		log.Println("Can not set last update:", err)
+	}
+}
+
+// removeLastUpdate marks the topic list as changed and removes the entry of a deleted topic.
+func removeLastUpdate(topicID string) {
+	SetLastUpdateTopicList()
+
+	intID, err := strconv.ParseInt(topicID, 10, 64)
+	if err != nil {
+		log.Println("Can not remove last update:", err)
+		return
+	}
+	_, err = db.Exec("DELETE FROM lastupdate WHERE topic=?", intID)
+	if err != nil {
+		log.Println("Can not remove last update:", err)
+	}
+}
+
+// GetLastUpdateTopic returns a value representing the last change of a topic.
+// The value only changes if the topic was changed. It should only be compared for equality.
+func GetLastUpdateTopic(topicID string) (int64, error) {
+	intID, err := strconv.ParseInt(topicID, 10, 64)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+	return getLastUpdate(intID)
+}
+
+// GetLastUpdateTopicList returns a value representing the last change of the topic list.
+// The value changes if any topic was changed. It should only be compared for equality.
+func GetLastUpdateTopicList() (int64, error) {
+	return getLastUpdate(lastUpdateTopicList)
+}
+
+func getLastUpdate(topic int64) (int64, error) {
+	rows, err := db.Query("SELECT MAX(time) FROM lastupdate WHERE topic=? OR topic=?", topic, lastUpdateAll)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	var t sql.NullInt64
+	if rows.Next() {
+		err = rows.Scan(&t)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintln("Database error:", err))
+		}
+	}
+	return t.Int64, nil
 }
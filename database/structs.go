@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020 Marcus Soll
+// Copyright 2020,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -18,7 +18,7 @@ package database
 import "time"
 
 // User represents a user in the database.
-// For security reasons, the password and the salt is not included.
+// For security reasons, the password, the salt and the TOTP secret are not included.
 type User struct {
 	Name             string
 	Admin            bool
@@ -26,9 +26,11 @@ type User struct {
 	InvidedBy        string
 	InvitationDirect bool
 	LastSeen         time.Time
+	TOTPEnabled      bool
 }
 
 // Topic represents a topic in the database.
+// Category is NoCategory if the topic does not belong to a category.
 type Topic struct {
 	ID           string
 	Name         string
@@ -37,6 +39,91 @@ type Topic struct {
 	LastModified time.Time
 	Closed       bool
 	Pinned       bool
+	Category     string
+}
+
+// CategoryState summarises the topics of a category.
+// Unread is the number of topics modified after the last access of the user given to GetCategoryStates.
+type CategoryState struct {
+	Category     string
+	Topics       int
+	Unread       int
+	LastModified time.Time
+}
+
+// TopicFilter restricts the topics returned by GetTopicsFiltered and CountTopics.
+// Fields with their zero value do not restrict the result.
+// If Category is NoCategory, topics with an unknown category are included.
+// Participant matches topics created by the user as well as topics containing a post of the user.
+// UnreadBy matches topics modified after the last access of the user.
+type TopicFilter struct {
+	Category    string
+	Tag         string
+	Creator     string
+	Participant string
+	UnreadBy    string
+	OnlyOpen    bool
+	OnlyClosed  bool
+	OnlyPinned  bool
+}
+
+// TopicSort represents the order of topics returned by GetTopicsFiltered.
+// Pinned topics are always sorted before other topics.
+type TopicSort int
+
+const (
+	// SortLastModified sorts topics by modification time, starting with the newest one.
+	SortLastModified TopicSort = iota
+	// SortCreated sorts topics by creation time, starting with the newest one.
+	SortCreated
+	// SortCreator sorts topics by creator. Topics of the same creator are sorted by modification time.
+	SortCreator
+	// SortPosts sorts topics by number of posts, starting with the largest one.
+	SortPosts
+)
+
+// Category represents a category of topics in the database.
+// Categories are sorted by Position. If AdminOnly is set, only administrators can create topics and posts in the category.
+type Category struct {
+	ID          string
+	Name        string
+	Description string
+	Position    int
+	AdminOnly   bool
+}
+
+// Tag represents a tag in the database.
+// Topics is the number of topics with the tag.
+type Tag struct {
+	Name   string
+	Topics int
+}
+
+// Conversation represents a private conversation between users in the database.
+// Participants contains all users currently taking part in the conversation.
+type Conversation struct {
+	ID           string
+	Title        string
+	Creator      string
+	Created      time.Time
+	LastModified time.Time
+	Participants []string
+}
+
+// ConversationReadTime represents the time a participant has last read a conversation.
+type ConversationReadTime struct {
+	Conversation string
+	User         string
+	LastRead     time.Time
+}
+
+// Message represents a message of a private conversation in the database.
+type Message struct {
+	ID             string
+	ConversationID string
+	Sender         string
+	Content        string
+	Time           time.Time
 }
 
 // Post represents a post in the database.
@@ -47,3 +134,12 @@ type Post struct {
 	Content string
 	Time    time.Time
 }
+
+// PostRevision represents an earlier version of a post in the database.
+// A new revision is created every time a post is edited.
+type PostRevision struct {
+	ID       string
+	PostID   string
+	Content  string
+	Replaced time.Time
+}
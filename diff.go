@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"html"
+	"html/template"
+	"strings"
+)
+
+const (
+	diffEqual = iota
+	diffAdded
+	diffRemoved
+)
+
+// diffMaxLines is the maximal number of lines of each text compared by diffLines.
+// The comparison needs time and memory proportional to the product of the number of lines of both texts.
+const diffMaxLines = 500
+
+type diffLine struct {
+	Type int
+	Text string
+}
+
+// diffLines returns a line based diff between old and new.
+// The diff is calculated using the longest common subsequence of both texts.
+// If one of the texts has more than diffMaxLines lines, the whole old text is returned as removed and the whole new text as added.
+func diffLines(old, new string) []diffLine {
+	a := strings.Split(strings.ReplaceAll(old, "\r\n", "\n"), "\n")
+	b := strings.Split(strings.ReplaceAll(new, "\r\n", "\n"), "\n")
+
+	if len(a) > diffMaxLines || len(b) > diffMaxLines {
+		result := make([]diffLine, 0, len(a)+len(b))
+		for i := range a {
+			result = append(result, diffLine{Type: diffRemoved, Text: a[i]})
+		}
+		for i := range b {
+			result = append(result, diffLine{Type: diffAdded, Text: b[i]})
+		}
+		return result
+	}
+
+	// lcs[i][j] holds the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	result := make([]diffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, diffLine{Type: diffEqual, Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, diffLine{Type: diffRemoved, Text: a[i]})
+			i++
+		default:
+			result = append(result, diffLine{Type: diffAdded, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		result = append(result, diffLine{Type: diffRemoved, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		result = append(result, diffLine{Type: diffAdded, Text: b[j]})
+	}
+	return result
+}
+
+// diffToHTML renders the diff between old and new as HTML.
+// All content is escaped, so the result is safe to embed into a page.
+func diffToHTML(old, new string) template.HTML {
+	var sb strings.Builder
+	sb.WriteString("<pre class=\"diff\">")
+	for _, l := range diffLines(old, new) {
+		switch l.Type {
+		case diffAdded:
+			sb.WriteString("<span class=\"diff-add\">+ ")
+		case diffRemoved:
+			sb.WriteString("<span class=\"diff-del\">- ")
+		default:
+			sb.WriteString("<span>  ")
+		}
+		sb.WriteString(html.EscapeString(l.Text))
+		sb.WriteString("</span>\n")
+	}
+	sb.WriteString("</pre>")
+	return template.HTML(sb.String())
+}
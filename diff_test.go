@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Top-Ranger/discussiongo/database"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want []diffLine
+	}{
+		{name: "equal", old: "a\nb", new: "a\nb", want: []diffLine{{diffEqual, "a"}, {diffEqual, "b"}}},
+		{name: "added", old: "a\nc", new: "a\nb\nc", want: []diffLine{{diffEqual, "a"}, {diffAdded, "b"}, {diffEqual, "c"}}},
+		{name: "removed", old: "a\nb\nc", new: "a\nc", want: []diffLine{{diffEqual, "a"}, {diffRemoved, "b"}, {diffEqual, "c"}}},
+		{name: "changed", old: "a\nb", new: "a\nc", want: []diffLine{{diffEqual, "a"}, {diffRemoved, "b"}, {diffAdded, "c"}}},
+		{name: "windows line endings", old: "a\r\nb", new: "a\nb", want: []diffLine{{diffEqual, "a"}, {diffEqual, "b"}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := diffLines(tc.old, tc.new)
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("got %v, expected %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesMaxLines(t *testing.T) {
+	old := strings.Repeat("a\n", diffMaxLines) + "b"
+	new := strings.Repeat("a\n", diffMaxLines) + "c"
+
+	start := time.Now()
+	got := diffLines(old, new)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("diff took %s", d)
+	}
+
+	if len(got) != 2*(diffMaxLines+1) {
+		t.Fatalf("got %d lines", len(got))
+	}
+	for i := range got {
+		want := diffRemoved
+		if i > diffMaxLines {
+			want = diffAdded
+		}
+		if got[i].Type != want {
+			t.Fatalf("line %d: got type %d, expected %d", i, got[i].Type, want)
+		}
+	}
+	if got[diffMaxLines].Text != "b" || got[len(got)-1].Text != "c" {
+		t.Fatalf("texts not kept: %v", got[diffMaxLines])
+	}
+}
+
+func TestDiffToHTML(t *testing.T) {
+	got := string(diffToHTML("<b>\nsame", "<i>\nsame"))
+	want := "<pre class=\"diff\"><span class=\"diff-del\">- &lt;b&gt;</span>\n<span class=\"diff-add\">+ &lt;i&gt;</span>\n<span>  same</span>\n</pre>"
+	if got != want {
+		t.Fatalf("got %s, expected %s", got, want)
+	}
+}
+
+func TestPostHistory(t *testing.T) {
+	date := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	revisions := []database.PostRevision{
+		{Content: "first", Replaced: date},
+		{Content: "second", Replaced: date.Add(time.Hour)},
+	}
+
+	history := postHistory(revisions, "third")
+	if len(history) != 2 {
+		t.Fatalf("got %d changes", len(history))
+	}
+	// Newest change first
+	if history[0].Date != date.Add(time.Hour).Format(time.RFC822) || !strings.Contains(string(history[0].Diff), "- second") || !strings.Contains(string(history[0].Diff), "+ third") {
+		t.Errorf("unexpected change %+v", history[0])
+	}
+	if history[1].Date != date.Format(time.RFC822) || !strings.Contains(string(history[1].Diff), "- first") || !strings.Contains(string(history[1].Diff), "+ second") {
+		t.Errorf("unexpected change %+v", history[1])
+	}
+}
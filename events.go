@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2021,2022,2024 Marcus Soll
+// Copyright 2021,2022,2024,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -52,6 +52,12 @@ const (
 	EventUserRegisteredByAdmin
 	EventSetAdministrator
 	EventRemoveAdministrator
+	EventPostEdited
+	EventTOTPResetByAdmin
+	EventTopicMoved
+	EventTopicTagsChanged
+	EventFilesAdminDeleted
+	EventUploadRejected
 )
 
 type eventData struct {
@@ -126,6 +132,8 @@ func deleteEventHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	database.SetLastUpdate(event.Topic)
+
 	err = database.ModifyLastSeen(user)
 	if err != nil {
 		log.Println("Can not modify last seen:", err)
@@ -185,6 +193,47 @@ func eventToEventData(e events.Event) eventData {
 		ed.Description = template.HTML(fmt.Sprintf("%s <i>%s</i>", html.EscapeString(tl.EventSetAdministrator), html.EscapeString(e.AffectedUser)))
 	case EventRemoveAdministrator:
 		ed.Description = template.HTML(fmt.Sprintf("%s <i>%s</i>", html.EscapeString(tl.EventRemoveAdministrator), html.EscapeString(e.AffectedUser)))
+	case EventPostEdited:
+		if e.Data != nil {
+			ed.Description = template.HTML(fmt.Sprintf("<a class=\"metadata\" href=\"%s%s\">%s</a>", html.EscapeString(config.ServerPath), html.EscapeString(postPath(e.Topic, string(e.Data))), html.EscapeString(tl.EventPostEdited)))
+		} else {
+			ed.Description = template.HTML(template.HTMLEscapeString(tl.EventPostEdited))
+		}
+	case EventTOTPResetByAdmin:
+		ed.Description = template.HTML(fmt.Sprintf("%s <i>%s</i>", html.EscapeString(tl.EventTOTPReset), html.EscapeString(e.AffectedUser)))
+	case EventTopicMoved:
+		if e.Data != nil {
+			split := strings.Split(string(e.Data), "﷐")
+			if len(split) == 2 {
+				for i := range split {
+					if split[i] == "" {
+						split[i] = tl.NoCategory
+					}
+				}
+				ed.Description = template.HTML(fmt.Sprintf("%s (%s 🡆 <i>%s</i>)", html.EscapeString(tl.EventTopicMoved), html.EscapeString(split[0]), html.EscapeString(split[1])))
+			}
+		}
+	case EventTopicTagsChanged:
+		if e.Data != nil {
+			split := strings.Split(string(e.Data), "﷐")
+			if len(split) == 2 {
+				for i := range split {
+					if split[i] == "" {
+						split[i] = tl.NoTags
+					}
+				}
+				ed.Description = template.HTML(fmt.Sprintf("%s (%s 🡆 <i>%s</i>)", html.EscapeString(tl.EventTopicTagsChanged), html.EscapeString(split[0]), html.EscapeString(split[1])))
+			}
+		}
+	case EventFilesAdminDeleted:
+		ed.Description = template.HTML(fmt.Sprintf("%s (<i>%s</i>)", html.EscapeString(tl.EventFilesAdminDeleted), html.EscapeString(string(e.Data))))
+	case EventUploadRejected:
+		if e.Data != nil {
+			split := strings.Split(string(e.Data), "﷐")
+			if len(split) == 2 {
+				ed.Description = template.HTML(fmt.Sprintf("%s (<i>%s</i>: %s)", html.EscapeString(tl.EventUploadRejected), html.EscapeString(split[0]), html.EscapeString(split[1])))
+			}
+		}
 	default:
 		ed.Description = template.HTML(template.HTMLEscapeString(tl.UnknownEvent))
 	}
@@ -199,6 +248,24 @@ func eventCreateTopicRenameData(old, new string) []byte {
 	return []byte(s)
 }
 
+// eventCreateTopicMovedData uses the same format as eventCreateTopicRenameData.
+// Topics without category are represented by an empty name.
+func eventCreateTopicMovedData(old, new string) []byte {
+	return eventCreateTopicRenameData(old, new)
+}
+
+// eventCreateTopicTagsData uses the same format as eventCreateTopicRenameData.
+// The tags are joined by eventTagSeparator.
+func eventCreateTopicTagsData(old, new []string) []byte {
+	return eventCreateTopicRenameData(strings.Join(old, eventTagSeparator), strings.Join(new, eventTagSeparator))
+}
+
+// eventCreateUploadRejectedData uses the same format as eventCreateTopicRenameData.
+// It contains the name of the file and the reason for the rejection.
+func eventCreateUploadRejectedData(name, reason string) []byte {
+	return eventCreateTopicRenameData(name, reason)
+}
+
 func startAdminDeleteLoop(duration string) error {
 	d, err := time.ParseDuration(duration)
 	if err != nil {
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2021 Marcus Soll
+// Copyright 2021,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -19,8 +19,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"time"
+
+	"github.com/Top-Ranger/discussiongo/broadcast"
 )
 
 const AnoymousUser = "SYSTEM: DELETED USER"
@@ -48,6 +51,8 @@ func DeleteTopicEvents(topicid string) (int64, error) {
 	if err != nil {
 		return 0, errors.New(fmt.Sprintln("Database count error:", err))
 	}
+
+	broadcast.Publish(broadcast.Message{Type: broadcast.ContentRemoved, Topic: topicid})
 	return count, nil
 }
 
@@ -75,6 +80,7 @@ func AnonymiseUserEvents(user string) (int64, error) {
 	}
 	count += c
 
+	broadcast.Publish(broadcast.Message{Type: broadcast.ContentRemoved})
 	return count, nil
 }
 
@@ -90,6 +96,7 @@ func DeleteEvent(ID string) error {
 		return errors.New(fmt.Sprintln("Database error:", err))
 	}
 
+	broadcast.Publish(broadcast.Message{Type: broadcast.EventDeleted, ID: ID})
 	return nil
 }
 
@@ -122,6 +129,7 @@ func SaveEvent(e Event) (string, error) {
 		return "", errors.New(fmt.Sprintln("Database id error:", err))
 	}
 
+	broadcast.Publish(broadcast.Message{Type: broadcast.EventCreated, Topic: e.Topic, ID: strconv.FormatInt(id, 10)})
 	return strconv.FormatInt(id, 10), nil
 }
 
@@ -142,11 +150,19 @@ func SaveEvents(e []Event) error {
 		}
 	}()
 
+	messages := make([]broadcast.Message, 0, len(e))
+
 	for i := range e {
-		_, err := tx.Exec("INSERT INTO events (type, user, topic, date, data, affecteduser) VALUES (?, ?, ?, ?, ?, ?)", e[i].Type, e[i].User, e[i].Topic, e[i].Date.Unix(), e[i].Data, e[i].AffectedUser)
+		r, err := tx.Exec("INSERT INTO events (type, user, topic, date, data, affecteduser) VALUES (?, ?, ?, ?, ?, ?)", e[i].Type, e[i].User, e[i].Topic, e[i].Date.Unix(), e[i].Data, e[i].AffectedUser)
 		if err != nil {
 			return errors.New(fmt.Sprintln("Database error:", err))
 		}
+
+		id, err := r.LastInsertId()
+		if err != nil {
+			return errors.New(fmt.Sprintln("Database id error:", err))
+		}
+		messages = append(messages, broadcast.Message{Type: broadcast.EventCreated, Topic: e[i].Topic, ID: strconv.FormatInt(id, 10)})
 	}
 
 	err = tx.Commit()
@@ -156,6 +172,10 @@ func SaveEvents(e []Event) error {
 
 	successful = true
 
+	for i := range messages {
+		broadcast.Publish(messages[i])
+	}
+
 	return nil
 }
 
@@ -221,6 +241,41 @@ func GetEventsOfTopic(topicid string) ([]Event, error) {
 	return events, nil
 }
 
+// GetEventsOfTopicBetween returns all events associated by a topic which happened at or after from and before to.
+// If to is the zero time, there is no upper bound.
+func GetEventsOfTopicBetween(topicid string, from, to time.Time) ([]Event, error) {
+	events := make([]Event, 0)
+
+	upper := int64(math.MaxInt64)
+	if !to.IsZero() {
+		upper = to.Unix()
+	}
+
+	rows, err := db.Query("SELECT * FROM events WHERE topic=? AND date>=? AND date<?", topicid, from.Unix(), upper)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := Event{}
+		var s sql.NullString
+		var intDate int64
+		var intID int64
+		err = rows.Scan(&intID, &e.Type, &e.User, &e.Topic, &intDate, &e.Data, &s)
+		if err != nil {
+			return events, err
+		}
+		e.ID = strconv.FormatInt(intID, 10)
+		e.Date = time.Unix(intDate, 0)
+		if s.Valid {
+			e.AffectedUser = s.String
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
 // GetEventsOfUser returns all events associated by a user.
 func GetEventsOfUser(user string) ([]Event, error) {
 	events := make([]Event, 0)
@@ -0,0 +1,493 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Top-Ranger/auth/data"
+	"github.com/Top-Ranger/discussiongo/authtoken"
+	"github.com/Top-Ranger/discussiongo/database"
+)
+
+// feedLength is the maximal number of entries of a feed.
+const feedLength = 50
+
+// feedKeyParameter is the query parameter containing the feed token.
+const feedKeyParameter = "key"
+
+// Formats of feeds.
+const (
+	feedFormatAtom = "atom"
+	feedFormatRSS  = "rss"
+)
+
+// feedEntry is the format independent representation of a single entry of a feed.
+type feedEntry struct {
+	Title     string
+	Link      string
+	Author    string
+	Published time.Time
+	Updated   time.Time
+	Content   string
+}
+
+// feed is the format independent representation of a feed.
+type feed struct {
+	Title   string
+	Link    string
+	Self    string
+	Updated time.Time
+	Entries []feedEntry
+}
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Base      string      `xml:"xml:base,attr,omitempty"`
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title     string       `xml:"title"`
+	ID        string       `xml:"id"`
+	Link      atomLink     `xml:"link"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Author    atomAuthor   `xml:"author"`
+	Content   *atomContent `xml:"content,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Generator     string    `xml:"generator"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Creator     string  `xml:"dc:creator"`
+	Description string  `xml:"description,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Body        string `xml:",chardata"`
+}
+
+func init() {
+	http.HandleFunc("/feed/", feedHandleFunc)
+	http.HandleFunc("/newFeedToken.html", newFeedTokenHandleFunc)
+	http.HandleFunc("/revokeFeedToken.html", revokeFeedTokenHandleFunc)
+}
+
+// absoluteURL returns the absolute URL of a path inside the forum.
+func absoluteURL(path string) string {
+	return fmt.Sprintf("%s%s%s", config.ServerPrefix, config.ServerPath, path)
+}
+
+// feedTitle returns the title of a feed with the name of the forum prepended.
+func feedTitle(title string) string {
+	if config.ForumName == "" {
+		return fmt.Sprintf("DiscussionGo! - %s", title)
+	}
+	return fmt.Sprintf("%s - %s", config.ForumName, title)
+}
+
+// feedNotModified returns whether the client already has the current version of the feed.
+// If-None-Match takes precedence over If-Modified-Since.
+func feedNotModified(r *http.Request, etag string, modified time.Time) bool {
+	if v := r.Header.Get("If-None-Match"); v != "" {
+		for _, e := range strings.Split(v, ",") {
+			e = strings.TrimPrefix(strings.TrimSpace(e), "W/")
+			if e == etag || e == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if v := r.Header.Get("If-Modified-Since"); v != "" && !modified.IsZero() {
+		t, err := http.ParseTime(v)
+		if err != nil {
+			return false
+		}
+		return !modified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// marshalAtom converts a feed to Atom.
+func marshalAtom(f feed) ([]byte, error) {
+	a := atomFeed{
+		Base:      absoluteURL("/"),
+		Title:     f.Title,
+		ID:        f.Self,
+		Updated:   f.Updated.UTC().Format(time.RFC3339),
+		Links:     []atomLink{{Rel: "self", Type: "application/atom+xml", Href: f.Self}, {Rel: "alternate", Type: "text/html", Href: f.Link}},
+		Generator: "DiscussionGo!",
+		Entries:   make([]atomEntry, 0, len(f.Entries)),
+	}
+	for i := range f.Entries {
+		e := atomEntry{
+			Title:     f.Entries[i].Title,
+			ID:        f.Entries[i].Link,
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: f.Entries[i].Link},
+			Published: f.Entries[i].Published.UTC().Format(time.RFC3339),
+			Updated:   f.Entries[i].Updated.UTC().Format(time.RFC3339),
+			Author:    atomAuthor{Name: f.Entries[i].Author},
+		}
+		if f.Entries[i].Content != "" {
+			e.Content = &atomContent{Type: "html", Body: f.Entries[i].Content}
+		}
+		a.Entries = append(a.Entries, e)
+	}
+
+	b, err := xml.MarshalIndent(a, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// marshalRSS converts a feed to RSS 2.0.
+func marshalRSS(f feed) ([]byte, error) {
+	rss := rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Title,
+			Self:          atomLink{Rel: "self", Type: "application/rss+xml", Href: f.Self},
+			LastBuildDate: f.Updated.Format(time.RFC1123Z),
+			Generator:     "DiscussionGo!",
+			Items:         make([]rssItem, 0, len(f.Entries)),
+		},
+	}
+	for i := range f.Entries {
+		rss.Channel.Items = append(rss.Channel.Items, rssItem{
+			Title:       f.Entries[i].Title,
+			Link:        f.Entries[i].Link,
+			GUID:        rssGUID{IsPermaLink: true, Body: f.Entries[i].Link},
+			PubDate:     f.Entries[i].Published.Format(time.RFC1123Z),
+			Creator:     f.Entries[i].Author,
+			Description: f.Entries[i].Content,
+		})
+	}
+
+	b, err := xml.MarshalIndent(rss, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// postFeedEntry converts a post into a feed entry.
+func postFeedEntry(p database.Post, topicName string) feedEntry {
+	return feedEntry{
+		Title:     topicName,
+		Link:      absoluteURL(postPath(p.TopicID, p.ID)),
+		Author:    p.Poster,
+		Published: p.Time,
+		Updated:   p.Time,
+		Content:   string(formatPost(p.Content)),
+	}
+}
+
+// feedHandleFunc serves all feeds.
+// The following feeds are available, each as Atom ('.atom') and RSS ('.rss'):
+// 'topics' (the latest topics), 'posts' (the latest posts of all topics) and 'topic' (the latest posts of the topic given by 'id').
+// If the forum can not be read without an account, a valid feed token must be given as parameter 'key'.
+func feedHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, _ := TestUser(r, rw)
+
+	if !canRead(loggedIn) {
+		_, ok := authtoken.CheckFeedToken(r.URL.Query().Get(feedKeyParameter))
+		if !ok {
+			rw.WriteHeader(http.StatusUnauthorized)
+			rw.Write([]byte(t.TokenInvalid))
+			return
+		}
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/feed/")
+	kind, format, _ := strings.Cut(name, ".")
+	if format != feedFormatAtom && format != feedFormatRSS {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	var topic database.Topic
+	var lastUpdate int64
+	var err error
+	switch kind {
+	case "topics", "posts":
+		lastUpdate, err = database.GetLastUpdateTopicList()
+	case "topic":
+		topic, err = database.GetTopic(id)
+		if err != nil {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		lastUpdate, err = database.GetLastUpdateTopic(id)
+	default:
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	etag := fmt.Sprintf("\"%s%s.%s-%d\"", kind, id, format, lastUpdate)
+	var modified time.Time
+	if lastUpdate != 0 {
+		modified = time.Unix(0, lastUpdate)
+	}
+
+	rw.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		rw.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	rw.Header().Set("Cache-Control", "private, no-cache")
+
+	if feedNotModified(r, etag, modified) {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	f := feed{
+		Self: absoluteURL(fmt.Sprintf("/feed/%s.%s", kind, format)),
+		Link: absoluteURL("/"),
+	}
+
+	switch kind {
+	case "topics":
+		f.Title = feedTitle(t.LatestTopics)
+		topics, err := database.GetLatestTopics(feedLength)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		for i := range topics {
+			f.Entries = append(f.Entries, feedEntry{
+				Title:     topics[i].Name,
+				Link:      topicURL(topics[i].ID),
+				Author:    topics[i].Creator,
+				Published: topics[i].Created,
+				Updated:   topics[i].LastModified,
+			})
+		}
+	case "posts":
+		f.Title = feedTitle(t.LatestPosts)
+		posts, err := database.GetLatestPosts(feedLength)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		names := make(map[string]string)
+		for i := range posts {
+			n, ok := names[posts[i].TopicID]
+			if !ok {
+				tp, err := database.GetTopic(posts[i].TopicID)
+				if err != nil {
+					rw.WriteHeader(http.StatusInternalServerError)
+					rw.Write([]byte(err.Error()))
+					return
+				}
+				n = tp.Name
+				names[posts[i].TopicID] = n
+			}
+			f.Entries = append(f.Entries, postFeedEntry(posts[i], n))
+		}
+	case "topic":
+		f.Title = feedTitle(topic.Name)
+		f.Self = fmt.Sprintf("%s?id=%s", f.Self, url.QueryEscape(topic.ID))
+		f.Link = topicURL(topic.ID)
+		posts, err := database.GetPosts(topic.ID)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		for i := len(posts) - 1; i >= 0 && len(posts)-i <= feedLength; i-- {
+			f.Entries = append(f.Entries, postFeedEntry(posts[i], topic.Name))
+		}
+	}
+
+	// Use the most recent entry if the last update is unknown
+	f.Updated = modified
+	for i := range f.Entries {
+		if f.Entries[i].Updated.After(f.Updated) {
+			f.Updated = f.Entries[i].Updated
+		}
+	}
+
+	var b []byte
+	switch format {
+	case feedFormatAtom:
+		rw.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		b, err = marshalAtom(f)
+	case feedFormatRSS:
+		rw.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		b, err = marshalRSS(f)
+	}
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(b)
+}
+
+func newFeedTokenHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	token := r.Form.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	f, err := authtoken.NewFeedToken(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("%s created a new feed token", user)
+
+	// The token is only shown once since only its hash is saved
+	key := url.QueryEscape(f.ID)
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(rw, "%s\n\n", t.FeedTokenCreated)
+	fmt.Fprintf(rw, "%s:\n%s\n%s\n\n", t.LatestTopics, absoluteURL("/feed/topics.atom?key="+key), absoluteURL("/feed/topics.rss?key="+key))
+	fmt.Fprintf(rw, "%s:\n%s\n%s\n\n", t.LatestPosts, absoluteURL("/feed/posts.atom?key="+key), absoluteURL("/feed/posts.rss?key="+key))
+	fmt.Fprintf(rw, "%s:\n%s\n%s\n\n", t.Topic, absoluteURL("/feed/topic.atom?id=ID&key="+key), absoluteURL("/feed/topic.rss?id=ID&key="+key))
+	fmt.Fprintf(rw, "%s\n\n%s\n", t.FeedTokenCreatedMessage, absoluteURL("/user.html#feeds"))
+}
+
+func revokeFeedTokenHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	token := r.Form.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	_, err = authtoken.DeleteUserFeedToken(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/user.html#feeds", config.ServerPath), http.StatusFound)
+}
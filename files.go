@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2021,2022,2024 Marcus Soll
+// Copyright 2021,2022,2024,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -16,11 +16,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"strings"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/Top-Ranger/auth/data"
@@ -56,14 +58,12 @@ func saveFileHandleFunc(rw http.ResponseWriter, r *http.Request) {
 
 	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 
-	canUpload := config.EnableFileUpload || (config.EnableFileUploadAdmin && isAdmin)
-
-	if !canUpload {
+	if !canUploadFiles(loggedIn, isAdmin) {
 		rw.WriteHeader(http.StatusForbidden)
 		return
 	}
 
-	err := r.ParseMultipartForm(int64(config.FileMaxMB) * 1000000)
+	err := r.ParseMultipartForm(uploadMemoryLimit)
 	if err != nil {
 		rw.WriteHeader(http.StatusBadRequest)
 		rw.Write([]byte(err.Error()))
@@ -94,62 +94,54 @@ func saveFileHandleFunc(rw http.ResponseWriter, r *http.Request) {
 
 	}
 
-	fileReader, meta, err := r.FormFile("file")
+	category, err := database.GetCategory(topicData.Category)
 	if err != nil {
-		rw.WriteHeader(http.StatusBadRequest)
+		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
-
-	if meta.Size > int64(config.FileMaxMB)*1000000 {
+	if !canPost(loggedIn, isAdmin, topicData, category) {
 		tl := GetDefaultTranslation()
-		rw.WriteHeader(http.StatusBadRequest)
-		rw.Write([]byte(tl.FileTooLarge))
+		rw.WriteHeader(http.StatusForbidden)
+		rw.Write([]byte(tl.AdminOnlyCategoryMessage))
 		return
 	}
 
-	b, err := io.ReadAll(fileReader)
+	fileReader, meta, err := r.FormFile("file")
 	if err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
+		rw.WriteHeader(http.StatusBadRequest)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
-	f := files.File{
-		Name:  meta.Filename,
-		User:  user,
-		Topic: topic,
-		Data:  b,
-	}
-
-	fileID, err := files.SaveFile(f)
-	if err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		rw.Write([]byte(err.Error()))
+	if meta.Size > int64(config.FileMaxMB)*1000000 {
+		tl := GetDefaultTranslation()
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(tl.FileTooLarge))
 		return
 	}
 
-	err = database.ModifyLastSeen(user)
-	if err != nil {
-		log.Println("Can not modify last seen:", err)
-	}
+	defer fileReader.Close()
 
-	err = database.TopicModifyTime(topic)
+	fileID, err := saveFile(user, topic, meta.Filename, fileReader, meta.Size)
+	if message, ok := uploadErrorMessage(err, GetDefaultTranslation()); ok {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(message))
+		return
+	}
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
-	database.SetLastUpdateTopicPost()
-
-	http.Redirect(rw, r, fmt.Sprintf("%s/topic.html?id=%s#file%s", config.ServerPath, topic, fileID), http.StatusFound)
+	http.Redirect(rw, r, fmt.Sprintf("%s%s", config.ServerPath, filePath(topic, fileID)), http.StatusFound)
 }
 
 func getFileHandleFunc(rw http.ResponseWriter, r *http.Request) {
 	loggedIn, user := TestUser(r, rw)
 
-	if !config.CanReadWithoutRegister && !loggedIn {
+	if !canRead(loggedIn) {
 		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
 		return
 	}
@@ -173,20 +165,30 @@ func getFileHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	f, err := files.GetFile(id)
+	f, err := files.GetFileMetadata(id)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
 
+	access, err := canAccessFile(user, loggedIn, f)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
+	if !access {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
 
-	f.Name = strings.ReplaceAll(f.Name, "\"", "_")
-	f.Name = strings.ReplaceAll(f.Name, ";", "_")
-	rw.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", f.Name))
+	if q.Get("thumbnail") == "1" {
+		writeThumbnail(rw, r, f)
+		return
+	}
 
-	rw.WriteHeader(http.StatusOK)
-	rw.Write(f.Data)
+	writeFile(rw, r, f)
 }
 
 func deleteFileHandleFunc(rw http.ResponseWriter, r *http.Request) {
@@ -229,7 +231,7 @@ func deleteFileHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !isAdmin && user != f.User {
+	if !canDeleteFile(user, loggedIn, isAdmin, f) {
 		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
 		return
 	}
@@ -241,7 +243,13 @@ func deleteFileHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = events.SaveEvent(events.Event{
+	if conversation, ok := conversationOfFile(f.Topic); ok {
+		// Conversations are private, so no event is created
+		http.Redirect(rw, r, fmt.Sprintf("%s/conversation.html?id=%s", config.ServerPath, url.QueryEscape(conversation)), http.StatusFound)
+		return
+	}
+
+	_, err = saveEvent(events.Event{
 		Type:  EventFileDeleted,
 		User:  user,
 		Topic: f.Topic,
@@ -253,6 +261,8 @@ func deleteFileHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	database.SetLastUpdate(f.Topic)
+
 	err = database.ModifyLastSeen(user)
 	if err != nil {
 		log.Println("Can not modify last seen:", err)
@@ -260,3 +270,261 @@ func deleteFileHandleFunc(rw http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
 }
+
+// initFileStorage sets the storage for the content of uploaded files.
+func initFileStorage(c fileStorageConfig) error {
+	switch c.Type {
+	case "local":
+		s, err := files.NewLocalStorage(c.Directory)
+		if err != nil {
+			return err
+		}
+		files.SetStorage(s)
+	case "s3":
+		s, err := files.NewS3Storage(c.S3)
+		if err != nil {
+			return err
+		}
+		files.SetStorage(s)
+	default:
+		return fmt.Errorf("unknown file storage type '%s'", c.Type)
+	}
+	return nil
+}
+
+var (
+	// errUserQuotaExceeded is returned if an upload would exceed FileQuotaUserMB.
+	errUserQuotaExceeded = errors.New("user storage quota exceeded")
+
+	// errTopicQuotaExceeded is returned if an upload would exceed FileQuotaTopicMB.
+	errTopicQuotaExceeded = errors.New("topic storage quota exceeded")
+)
+
+// quotaReservations holds the bytes of uploads which passed the final quota check but are not saved yet.
+// They are counted against the quotas so that concurrent uploads can not exceed a quota together.
+var quotaReservations = struct {
+	sync.Mutex
+	user  map[string]int64
+	topic map[string]int64
+}{
+	user:  make(map[string]int64),
+	topic: make(map[string]int64),
+}
+
+// uploadMemoryLimit is the number of bytes of an upload kept in memory. Larger uploads are spooled to temporary files.
+const uploadMemoryLimit = 1 << 20
+
+// saveFile stores a new file in a topic and returns its ID. The content is read from r and must be size bytes long.
+// Permissions, the state of the topic and the size of the file must be checked by the caller.
+func saveFile(user, topic, name string, r io.ReadSeeker, size int64) (string, error) {
+	fileID, err := saveFileContent(files.File{
+		Name:   name,
+		User:   user,
+		Topic:  topic,
+		Length: size,
+	}, r)
+	if err != nil {
+		return "", err
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+
+	err = database.TopicModifyTime(topic)
+	if err != nil {
+		return "", err
+	}
+
+	database.SetLastUpdate(topic)
+
+	triggerWebhook(webhookFileUploaded, webhookFileData{
+		ID:    fileID,
+		Name:  name,
+		User:  user,
+		Topic: topic,
+		URL:   absoluteURL(fmt.Sprintf("/getFile.html?id=%s", fileID)),
+	})
+	return fileID, nil
+}
+
+// uploadErrorMessage returns the translated message for errors caused by the uploaded file itself.
+// It returns false for all other errors.
+func uploadErrorMessage(err error, t Translation) (string, bool) {
+	switch {
+	case errors.Is(err, errFileTypeNotAllowed):
+		return t.FileTypeNotAllowed, true
+	case errors.Is(err, errUserQuotaExceeded):
+		return t.UserQuotaExceeded, true
+	case errors.Is(err, errTopicQuotaExceeded):
+		return t.TopicQuotaExceeded, true
+	case errors.Is(err, errFileInfected):
+		return t.FileRejectedByScanner, true
+	case errors.Is(err, errFileScanFailed):
+		return t.FileScanFailed, true
+	default:
+		return "", false
+	}
+}
+
+// checkFileQuota returns an error if a new file of the given size would exceed FileQuotaUserMB or FileQuotaTopicMB.
+// Reserved bytes of uploads in progress are not counted, see reserveFileQuota.
+func checkFileQuota(user, topic string, size int64) error {
+	return checkFileQuotaReserved(user, topic, size, 0, 0)
+}
+
+// checkFileQuotaReserved works like checkFileQuota, but additionally counts the given number of reserved bytes for the user and the topic.
+func checkFileQuotaReserved(user, topic string, size, reservedUser, reservedTopic int64) error {
+	if config.FileQuotaUserMB > 0 {
+		used, err := files.GetUserStorage(user)
+		if err != nil {
+			return err
+		}
+		if used+reservedUser+size > int64(config.FileQuotaUserMB)*1000000 {
+			return errUserQuotaExceeded
+		}
+	}
+
+	if config.FileQuotaTopicMB > 0 {
+		used, err := files.GetTopicStorage(topic)
+		if err != nil {
+			return err
+		}
+		if used+reservedTopic+size > int64(config.FileQuotaTopicMB)*1000000 {
+			return errTopicQuotaExceeded
+		}
+	}
+	return nil
+}
+
+// reserveFileQuota checks the quotas including all reservations and reserves size bytes for the user and the topic.
+// On success, the returned function must be called once the file is saved or saving failed.
+func reserveFileQuota(user, topic string, size int64) (func(), error) {
+	quotaReservations.Lock()
+	defer quotaReservations.Unlock()
+
+	err := checkFileQuotaReserved(user, topic, size, quotaReservations.user[user], quotaReservations.topic[topic])
+	if err != nil {
+		return nil, err
+	}
+	quotaReservations.user[user] += size
+	quotaReservations.topic[topic] += size
+
+	return func() {
+		quotaReservations.Lock()
+		defer quotaReservations.Unlock()
+		releaseReservation(quotaReservations.user, user, size)
+		releaseReservation(quotaReservations.topic, topic, size)
+	}, nil
+}
+
+// releaseReservation removes size reserved bytes of key from m.
+func releaseReservation(m map[string]int64, key string, size int64) {
+	m[key] -= size
+	if m[key] <= 0 {
+		delete(m, key)
+	}
+}
+
+// saveFileContent saves a file without any further actions. f.Length must be the size of the content read from r.
+// The type of the file is detected from the content. If it is not allowed, errFileTypeNotAllowed is returned.
+// If the file exceeds a storage quota, errUserQuotaExceeded or errTopicQuotaExceeded is returned.
+// The content is checked by the upload scanner before it is saved, see scanUpload.
+// Metadata is removed from images and a thumbnail is created for them.
+func saveFileContent(f files.File, r io.ReadSeeker) (string, error) {
+	// The quota is checked early so that uploads exceeding it are not scanned
+	err := checkFileQuota(f.User, f.Topic, f.Length)
+	if err != nil {
+		return "", err
+	}
+
+	f.ContentType, err = sniffContentType(f.Name, r)
+	if err != nil {
+		return "", err
+	}
+	if !fileTypeAllowed(f.ContentType) {
+		return "", errFileTypeNotAllowed
+	}
+
+	err = scanUpload(f, r)
+	if err != nil {
+		return "", err
+	}
+
+	content, thumbnail, err := prepareUpload(&f, r)
+	if err != nil {
+		return "", err
+	}
+
+	if config.FileQuotaUserMB > 0 || config.FileQuotaTopicMB > 0 {
+		// The reservation is released after the file is saved, as it is counted by the database from then on
+		release, err := reserveFileQuota(f.User, f.Topic, f.Length)
+		if err != nil {
+			return "", err
+		}
+		defer release()
+	}
+	return files.SaveFile(f, content, thumbnail)
+}
+
+// writeFile writes the content of a file as response.
+// The content is streamed from the storage, range requests and conditional requests are supported.
+func writeFile(rw http.ResponseWriter, r *http.Request, f files.File) {
+	content, err := files.OpenFile(f)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	defer content.Close()
+
+	contentType := f.ContentType
+	if contentType == "" {
+		// The type of files uploaded by older versions was not recorded
+		contentType, err = sniffContentType(f.Name, content)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	rw.Header().Set("Content-Type", contentType)
+	rw.Header().Set("Content-Disposition", contentDisposition(f.Name, contentType))
+	writeFileContent(rw, r, f, fileETag(f, "file"), content)
+}
+
+// writeThumbnail writes the thumbnail of a file as response.
+func writeThumbnail(rw http.ResponseWriter, r *http.Request, f files.File) {
+	if !f.HasThumbnail() {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	content, err := files.OpenThumbnail(f)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	defer content.Close()
+
+	rw.Header().Set("Content-Type", "image/jpeg")
+	writeFileContent(rw, r, f, fileETag(f, "thumbnail"), content)
+}
+
+// writeFileContent serves content with the headers common to all file responses.
+// Files never change, so clients may keep them as long as they revalidate their access.
+func writeFileContent(rw http.ResponseWriter, r *http.Request, f files.File, etag string, content io.ReadSeeker) {
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.Header().Set("Cache-Control", "private, no-cache")
+	rw.Header().Set("ETag", etag)
+	http.ServeContent(rw, r, "", f.Date, content)
+}
+
+// fileETag returns the entity tag of a representation of a file.
+// IDs might be reused after a file is deleted, so the date and size are included.
+func fileETag(f files.File, representation string) string {
+	return fmt.Sprintf("\"%s-%s-%d-%d\"", representation, f.ID, f.Date.Unix(), f.Length)
+}
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2021,2024 Marcus Soll
+// Copyright 2021,2024,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -16,26 +16,127 @@
 package files
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"math"
 	"strconv"
 	"time"
+	"unicode/utf8"
+
+	"github.com/Top-Ranger/discussiongo/broadcast"
 )
 
 // File represents a file.
+// The content is read through OpenFile.
+// ContentType is the MIME type detected on upload. It is empty for files uploaded by older versions.
 type File struct {
-	ID     string
-	Name   string
-	User   string
-	Topic  string
-	Date   time.Time
-	Data   []byte `xml:",cdata"`
-	Length int64
+	ID          string
+	Name        string
+	User        string
+	Topic       string
+	Date        time.Time
+	Length      int64
+	ContentType string
+
+	// storageKey is the key of the content in the storage. It is empty if the content is still saved in the database.
+	storageKey string
+	// thumbnailKey is the key of the thumbnail in the storage. It is empty if the file has no thumbnail.
+	thumbnailKey string
+}
+
+// HasThumbnail returns whether a thumbnail of the file is available through OpenThumbnail.
+func (f File) HasThumbnail() bool {
+	return f.thumbnailKey != ""
+}
+
+// StorageUsage represents the storage used by the files of a user or a topic.
+// Key is the name of the user or the ID of the topic.
+type StorageUsage struct {
+	Key   string
+	Files int64
+	Bytes int64
+}
+
+// metadataColumns are the columns needed by scanMetadata.
+const metadataColumns = "id,name,user,topic,date,size,contenttype,storagekey,thumbnailkey"
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanMetadata reads the columns given by metadataColumns.
+func scanMetadata(row scanner) (File, error) {
+	f := File{}
+	var intDate int64
+	var intID int64
+	err := row.Scan(&intID, &f.Name, &f.User, &f.Topic, &intDate, &f.Length, &f.ContentType, &f.storageKey, &f.thumbnailKey)
+	if err != nil {
+		return f, err
+	}
+	f.ID = strconv.FormatInt(intID, 10)
+	f.Date = time.Unix(intDate, 0)
+	return f, nil
+}
+
+// deleteContent removes the content of the given keys from the storage. Empty keys are skipped.
+// Errors are only logged since the files are already removed from the database.
+func deleteContent(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	s, err := getStorage()
+	if err != nil {
+		log.Println("Can not delete file content:", err)
+		return
+	}
+	for i := range keys {
+		if keys[i] == "" {
+			continue
+		}
+		err = s.Delete(keys[i])
+		if err != nil {
+			log.Printf("Can not delete file content %s: %s", keys[i], err.Error())
+		}
+	}
+}
+
+// storageKeys returns the storage keys of the content and the thumbnails of all files matching the condition.
+func storageKeys(condition string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT storagekey, thumbnailkey FROM files WHERE %s", condition), args...)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	keys := make([]string, 0)
+	for rows.Next() {
+		var key, thumbnail string
+		err = rows.Scan(&key, &thumbnail)
+		if err != nil {
+			return nil, err
+		}
+		if key != "" {
+			keys = append(keys, key)
+		}
+		if thumbnail != "" {
+			keys = append(keys, thumbnail)
+		}
+	}
+	return keys, nil
 }
 
 // DeleteTopicFiles removes all files associated by a topic.
 // It returns the number of deleted files.
 func DeleteTopicFiles(topicid string) (int64, error) {
+	keys, err := storageKeys("topic=?", topicid)
+	if err != nil {
+		return 0, err
+	}
+
 	r, err := db.Exec("DELETE FROM files WHERE topic=?", topicid)
 	if err != nil {
 		return 0, errors.New(fmt.Sprintln("Database error:", err))
@@ -45,12 +146,19 @@ func DeleteTopicFiles(topicid string) (int64, error) {
 	if err != nil {
 		return 0, errors.New(fmt.Sprintln("Database count error:", err))
 	}
+	deleteContent(keys)
+	broadcast.Publish(broadcast.Message{Type: broadcast.ContentRemoved, Topic: topicid})
 	return count, nil
 }
 
 // DeleteUserFiles removes all files associated by a user.
 // It returns the number of deleted files.
 func DeleteUserFiles(user string) (int64, error) {
+	keys, err := storageKeys("user=?", user)
+	if err != nil {
+		return 0, err
+	}
+
 	r, err := db.Exec("DELETE FROM files WHERE user=?", user)
 	if err != nil {
 		return 0, errors.New(fmt.Sprintln("Database error:", err))
@@ -60,6 +168,8 @@ func DeleteUserFiles(user string) (int64, error) {
 	if err != nil {
 		return 0, errors.New(fmt.Sprintln("Database count error:", err))
 	}
+	deleteContent(keys)
+	broadcast.Publish(broadcast.Message{Type: broadcast.ContentRemoved})
 	return count, nil
 }
 
@@ -70,165 +180,329 @@ func DeleteFile(ID string) error {
 		return errors.New(fmt.Sprintln("Can not convert ID:", err))
 	}
 
+	keys, err := storageKeys("id=?", intID)
+	if err != nil {
+		return err
+	}
+
 	_, err = db.Exec("DELETE FROM files WHERE id=?", intID)
 	if err != nil {
 		return errors.New(fmt.Sprintln("Database error:", err))
 	}
+	deleteContent(keys)
 
+	broadcast.Publish(broadcast.Message{Type: broadcast.FileDeleted, ID: ID})
 	return nil
 }
 
-// SaveFile saves a file.
-// ID and time will be ignored.
+// SaveFile saves a file. The content is read from r, f.Length must be its size in bytes.
+// If thumbnail is not empty, it is saved as the thumbnail of the file.
+// ID and Date will be ignored.
 // It returns the ID of the file.
-func SaveFile(f File) (string, error) {
+func SaveFile(f File, r io.Reader, thumbnail []byte) (string, error) {
+	s, err := getStorage()
+	if err != nil {
+		return "", err
+	}
+
+	key, err := newStorageKey()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.Save(key, r, f.Length)
+	if err != nil {
+		return "", fmt.Errorf("files: can not save content: %w", err)
+	}
+
+	thumbnailKey := ""
+	if len(thumbnail) != 0 {
+		thumbnailKey, err = newStorageKey()
+		if err != nil {
+			deleteContent([]string{key})
+			return "", err
+		}
+
+		err = s.Save(thumbnailKey, bytes.NewReader(thumbnail), int64(len(thumbnail)))
+		if err != nil {
+			deleteContent([]string{key})
+			return "", fmt.Errorf("files: can not save thumbnail: %w", err)
+		}
+	}
+
 	date := time.Now().Unix()
-	r, err := db.Exec("INSERT INTO files (name, user, topic, date, data) VALUES (?, ?, ?, ?, ?)", f.Name, f.User, f.Topic, date, f.Data)
+	res, err := db.Exec("INSERT INTO files (name, user, topic, date, size, contenttype, storagekey, thumbnailkey) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", f.Name, f.User, f.Topic, date, f.Length, f.ContentType, key, thumbnailKey)
 	if err != nil {
+		deleteContent([]string{key, thumbnailKey})
 		return "", errors.New(fmt.Sprintln("Database error:", err))
 	}
 
-	id, err := r.LastInsertId()
+	id, err := res.LastInsertId()
 	if err != nil {
 		return "", errors.New(fmt.Sprintln("Database id error:", err))
 	}
 
+	broadcast.Publish(broadcast.Message{Type: broadcast.FileUploaded, Topic: f.Topic, ID: strconv.FormatInt(id, 10)})
 	return strconv.FormatInt(id, 10), nil
-
 }
 
-// GetFile returns a file by ID.
-func GetFile(ID string) (File, error) {
-	intID, err := strconv.ParseInt(ID, 10, 64)
-	if err != nil {
-		return File{}, errors.New(fmt.Sprintln("Can not convert ID:", err))
+// OpenFile returns the content of a file. f must be returned by one of the metadata functions of this package.
+// The caller must close the content.
+func OpenFile(f File) (io.ReadSeekCloser, error) {
+	if f.storageKey == "" {
+		// Content was not yet migrated out of the database
+		intID, err := strconv.ParseInt(f.ID, 10, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintln("Can not convert ID:", err))
+		}
+		var data []byte
+		err = db.QueryRow("SELECT data FROM files WHERE id=?", intID).Scan(&data)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintln("Database error:", err))
+		}
+		return byteContent{bytes.NewReader(data)}, nil
 	}
 
-	rows, err := db.Query("SELECT id,name,user,topic,date,data,length(data) FROM files WHERE id=?", intID)
+	s, err := getStorage()
 	if err != nil {
-		return File{}, err
+		return nil, err
 	}
-	defer rows.Close()
+	return s.Open(f.storageKey)
+}
 
-	f := File{}
-	if rows.Next() {
-		var intDate int64
-		var intID int64
-		err = rows.Scan(&intID, &f.Name, &f.User, &f.Topic, &intDate, &f.Data, &f.Length)
-		if err != nil {
-			return f, err
-		}
-		f.ID = strconv.FormatInt(intID, 10)
-		f.Date = time.Unix(intDate, 0)
-	} else {
-		return f, errors.New("can not read topic data")
+// OpenThumbnail returns the thumbnail of a file. f must be returned by one of the metadata functions of this package.
+// The caller must close the thumbnail.
+func OpenThumbnail(f File) (io.ReadSeekCloser, error) {
+	if !f.HasThumbnail() {
+		return nil, fmt.Errorf("files: file %s has no thumbnail", f.ID)
 	}
-	return f, nil
+
+	s, err := getStorage()
+	if err != nil {
+		return nil, err
+	}
+	return s.Open(f.thumbnailKey)
 }
 
-// GetFile returns the file metadata by ID.
-// It does not fill File.Data
+// GetFileMetadata returns the file metadata by ID.
 func GetFileMetadata(ID string) (File, error) {
 	intID, err := strconv.ParseInt(ID, 10, 64)
 	if err != nil {
 		return File{}, errors.New(fmt.Sprintln("Can not convert ID:", err))
 	}
 
-	rows, err := db.Query("SELECT id,name,user,topic,date,length(data) FROM files WHERE id=?", intID)
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM files WHERE id=?", metadataColumns), intID)
 	if err != nil {
 		return File{}, err
 	}
 	defer rows.Close()
 
-	f := File{}
-	if rows.Next() {
-		var intDate int64
-		var intID int64
-		err = rows.Scan(&intID, &f.Name, &f.User, &f.Topic, &intDate, &f.Length)
-		if err != nil {
-			return f, err
-		}
-		f.ID = strconv.FormatInt(intID, 10)
-		f.Date = time.Unix(intDate, 0)
-	} else {
-		return f, errors.New("can not read topic data")
+	if !rows.Next() {
+		return File{}, errors.New("can not read topic data")
 	}
-	return f, nil
+	return scanMetadata(rows)
 }
 
-// GetFilesOfTopic returns all file metadata associated by a topic.
-// It does not fill File.Data
+// GetFileMetadataOfTopic returns all file metadata associated by a topic.
 func GetFileMetadataOfTopic(topicid string) ([]File, error) {
 	files := make([]File, 0)
 
-	rows, err := db.Query("SELECT id,name,user,topic,date,length(data) FROM files WHERE topic=?", topicid)
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM files WHERE topic=?", metadataColumns), topicid)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		f := File{}
-		var intDate int64
-		var intID int64
-		err = rows.Scan(&intID, &f.Name, &f.User, &f.Topic, &intDate, &f.Length)
+		f, err := scanMetadata(rows)
 		if err != nil {
 			return files, err
 		}
-		f.ID = strconv.FormatInt(intID, 10)
-		f.Date = time.Unix(intDate, 0)
 		files = append(files, f)
 	}
 	return files, nil
 }
 
-// GetFilesForUser returns all files associated by a user.
-func GetFilesForUser(user string) ([]File, error) {
+// GetFileMetadataOfTopicBetween returns the metadata of all files associated by a topic which were uploaded at or after from and before to.
+// If to is the zero time, there is no upper bound.
+func GetFileMetadataOfTopicBetween(topicid string, from, to time.Time) ([]File, error) {
 	files := make([]File, 0)
 
-	rows, err := db.Query("SELECT id,name,user,topic,date,data,length(data) FROM files WHERE user=?", user)
+	upper := int64(math.MaxInt64)
+	if !to.IsZero() {
+		upper = to.Unix()
+	}
+
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM files WHERE topic=? AND date>=? AND date<?", metadataColumns), topicid, from.Unix(), upper)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		f := File{}
-		var intDate int64
-		var intID int64
-		err = rows.Scan(&intID, &f.Name, &f.User, &f.Topic, &intDate, &f.Data, &f.Length)
+		f, err := scanMetadata(rows)
 		if err != nil {
 			return files, err
 		}
-		f.ID = strconv.FormatInt(intID, 10)
-		f.Date = time.Unix(intDate, 0)
 		files = append(files, f)
 	}
 	return files, nil
 }
 
-// GetFilesForUser returns all file metadata associated by a user.
-// It does not fill File.Data
+// GetFileMetadataForUser returns all file metadata associated by a user.
 func GetFileMetadataForUser(user string) ([]File, error) {
 	files := make([]File, 0)
 
-	rows, err := db.Query("SELECT id,name,user,topic,date,length(data) FROM files WHERE user=?", user)
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM files WHERE user=?", metadataColumns), user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		f, err := scanMetadata(rows)
+		if err != nil {
+			return files, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// SearchFiles returns the metadata of up to limit files whose name contains all terms.
+// Files whose topic starts with excludeTopicPrefix are not returned, excludeTopicPrefix must not be empty.
+// The terms should be created by database.SearchTerms. The files are sorted by relevance.
+func SearchFiles(terms []string, excludeTopicPrefix string, limit int) ([]File, error) {
+	files := make([]File, 0)
+
+	if len(terms) == 0 {
+		return files, nil
+	}
+
+	rows, err := db.Query(searchFilesQuery, searchMatchExpression(terms), utf8.RuneCountInString(excludeTopicPrefix), excludeTopicPrefix, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		f := File{}
-		var intDate int64
-		var intID int64
-		err = rows.Scan(&intID, &f.Name, &f.User, &f.Topic, &intDate, &f.Length)
+		f, err := scanMetadata(rows)
 		if err != nil {
 			return files, err
 		}
-		f.ID = strconv.FormatInt(intID, 10)
-		f.Date = time.Unix(intDate, 0)
 		files = append(files, f)
 	}
 	return files, nil
 }
+
+// GetUserStorage returns the number of bytes used by all files of a user.
+func GetUserStorage(user string) (int64, error) {
+	var size int64
+	err := db.QueryRow("SELECT COALESCE(SUM(size),0) FROM files WHERE user=?", user).Scan(&size)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return size, nil
+}
+
+// GetTopicStorage returns the number of bytes used by all files of a topic.
+func GetTopicStorage(topic string) (int64, error) {
+	var size int64
+	err := db.QueryRow("SELECT COALESCE(SUM(size),0) FROM files WHERE topic=?", topic).Scan(&size)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return size, nil
+}
+
+// GetStorageByUser returns the storage usage of up to limit users, starting with the user storing the most bytes.
+func GetStorageByUser(limit int) ([]StorageUsage, error) {
+	return storageBy("user", limit)
+}
+
+// GetStorageByTopic returns the storage usage of up to limit topics, starting with the topic storing the most bytes.
+func GetStorageByTopic(limit int) ([]StorageUsage, error) {
+	return storageBy("topic", limit)
+}
+
+// storageBy returns the storage usage grouped by column.
+func storageBy(column string, limit int) ([]StorageUsage, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT %s, COUNT(*), COALESCE(SUM(size),0) AS bytes FROM files GROUP BY %s ORDER BY bytes DESC LIMIT ?", column, column), limit)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	usage := make([]StorageUsage, 0)
+	for rows.Next() {
+		var u StorageUsage
+		err = rows.Scan(&u.Key, &u.Files, &u.Bytes)
+		if err != nil {
+			return usage, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+// RebuildSearchIndex rebuilds the full text index of the file names.
+func RebuildSearchIndex() error {
+	_, err := db.Exec(rebuildSearchIndexQuery)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return nil
+}
+
+// MigrateToStorage moves the content of all files still saved in the database into the storage.
+// The files are moved one after another, so the migration can be interrupted and restarted at any time.
+// It returns the number of moved files.
+func MigrateToStorage() (int64, error) {
+	s, err := getStorage()
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := db.Query("SELECT id FROM files WHERE storagekey=''")
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	var count int64
+	for _, id := range ids {
+		var data []byte
+		err = db.QueryRow("SELECT data FROM files WHERE id=?", id).Scan(&data)
+		if err != nil {
+			return count, errors.New(fmt.Sprintln("Database error:", err))
+		}
+
+		key, err := newStorageKey()
+		if err != nil {
+			return count, err
+		}
+
+		err = s.Save(key, bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return count, fmt.Errorf("files: can not save content of file %d: %w", id, err)
+		}
+
+		_, err = db.Exec("UPDATE files SET storagekey=?, size=?, data=NULL WHERE id=?", key, len(data), id)
+		if err != nil {
+			deleteContent([]string{key})
+			return count, errors.New(fmt.Sprintln("Database error:", err))
+		}
+		count++
+	}
+	return count, nil
+}
@@ -1,7 +1,7 @@
 //go:build mysql
 
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2021,2022,2024 Marcus Soll
+// Copyright 2021,2022,2024,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -20,12 +20,29 @@ package files
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
-const databaseVersion = "MySQL-2"
+const databaseVersion = "MySQL-20"
+
+// The full text search uses the natural ordering of MATCH, which sorts by relevance.
+// OPTIMIZE TABLE recreates InnoDB tables including their full text indices.
+const (
+	searchFilesQuery        = "SELECT id, name, user, topic, date, size, contenttype, storagekey, thumbnailkey FROM files WHERE MATCH(name) AGAINST (? IN BOOLEAN MODE) AND SUBSTR(topic, 1, ?) <> ? LIMIT ?"
+	rebuildSearchIndexQuery = "OPTIMIZE TABLE files"
+)
+
+// searchMatchExpression returns a boolean mode query requiring all terms as prefix.
+func searchMatchExpression(terms []string) string {
+	required := make([]string, len(terms))
+	for i := range terms {
+		required[i] = fmt.Sprintf("+%s*", terms[i])
+	}
+	return strings.Join(required, " ")
+}
 
 // InitDB initialises the database.
 // Must be called before any other function.
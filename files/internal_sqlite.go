@@ -1,7 +1,7 @@
 //go:build sqlite
 
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2021,2022 Marcus Soll
+// Copyright 2021,2022,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -19,12 +19,37 @@ package files
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3" // Database driver
 )
 
+const (
+	searchFilesQuery        = "SELECT files.id, files.name, files.user, files.topic, files.date, files.size, files.contenttype, files.storagekey, files.thumbnailkey FROM files_search INNER JOIN files ON files_search.rowid=files.id WHERE files_search MATCH ? AND SUBSTR(files.topic, 1, ?) <> ? ORDER BY files_search.rank LIMIT ?"
+	rebuildSearchIndexQuery = "INSERT INTO files_search(files_search) VALUES('rebuild')"
+)
+
+// searchTables contains all statements needed to create the full text search.
+// The index is kept up to date by triggers.
+var searchTables = []string{
+	"CREATE VIRTUAL TABLE files_search USING fts5(name, content='files', content_rowid='id')",
+	"CREATE TRIGGER files_search_insert AFTER INSERT ON files BEGIN INSERT INTO files_search(rowid, name) VALUES (new.id, new.name); END",
+	"CREATE TRIGGER files_search_delete AFTER DELETE ON files BEGIN INSERT INTO files_search(files_search, rowid, name) VALUES ('delete', old.id, old.name); END",
+	"CREATE TRIGGER files_search_update AFTER UPDATE OF name ON files BEGIN INSERT INTO files_search(files_search, rowid, name) VALUES ('delete', old.id, old.name); INSERT INTO files_search(rowid, name) VALUES (new.id, new.name); END",
+}
+
+// searchMatchExpression returns a FTS5 query matching all terms as prefix.
+func searchMatchExpression(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i := range terms {
+		quoted[i] = fmt.Sprintf("\"%s\"*", strings.ReplaceAll(terms[i], "\"", "\"\""))
+	}
+	return strings.Join(quoted, " ")
+}
+
 // InitDB initialises the database.
 // Must be called before any other function.
 // SQLite will ignore all config.
@@ -62,7 +87,7 @@ func connectToDB(path string) error {
 			return err
 		}
 
-		_, err = tx.Exec("INSERT INTO meta VALUES ('version', 1)")
+		_, err = tx.Exec("INSERT INTO meta VALUES ('version', 6)")
 		if err != nil {
 			return err
 		}
@@ -72,7 +97,7 @@ func connectToDB(path string) error {
 			return err
 		}
 
-		_, err = tx.Exec("CREATE TABLE files (id INTEGER PRIMARY KEY, name TEXT NOT NULL, user TEXT NOT NULL, topic TEXT NOT NULL, date INTEGER, data BLOB)")
+		_, err = tx.Exec("CREATE TABLE files (id INTEGER PRIMARY KEY, name TEXT NOT NULL, user TEXT NOT NULL, topic TEXT NOT NULL, date INTEGER, data BLOB, size INTEGER DEFAULT 0, storagekey TEXT DEFAULT '', thumbnailkey TEXT DEFAULT '', contenttype TEXT DEFAULT '')")
 		if err != nil {
 			return err
 		}
@@ -87,6 +112,18 @@ func connectToDB(path string) error {
 			return err
 		}
 
+		_, err = tx.Exec("CREATE INDEX idx_files_owner ON files (user)")
+		if err != nil {
+			return err
+		}
+
+		for _, q := range searchTables {
+			_, err = tx.Exec(q)
+			if err != nil {
+				return fmt.Errorf("can not create search index (SQLite builds require FTS5, build with -tags=\"sqlite sqlite_fts5\"): %w", err)
+			}
+		}
+
 		err = tx.Commit()
 		if err != nil {
 			return err
@@ -117,6 +154,148 @@ func connectToDB(path string) error {
 
 		// Upgrade
 		switch versionNr {
+		case 1:
+			log.Println("Upgrade files database 1 -> 2")
+
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			for _, q := range searchTables {
+				_, err = tx.Exec(q)
+				if err != nil {
+					return fmt.Errorf("can not create search index (SQLite builds require FTS5, build with -tags=\"sqlite sqlite_fts5\"): %w", err)
+				}
+			}
+
+			_, err = tx.Exec(rebuildSearchIndexQuery)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=2 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
+			log.Println("Upgrade done")
+			fallthrough
+		case 2:
+			log.Println("Upgrade files database 2 -> 3")
+
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("ALTER TABLE files ADD COLUMN size INTEGER DEFAULT 0")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("ALTER TABLE files ADD COLUMN storagekey TEXT DEFAULT ''")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE files SET size=length(data)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=3 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
+			log.Println("Upgrade done")
+			fallthrough
+		case 3:
+			log.Println("Upgrade files database 3 -> 4")
+
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("ALTER TABLE files ADD COLUMN thumbnailkey TEXT DEFAULT ''")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=4 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
+			log.Println("Upgrade done")
+			fallthrough
+		case 4:
+			log.Println("Upgrade files database 4 -> 5")
+
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("ALTER TABLE files ADD COLUMN contenttype TEXT DEFAULT ''")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=5 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
+			log.Println("Upgrade done")
+			fallthrough
+		case 5:
+			log.Println("Upgrade files database 5 -> 6")
+
+			tx, err := newDB.Begin()
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("CREATE INDEX idx_files_owner ON files (user)")
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE meta SET value=6 WHERE key='version'")
+			if err != nil {
+				return err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return err
+			}
+
+			log.Println("Upgrade done")
+			fallthrough
 		default:
 			log.Println("Database is on newest version")
 		}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+)
+
+// Storage holds the content of files. The metadata of the files stays in the database.
+// Keys only consist of characters which are safe in paths and URLs.
+// All methods must be safe for concurrent use.
+type Storage interface {
+	// Save stores the content read from r under key. size is the number of bytes r returns.
+	Save(key string, r io.Reader, size int64) error
+
+	// Open returns the content stored under key. The caller must close it.
+	Open(key string) (io.ReadSeekCloser, error)
+
+	// Delete removes the content stored under key.
+	// It does not return an error if key does not exist.
+	Delete(key string) error
+}
+
+// SetStorage sets the storage used for the content of new files.
+// Must be called before any file is saved or read.
+func SetStorage(s Storage) {
+	storage = s
+}
+
+// newStorageKey returns a new random key for the storage.
+func newStorageKey() (string, error) {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// getStorage returns the current storage or an error if none is set.
+func getStorage() (Storage, error) {
+	if storage == nil {
+		return nil, errors.New("files: no storage configured")
+	}
+	return storage, nil
+}
+
+// byteContent allows to read content saved in the database like content from the storage.
+type byteContent struct {
+	*bytes.Reader
+}
+
+// Close does nothing.
+func (b byteContent) Close() error {
+	return nil
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage stores the content of files in a local directory.
+type LocalStorage struct {
+	dir string
+}
+
+// NewLocalStorage returns a storage using dir. The directory is created if it does not exist.
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("files: can not create storage directory '%s': %w", dir, err)
+	}
+	return &LocalStorage{dir: dir}, nil
+}
+
+// path returns the path of the file holding the content of key.
+func (l *LocalStorage) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || key == "." || key == ".." {
+		return "", fmt.Errorf("files: invalid storage key '%s'", key)
+	}
+	return filepath.Join(l.dir, key), nil
+}
+
+// Save stores the content read from r under key.
+// The content is written to a temporary file first so that incomplete uploads are never visible.
+func (l *LocalStorage) Save(key string, r io.Reader, size int64) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(l.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if n != size {
+		tmp.Close()
+		return fmt.Errorf("files: expected %d bytes, got %d", size, n)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open returns the content stored under key.
+func (l *LocalStorage) Open(key string) (io.ReadSeekCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Delete removes the content stored under key.
+func (l *LocalStorage) Delete(key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,302 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA256 hash of an empty body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Config holds the configuration of an S3-compatible object storage.
+// Endpoint is the base URL of the service, e.g. 'https://s3.eu-central-1.amazonaws.com' or 'http://localhost:9000'.
+// Prefix is prepended to all keys. If PathStyle is set, the bucket is part of the path instead of the host name.
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Prefix    string
+	PathStyle bool
+}
+
+// S3Storage stores the content of files in an S3-compatible object storage.
+// Requests are signed with AWS Signature Version 4.
+type S3Storage struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3Storage returns a storage using the object storage described by config.
+// It does not create the bucket.
+func NewS3Storage(config S3Config) (*S3Storage, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("files: can not parse S3 endpoint '%s': %w", config.Endpoint, err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("files: S3 endpoint '%s' must use http or https", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, errors.New("files: no S3 bucket configured")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/")
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = time.Minute
+
+	return &S3Storage{
+		config:   config,
+		endpoint: endpoint,
+		client:   &http.Client{Transport: transport},
+	}, nil
+}
+
+// objectURL returns the URL of the object holding the content of key.
+func (s *S3Storage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	path := fmt.Sprintf("%s/%s%s", u.Path, s.config.Prefix, key)
+	if s.config.PathStyle {
+		path = fmt.Sprintf("%s/%s/%s%s", u.Path, s.config.Bucket, s.config.Prefix, key)
+	} else {
+		u.Host = fmt.Sprintf("%s.%s", s.config.Bucket, u.Host)
+	}
+	u.Path = path
+	u.RawPath = s3EscapePath(path)
+	return &u
+}
+
+// do signs and sends a request for the object holding the content of key.
+// If body is not nil, size bytes are sent without signing the payload.
+// The caller must close the body of the response.
+func (s *S3Storage) do(method, key string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	u := s.objectURL(key)
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the escaping of the path, it is part of the signature
+	req.URL = u
+	for k := range header {
+		req.Header.Set(k, header.Get(k))
+	}
+
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		payloadHash = "UNSIGNED-PAYLOAD"
+		req.ContentLength = size
+		req.Body = io.NopCloser(body)
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+	}
+	s.sign(req, payloadHash, time.Now())
+
+	return s.client.Do(req)
+}
+
+// sign adds the headers for AWS Signature Version 4 to req.
+func (s *S3Storage) sign(req *http.Request, payloadHash string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := []string{"host"}
+	values := map[string]string{"host": req.URL.Host}
+	if r := req.Header.Get("Range"); r != "" {
+		headers = append(headers, "range")
+		values["range"] = r
+	}
+	headers = append(headers, "x-amz-content-sha256", "x-amz-date")
+	values["x-amz-content-sha256"] = payloadHash
+	values["x-amz-date"] = amzDate
+
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		canonicalHeaders.WriteString(fmt.Sprintf("%s:%s\n", h, strings.TrimSpace(values[h])))
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.config.Region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+// Save stores the content read from r under key.
+func (s *S3Storage) Save(key string, r io.Reader, size int64) error {
+	resp, err := s.do(http.MethodPut, key, r, size, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(http.MethodPut, key, resp)
+	}
+	return nil
+}
+
+// Open returns the content stored under key.
+// The content is requested lazily, seeking starts a new ranged request.
+func (s *S3Storage) Open(key string) (io.ReadSeekCloser, error) {
+	resp, err := s.do(http.MethodHead, key, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("files: S3 object '%s': %w", key, os.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error(http.MethodHead, key, resp)
+	}
+	return &s3Object{storage: s, key: key, size: resp.ContentLength}, nil
+}
+
+// Delete removes the content stored under key.
+func (s *S3Storage) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return s3Error(http.MethodDelete, key, resp)
+	}
+}
+
+// s3Object reads an object of a S3Storage.
+type s3Object struct {
+	storage *S3Storage
+	key     string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+}
+
+// Read reads from the current offset, starting a new request if needed.
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		header := http.Header{}
+		if o.offset > 0 {
+			header.Set("Range", fmt.Sprintf("bytes=%d-", o.offset))
+		}
+		resp, err := o.storage.do(http.MethodGet, o.key, nil, 0, header)
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+			defer resp.Body.Close()
+			return 0, s3Error(http.MethodGet, o.key, resp)
+		}
+		o.body = resp.Body
+	}
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+// Seek sets the offset for the next Read.
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.size
+	default:
+		return 0, errors.New("files: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("files: negative position")
+	}
+	if offset != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = offset
+	return offset, nil
+}
+
+// Close closes the current request.
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
+
+// s3Error returns an error describing a failed request.
+func s3Error(method, key string, resp *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("files: S3 %s '%s' failed: %s %s", method, key, resp.Status, strings.TrimSpace(string(b)))
+}
+
+// s3EscapePath escapes a path as required by AWS Signature Version 4.
+// All characters except the unreserved ones and '/' are percent-encoded.
+func s3EscapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			b.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return b.String()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -19,5 +19,6 @@ package files
 import "database/sql"
 
 var (
-	db *sql.DB
+	db      *sql.DB
+	storage Storage
 )
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// errFileTypeNotAllowed is returned if the type of an uploaded file is not allowed by FileAllowedTypes and FileDeniedTypes.
+var errFileTypeNotAllowed = errors.New("file type not allowed")
+
+// inlineFileTypes can be shown by browsers without risk. All other files are offered as download,
+// so that e.g. uploaded HTML or SVG files can not run scripts in the context of the forum.
+var inlineFileTypes = map[string]bool{
+	"application/pdf": true,
+	"audio/mpeg":      true,
+	"audio/ogg":       true,
+	"audio/wav":       true,
+	"audio/wave":      true,
+	"audio/webm":      true,
+	"image/bmp":       true,
+	"image/gif":       true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+	"text/plain":      true,
+	"video/mp4":       true,
+	"video/ogg":       true,
+	"video/webm":      true,
+}
+
+// detectContentType returns the MIME type of a file based on the first bytes of its content.
+// The name of the file is only used if the content does not reveal a specific type.
+func detectContentType(name string, head []byte) string {
+	contentType := http.DetectContentType(head)
+	switch mediaType(contentType) {
+	case "application/octet-stream", "text/plain":
+		byName := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+		if byName != "" {
+			return byName
+		}
+	}
+	return contentType
+}
+
+// sniffContentType returns the MIME type of content. The content is read from the start and rewound afterwards.
+func sniffContentType(name string, content io.ReadSeeker) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	_, err = content.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	return detectContentType(name, head[:n]), nil
+}
+
+// mediaType returns the MIME type without parameters in lower case.
+func mediaType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	return t
+}
+
+// fileTypeAllowed returns whether files of the type can be uploaded.
+// Denied types always win, if allowed types are configured the type must match one of them.
+func fileTypeAllowed(contentType string) bool {
+	t := mediaType(contentType)
+	if matchFileType(config.FileDeniedTypes, t) {
+		return false
+	}
+	return len(config.FileAllowedTypes) == 0 || matchFileType(config.FileAllowedTypes, t)
+}
+
+// matchFileType returns whether the media type matches one of the patterns.
+// Patterns are either a complete media type (e.g. 'application/pdf') or match all subtypes (e.g. 'image/*').
+func matchFileType(patterns []string, t string) bool {
+	for _, p := range patterns {
+		if p == t || (strings.HasSuffix(p, "/*") && strings.HasPrefix(t, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFileTypes normalises the patterns used by matchFileType.
+func parseFileTypes(patterns []string) ([]string, error) {
+	normalised := make([]string, len(patterns))
+	for i := range patterns {
+		p := strings.ToLower(strings.TrimSpace(patterns[i]))
+		parts := strings.Split(p, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[0] == "*" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid file type '%s'", patterns[i])
+		}
+		normalised[i] = p
+	}
+	return normalised, nil
+}
+
+// contentDisposition returns the Content-Disposition header for a file.
+// Only types in inlineFileTypes are shown in the browser.
+func contentDisposition(name, contentType string) string {
+	disposition := "attachment"
+	if inlineFileTypes[mediaType(contentType)] {
+		disposition = "inline"
+	}
+	header := mime.FormatMediaType(disposition, map[string]string{"filename": name})
+	if header == "" {
+		// Name can not be encoded
+		return disposition
+	}
+	return header
+}
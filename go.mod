@@ -9,6 +9,7 @@ require (
 	github.com/microcosm-cc/bluemonday v1.0.27
 	github.com/yuin/goldmark v1.8.2
 	golang.org/x/crypto v0.50.0
+	golang.org/x/image v0.25.0
 )
 
 require (
@@ -0,0 +1,470 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Decoder for thumbnails
+	"image/jpeg"
+	_ "image/png" // Decoder for thumbnails
+	"io"
+
+	"github.com/Top-Ranger/discussiongo/files"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Decoder for thumbnails
+)
+
+const (
+	// thumbnailSize is the maximal width and height of a thumbnail in pixels.
+	thumbnailSize = 320
+
+	// maxThumbnailPixels is the maximal number of pixels of an image a thumbnail is created for.
+	// This protects against images which are small as file but huge when decoded, as decoding needs up to 4 bytes per pixel.
+	maxThumbnailPixels = 24000000
+)
+
+// metadataStrippers remove privacy relevant metadata (EXIF, XMP, comments, ...) from images without re-encoding them.
+// Besides the stripped image, they return the EXIF orientation (1 if the image is not rotated).
+var metadataStrippers = map[string]func([]byte) ([]byte, int, error){
+	"image/jpeg": stripJPEG,
+	"image/png":  stripPNG,
+	"image/gif":  stripGIF,
+	"image/webp": stripWebP,
+}
+
+// prepareUpload checks whether an upload is a supported image.
+// In this case, the metadata of the image is removed and a thumbnail is created.
+// It returns the content to save and the thumbnail, which is nil for all other files. f.Length and f.ContentType are updated to match the content.
+// Images whose structure can not be parsed are saved as 'application/octet-stream' so that they are never shown as image,
+// since their metadata could not be removed.
+// The size of the upload must be checked by the caller since images are read into memory.
+func prepareUpload(f *files.File, r io.Reader) (io.Reader, []byte, error) {
+	strip, ok := metadataStrippers[mediaType(f.ContentType)]
+	if !ok {
+		return r, nil, nil
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stripped, orientation, err := strip(data)
+	if err != nil {
+		f.ContentType = "application/octet-stream"
+		f.Length = int64(len(data))
+		return bytes.NewReader(data), nil, nil
+	}
+
+	thumbnail, err := createThumbnail(stripped, orientation)
+	if err != nil {
+		thumbnail = nil
+	}
+	f.Length = int64(len(stripped))
+	return bytes.NewReader(stripped), thumbnail, nil
+}
+
+// createThumbnail returns a JPEG fitting into thumbnailSize x thumbnailSize pixels.
+// Transparent areas are shown white, the orientation is applied to the thumbnail.
+func createThumbnail(data []byte, orientation int) ([]byte, error) {
+	c, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if c.Width <= 0 || c.Height <= 0 || int64(c.Width)*int64(c.Height) > maxThumbnailPixels {
+		return nil, fmt.Errorf("image size %dx%d not supported for thumbnails", c.Width, c.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := c.Width, c.Height
+	if width > thumbnailSize || height > thumbnailSize {
+		if width > height {
+			height = max(1, height*thumbnailSize/width)
+			width = thumbnailSize
+		} else {
+			width = max(1, width*thumbnailSize/height)
+			height = thumbnailSize
+		}
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(scaled, scaled.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, applyOrientation(scaled, orientation), &jpeg.Options{Quality: 80})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// applyOrientation rotates and mirrors img as described by the EXIF orientation.
+func applyOrientation(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	var out *image.RGBA
+	if orientation >= 5 {
+		out = image.NewRGBA(image.Rect(0, 0, h, w))
+	} else {
+		out = image.NewRGBA(image.Rect(0, 0, w, h))
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			out.SetRGBA(dx, dy, img.RGBAAt(x, y))
+		}
+	}
+	return out
+}
+
+// errInvalidImage is returned if the structure of an image can not be parsed.
+var errInvalidImage = errors.New("invalid image")
+
+// stripJPEG removes all APP1 (EXIF, XMP), APP13 (IPTC) and comment segments of a JPEG.
+// Since browsers rotate images according to the EXIF orientation, a minimal EXIF segment only containing the orientation is kept.
+// Data after the end of the image is removed.
+func stripJPEG(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, errInvalidImage
+	}
+
+	orientation := 1
+	segments := make([][]byte, 0)
+	pos := 2
+	for pos+1 < len(data) {
+		if data[pos] != 0xFF {
+			return nil, 0, errInvalidImage
+		}
+		marker := data[pos+1]
+
+		switch {
+		case marker == 0xFF:
+			// Fill byte
+			pos++
+			continue
+		case marker == 0xD9:
+			// End of image
+			segments = append(segments, data[pos:pos+2])
+			return buildJPEG(segments, orientation), orientation, nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// Markers without length
+			segments = append(segments, data[pos:pos+2])
+			pos += 2
+			continue
+		}
+
+		if pos+4 > len(data) {
+			return nil, 0, errInvalidImage
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end < pos+4 || end > len(data) {
+			return nil, 0, errInvalidImage
+		}
+		segment := data[pos:end]
+
+		switch marker {
+		case 0xE1:
+			if bytes.HasPrefix(segment[4:], []byte("Exif\x00\x00")) {
+				orientation = exifOrientation(segment[10:])
+			}
+		case 0xED, 0xFE:
+			// IPTC and comments are removed
+		case 0xDA:
+			// The entropy coded data of a scan runs until the next marker
+			i := end
+			for i+1 < len(data) && (data[i] != 0xFF || data[i+1] == 0x00 || (data[i+1] >= 0xD0 && data[i+1] <= 0xD7)) {
+				i++
+			}
+			if i+1 >= len(data) {
+				i = len(data)
+			}
+			segments = append(segments, data[pos:i])
+			end = i
+		default:
+			segments = append(segments, segment)
+		}
+		pos = end
+	}
+
+	// Image is truncated, but everything before the end is still shown by browsers
+	segments = append(segments, data[pos:])
+	return buildJPEG(segments, orientation), orientation, nil
+}
+
+// buildJPEG joins the segments of a JPEG. If orientation is not 1, a minimal EXIF segment containing the orientation is added.
+func buildJPEG(segments [][]byte, orientation int) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0xFF, 0xD8})
+
+	i := 0
+	if len(segments) > 0 && len(segments[0]) > 1 && segments[0][1] == 0xE0 {
+		// JFIF segment has to stay the first one
+		buf.Write(segments[0])
+		i = 1
+	}
+
+	if orientation != 1 {
+		buf.Write([]byte{0xFF, 0xE1, 0x00, 0x22})
+		buf.WriteString("Exif\x00\x00")
+		// TIFF header (big endian), IFD0 with one entry (orientation, SHORT, count 1), no next IFD
+		buf.Write([]byte{'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08})
+		buf.Write([]byte{0x00, 0x01, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, byte(orientation), 0x00, 0x00})
+		buf.Write([]byte{0x00, 0x00, 0x00, 0x00})
+	}
+
+	for ; i < len(segments); i++ {
+		buf.Write(segments[i])
+	}
+	return buf.Bytes()
+}
+
+// exifOrientation returns the orientation stored in the first IFD of the EXIF data (starting with the TIFF header).
+// It returns 1 if no valid orientation is found.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != 0x0112 || order.Uint16(tiff[entry+2:]) != 3 {
+			continue
+		}
+		o := int(order.Uint16(tiff[entry+8:]))
+		if o < 1 || o > 8 {
+			return 1
+		}
+		return o
+	}
+	return 1
+}
+
+// pngPrivateChunks are removed from PNG images.
+var pngPrivateChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// stripPNG removes all text, time and EXIF chunks of a PNG. Data after the end of the image is removed.
+func stripPNG(data []byte) ([]byte, int, error) {
+	if len(data) < 8 || !bytes.Equal(data[:8], []byte("\x89PNG\r\n\x1a\n")) {
+		return nil, 0, errInvalidImage
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:8])
+	pos := 8
+	for {
+		if pos+12 > len(data) {
+			return nil, 0, errInvalidImage
+		}
+		length := uint64(binary.BigEndian.Uint32(data[pos:]))
+		if length > uint64(len(data)-pos-12) {
+			return nil, 0, errInvalidImage
+		}
+		end := pos + 12 + int(length)
+		chunkType := string(data[pos+4 : pos+8])
+		if !pngPrivateChunks[chunkType] {
+			buf.Write(data[pos:end])
+		}
+		pos = end
+		if chunkType == "IEND" {
+			return buf.Bytes(), 1, nil
+		}
+	}
+}
+
+// stripGIF removes all comments and XMP data of a GIF. Data after the end of the image is removed.
+func stripGIF(data []byte) ([]byte, int, error) {
+	if len(data) < 13 || (!bytes.HasPrefix(data, []byte("GIF87a")) && !bytes.HasPrefix(data, []byte("GIF89a"))) {
+		return nil, 0, errInvalidImage
+	}
+
+	// Header, logical screen descriptor and global colour table
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+	if pos > len(data) {
+		return nil, 0, errInvalidImage
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:pos])
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21:
+			// Extension
+			if pos+2 > len(data) {
+				return nil, 0, errInvalidImage
+			}
+			end, err := gifSubBlocksEnd(data, pos+2)
+			if err != nil {
+				return nil, 0, err
+			}
+			keep := true
+			switch data[pos+1] {
+			case 0xFE:
+				// Comment
+				keep = false
+			case 0xFF:
+				// Application extension, the identifier is the first sub block
+				if end-pos > 14 && data[pos+2] == 11 && string(data[pos+3:pos+14]) == "XMP DataXMP" {
+					keep = false
+				}
+			}
+			if keep {
+				buf.Write(data[pos:end])
+			}
+			pos = end
+		case 0x2C:
+			// Image descriptor, local colour table, LZW minimum code size and image data
+			if pos+10 > len(data) {
+				return nil, 0, errInvalidImage
+			}
+			end := pos + 10
+			if data[pos+9]&0x80 != 0 {
+				end += 3 << (data[pos+9]&0x07 + 1)
+			}
+			end, err := gifSubBlocksEnd(data, end+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			buf.Write(data[pos:end])
+			pos = end
+		case 0x3B:
+			// Trailer
+			buf.WriteByte(0x3B)
+			return buf.Bytes(), 1, nil
+		default:
+			return nil, 0, errInvalidImage
+		}
+	}
+
+	// Image is truncated, add missing trailer
+	buf.WriteByte(0x3B)
+	return buf.Bytes(), 1, nil
+}
+
+// gifSubBlocksEnd returns the position after the sub blocks starting at pos.
+func gifSubBlocksEnd(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, errInvalidImage
+		}
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, nil
+		}
+		pos += size
+	}
+}
+
+// stripWebP removes the EXIF and XMP chunks of a WebP. Data after the end of the image is removed.
+func stripWebP(data []byte) ([]byte, int, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, 0, errInvalidImage
+	}
+	riffSize := uint64(binary.LittleEndian.Uint32(data[4:]))
+	if riffSize < 4 || riffSize > uint64(len(data)-8) {
+		return nil, 0, errInvalidImage
+	}
+	riffEnd := 8 + int(riffSize)
+
+	var buf bytes.Buffer
+	buf.Write(data[:12])
+	vp8x := -1
+	pos := 12
+	for pos+8 <= riffEnd {
+		chunkType := string(data[pos : pos+4])
+		size := uint64(binary.LittleEndian.Uint32(data[pos+4:]))
+		if size > uint64(riffEnd-pos-8) {
+			return nil, 0, errInvalidImage
+		}
+		// Chunks are padded to an even size
+		end := min(pos+8+int(size)+int(size&1), riffEnd)
+
+		switch chunkType {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			vp8x = buf.Len() + 8
+			buf.Write(data[pos:end])
+		default:
+			buf.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	b := buf.Bytes()
+	if vp8x >= 0 && vp8x < len(b) {
+		// Remove EXIF and XMP flags
+		b[vp8x] &^= 0x08 | 0x04
+	}
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-8))
+	return b, 1, nil
+}
@@ -0,0 +1,405 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/Top-Ranger/discussiongo/files"
+)
+
+// secret is placed in all metadata of the test images. It must not be found after stripping.
+const secret = "GPS 52.5200 13.4050"
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 8; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 16), G: uint8(y * 32), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// jpegSegment returns a JPEG segment with the given marker and payload.
+func jpegSegment(marker byte, payload []byte) []byte {
+	s := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(s[2:], uint16(len(payload)+2))
+	return append(s, payload...)
+}
+
+// exifPayload returns an APP1 payload with an orientation entry and an entry pointing to secret.
+func exifPayload(orientation uint16) []byte {
+	var b bytes.Buffer
+	b.WriteString("Exif\x00\x00")
+	b.Write([]byte{'I', 'I', 0x2A, 0x00, 0x08, 0x00, 0x00, 0x00})
+	b.Write([]byte{0x02, 0x00})
+	// Orientation
+	b.Write([]byte{0x12, 0x01, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, byte(orientation), 0x00, 0x00, 0x00})
+	// ImageDescription, ASCII, pointing behind the IFD
+	entry := []byte{0x0E, 0x01, 0x02, 0x00, 0, 0, 0, 0, 38, 0, 0, 0}
+	binary.LittleEndian.PutUint32(entry[4:], uint32(len(secret)+1))
+	b.Write(entry)
+	b.Write([]byte{0, 0, 0, 0})
+	b.WriteString(secret + "\x00")
+	return b.Bytes()
+}
+
+// testJPEG returns a JPEG with EXIF, XMP, IPTC and comment segments after the start of image.
+func testJPEG(t *testing.T, orientation uint16) []byte {
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, testImage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	var b bytes.Buffer
+	b.Write(encoded[:2])
+	b.Write(jpegSegment(0xE1, exifPayload(orientation)))
+	b.Write(jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>"+secret+"</x:xmpmeta>")))
+	b.Write(jpegSegment(0xED, []byte("Photoshop 3.0\x00"+secret)))
+	b.Write(jpegSegment(0xFE, []byte(secret)))
+	b.Write(encoded[2:])
+	return b.Bytes()
+}
+
+func TestStripJPEG(t *testing.T) {
+	for _, orientation := range []uint16{1, 6} {
+		data := testJPEG(t, orientation)
+		if !bytes.Contains(data, []byte(secret)) {
+			t.Fatal("test image does not contain metadata")
+		}
+
+		stripped, o, err := stripJPEG(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o != int(orientation) {
+			t.Errorf("got orientation %d, expected %d", o, orientation)
+		}
+		if bytes.Contains(stripped, []byte(secret)) {
+			t.Errorf("orientation %d: metadata not removed", orientation)
+		}
+		for _, marker := range []byte{0xED, 0xFE} {
+			if bytes.Contains(stripped, []byte{0xFF, marker}) {
+				t.Errorf("orientation %d: segment %X not removed", orientation, marker)
+			}
+		}
+		exif := bytes.Index(stripped, []byte("Exif\x00\x00"))
+		switch {
+		case orientation == 1 && exif != -1:
+			t.Error("EXIF segment kept without orientation")
+		case orientation != 1 && exif == -1:
+			t.Errorf("orientation %d: EXIF segment removed", orientation)
+		case orientation != 1 && exifOrientation(stripped[exif+6:]) != int(orientation):
+			t.Errorf("orientation %d not kept", orientation)
+		}
+
+		img, err := jpeg.Decode(bytes.NewReader(stripped))
+		if err != nil {
+			t.Fatalf("stripped image can not be decoded: %s", err)
+		}
+		if img.Bounds().Dx() != 16 || img.Bounds().Dy() != 8 {
+			t.Errorf("unexpected size %v", img.Bounds())
+		}
+	}
+}
+
+func TestStripJPEGInvalid(t *testing.T) {
+	data := testJPEG(t, 1)
+	for _, invalid := range [][]byte{
+		nil,
+		[]byte("not a jpeg"),
+		// Truncated inside the EXIF segment
+		data[:20],
+	} {
+		_, _, err := stripJPEG(invalid)
+		if err == nil {
+			t.Errorf("no error for %q", invalid)
+		}
+	}
+}
+
+// pngChunk returns a PNG chunk with a valid CRC.
+func pngChunk(chunkType string, payload []byte) []byte {
+	c := make([]byte, 8, 12+len(payload))
+	binary.BigEndian.PutUint32(c, uint32(len(payload)))
+	copy(c[4:], chunkType)
+	c = append(c, payload...)
+	return binary.BigEndian.AppendUint32(c, crc32.ChecksumIEEE(c[4:]))
+}
+
+func TestStripPNG(t *testing.T) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, testImage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	// Insert private chunks after IHDR (8 bytes signature + 25 bytes IHDR)
+	var b bytes.Buffer
+	b.Write(encoded[:33])
+	b.Write(pngChunk("tEXt", []byte("Comment\x00"+secret)))
+	b.Write(pngChunk("zTXt", []byte("Comment\x00\x00"+secret)))
+	b.Write(pngChunk("iTXt", []byte("Comment\x00\x00\x00\x00\x00"+secret)))
+	b.Write(pngChunk("eXIf", exifPayload(6)[6:]))
+	b.Write(pngChunk("tIME", []byte{0x07, 0xEA, 1, 2, 3, 4, 5}))
+	b.Write(encoded[33:])
+	b.WriteString(secret)
+
+	stripped, _, err := stripPNG(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stripped, []byte(secret)) {
+		t.Error("metadata not removed")
+	}
+	for chunkType := range pngPrivateChunks {
+		if bytes.Contains(stripped, []byte(chunkType)) {
+			t.Errorf("chunk %s not removed", chunkType)
+		}
+	}
+	if !bytes.Equal(stripped, encoded) {
+		t.Error("image data changed")
+	}
+
+	_, err = png.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("stripped image can not be decoded: %s", err)
+	}
+
+	_, _, err = stripPNG(encoded[:40])
+	if err == nil {
+		t.Error("no error for truncated image")
+	}
+}
+
+// gifSubBlocks returns data as GIF sub blocks including the terminator.
+func TestCreateThumbnailSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height uint32
+		valid         bool
+	}{
+		{name: "small", width: 20, height: 10, valid: true},
+		{name: "limit", width: 6000, height: 4000, valid: true},
+		{name: "too large", width: 6000, height: 4001, valid: false},
+		{name: "huge", width: 1 << 20, height: 1 << 20, valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if tc.valid {
+				err := png.Encode(&b, image.NewGray(image.Rect(0, 0, int(tc.width), int(tc.height))))
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				// The size is checked before decoding, so the header is enough
+				b.WriteString("\x89PNG\r\n\x1a\n")
+				b.Write(pngChunk("IHDR", append(binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, tc.width), tc.height), 8, 0, 0, 0, 0)))
+			}
+
+			thumbnail, err := createThumbnail(b.Bytes(), 1)
+			if tc.valid && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !tc.valid && err == nil {
+				t.Fatalf("thumbnail created for %dx%d image", tc.width, tc.height)
+			}
+			if tc.valid && len(thumbnail) == 0 {
+				t.Fatal("empty thumbnail")
+			}
+		})
+	}
+}
+
+func gifSubBlocks(data []byte) []byte {
+	b := make([]byte, 0)
+	for len(data) > 0 {
+		n := min(len(data), 255)
+		b = append(b, byte(n))
+		b = append(b, data[:n]...)
+		data = data[n:]
+	}
+	return append(b, 0)
+}
+
+func TestStripGIF(t *testing.T) {
+	var buf bytes.Buffer
+	err := gif.Encode(&buf, testImage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	// Insert extensions before the trailer
+	var b bytes.Buffer
+	b.Write(encoded[:len(encoded)-1])
+	b.Write([]byte{0x21, 0xFE})
+	b.Write(gifSubBlocks([]byte(secret)))
+	b.Write([]byte{0x21, 0xFF, 11})
+	b.WriteString("XMP DataXMP")
+	b.Write(gifSubBlocks([]byte("<x:xmpmeta>" + secret + "</x:xmpmeta>")))
+	b.Write([]byte{0x21, 0xFF, 11})
+	b.WriteString("NETSCAPE2.0")
+	b.Write([]byte{3, 1, 0, 0, 0})
+	b.WriteByte(0x3B)
+	b.WriteString(secret)
+
+	stripped, _, err := stripGIF(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stripped, []byte(secret)) {
+		t.Error("metadata not removed")
+	}
+	if !bytes.Contains(stripped, []byte("NETSCAPE2.0")) {
+		t.Error("animation extension removed")
+	}
+
+	_, err = gif.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("stripped image can not be decoded: %s", err)
+	}
+
+	_, _, err = stripGIF([]byte("GIF89a"))
+	if err == nil {
+		t.Error("no error for truncated image")
+	}
+}
+
+// webpChunk returns a RIFF chunk including padding.
+func webpChunk(chunkType string, payload []byte) []byte {
+	c := make([]byte, 8, 9+len(payload))
+	copy(c, chunkType)
+	binary.LittleEndian.PutUint32(c[4:], uint32(len(payload)))
+	c = append(c, payload...)
+	if len(payload)%2 == 1 {
+		c = append(c, 0)
+	}
+	return c
+}
+
+// testWebP returns an extended WebP with the given chunks after the VP8X chunk.
+func testWebP(chunks ...[]byte) []byte {
+	// Flags: ICC, alpha, EXIF, XMP
+	vp8x := []byte{0x20 | 0x10 | 0x08 | 0x04, 0, 0, 0, 15, 0, 0, 7, 0, 0}
+	var b bytes.Buffer
+	b.WriteString("RIFF\x00\x00\x00\x00WEBP")
+	b.Write(webpChunk("VP8X", vp8x))
+	for i := range chunks {
+		b.Write(chunks[i])
+	}
+	data := b.Bytes()
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+	return data
+}
+
+func TestStripWebP(t *testing.T) {
+	image := webpChunk("VP8L", []byte{0x2F, 0x0F, 0xC0, 0x01, 0x00})
+	data := testWebP(image, webpChunk("EXIF", exifPayload(6)[6:]), webpChunk("XMP ", []byte("<x:xmpmeta>"+secret+"</x:xmpmeta>")))
+	data = append(data, []byte(secret)...)
+
+	stripped, _, err := stripWebP(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stripped, []byte(secret)) {
+		t.Error("metadata not removed")
+	}
+	if bytes.Contains(stripped, []byte("EXIF")) || bytes.Contains(stripped, []byte("XMP ")) {
+		t.Error("chunks not removed")
+	}
+
+	expected := testWebP(image)
+	// EXIF and XMP flags are cleared, all other flags are kept
+	expected[20] = 0x20 | 0x10
+	if !bytes.Equal(stripped, expected) {
+		t.Errorf("got %X, expected %X", stripped, expected)
+	}
+	if int(binary.LittleEndian.Uint32(stripped[4:])) != len(stripped)-8 {
+		t.Error("RIFF size not updated")
+	}
+
+	invalid := testWebP(webpChunk("EXIF", []byte(secret)))
+	binary.LittleEndian.PutUint32(invalid[4:], uint32(len(invalid)+100))
+	_, _, err = stripWebP(invalid)
+	if err == nil {
+		t.Error("no error for invalid RIFF size")
+	}
+}
+
+func TestPrepareUpload(t *testing.T) {
+	data := testJPEG(t, 6)
+	f := files.File{Name: "a.jpg", ContentType: "image/jpeg", Length: int64(len(data))}
+	r, thumbnail, err := prepareUpload(&f, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(r)
+	if bytes.Contains(content, []byte(secret)) {
+		t.Error("metadata not removed")
+	}
+	if f.Length != int64(len(content)) || f.ContentType != "image/jpeg" {
+		t.Errorf("unexpected metadata %+v", f)
+	}
+	c, err := jpeg.DecodeConfig(bytes.NewReader(thumbnail))
+	if err != nil {
+		t.Fatalf("invalid thumbnail: %s", err)
+	}
+	if c.Width != 8 || c.Height != 16 {
+		t.Errorf("orientation not applied to thumbnail: %dx%d", c.Width, c.Height)
+	}
+
+	// Malformed images must never be served as image as they might still contain metadata
+	malformed := data[:20]
+	f = files.File{Name: "b.jpg", ContentType: "image/jpeg", Length: int64(len(malformed))}
+	r, thumbnail, err = prepareUpload(&f, bytes.NewReader(malformed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ = io.ReadAll(r)
+	if f.ContentType != "application/octet-stream" || thumbnail != nil || !bytes.Equal(content, malformed) {
+		t.Errorf("malformed image not saved as binary file: %+v", f)
+	}
+	if contentDisposition(f.Name, f.ContentType) == "inline" || inlineFileTypes[f.ContentType] {
+		t.Error("malformed image is shown inline")
+	}
+
+	text := []byte("hello")
+	f = files.File{Name: "c.txt", ContentType: "text/plain; charset=utf-8", Length: int64(len(text))}
+	r, thumbnail, err = prepareUpload(&f, bytes.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ = io.ReadAll(r)
+	if !bytes.Equal(content, text) || thumbnail != nil || f.ContentType != "text/plain; charset=utf-8" {
+		t.Error("other files must not be changed")
+	}
+}
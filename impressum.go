@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021,2022 Marcus Soll
+// Copyright 2020,2021,2022,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -21,11 +21,13 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Top-Ranger/auth/data"
 	"github.com/Top-Ranger/discussiongo/accesstimes"
@@ -33,6 +35,7 @@ import (
 	"github.com/Top-Ranger/discussiongo/database"
 	"github.com/Top-Ranger/discussiongo/events"
 	"github.com/Top-Ranger/discussiongo/files"
+	"github.com/Top-Ranger/discussiongo/notifications"
 )
 
 type impressumConfigStruct struct {
@@ -50,17 +53,24 @@ type impressumStruct struct {
 // DSGVOExport represents all information needed for an export according toDSGVO Art. 15 / DSGVO Art. 20.
 // It can then be marshalled e.g. to XMLS.
 type DSGVOExport struct {
-	XMLName        xml.Name `xml:"export"`
-	User           database.User
-	Topics         []database.Topic
-	Posts          []database.Post
-	Files          []files.File
-	Events         []events.Event
-	InvitedUser    []DSGVOExportInvitedUsers
-	Invitations    []string
-	TopicsLastRead []accesstimes.AccessTimes
-	AuthToken      []authtoken.Authtoken
-	NotExported    []string
+	XMLName               xml.Name `xml:"export"`
+	User                  database.User
+	Topics                []database.Topic
+	Posts                 []database.Post
+	PostRevisions         []database.PostRevision
+	Files                 []DSGVOExportFile
+	Conversations         []database.Conversation
+	Messages              []database.Message
+	Events                []events.Event
+	Notifications         []notifications.Notification
+	InvitedUser           []DSGVOExportInvitedUsers
+	Invitations           []string
+	TopicsLastRead        []accesstimes.AccessTimes
+	ConversationsLastRead []database.ConversationReadTime
+	AuthToken             []authtoken.Authtoken
+	PersonalToken         []authtoken.PersonalToken
+	FeedToken             []authtoken.FeedToken
+	NotExported           []string
 }
 
 // DSGVOExportInvitedUsers is a helper struct for DSGVOExport, which represents an invided user.
@@ -69,6 +79,93 @@ type DSGVOExportInvitedUsers struct {
 	Direct   bool
 }
 
+// DSGVOExportFile is a helper struct for DSGVOExport, which represents a file including its content.
+// The content is streamed from the storage while marshalling, so that large files are never kept in memory.
+type DSGVOExportFile struct {
+	files.File
+}
+
+// dsgvoExportChunkSize is the number of bytes of the content of a file read at once.
+const dsgvoExportChunkSize = 32 * 1024
+
+// MarshalXML writes the metadata of the file followed by its content.
+func (f DSGVOExportFile) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	err := e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+
+	metadata := []struct {
+		name  string
+		value any
+	}{
+		{name: "ID", value: f.ID},
+		{name: "Name", value: f.Name},
+		{name: "User", value: f.User},
+		{name: "Topic", value: f.Topic},
+		{name: "Date", value: f.Date},
+		{name: "Length", value: f.Length},
+		{name: "ContentType", value: f.ContentType},
+	}
+	for i := range metadata {
+		err = e.EncodeElement(metadata[i].value, xml.StartElement{Name: xml.Name{Local: metadata[i].name}})
+		if err != nil {
+			return err
+		}
+	}
+
+	content, err := files.OpenFile(f.File)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	data := xml.StartElement{Name: xml.Name{Local: "Data"}}
+	err = e.EncodeToken(data)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, dsgvoExportChunkSize)
+	carry := 0
+	for {
+		n, readErr := content.Read(buf[carry:])
+		n += carry
+		// Runes split between two reads are kept for the next chunk, otherwise they would be replaced as invalid
+		end := n
+		if readErr == nil {
+			for i := n - 1; i >= 0 && i >= n-utf8.UTFMax; i-- {
+				if utf8.RuneStart(buf[i]) {
+					if !utf8.FullRune(buf[i:n]) {
+						end = i
+					}
+					break
+				}
+			}
+		}
+		if end > 0 {
+			err = e.EncodeToken(xml.CharData(buf[:end]))
+			if err != nil {
+				return err
+			}
+		}
+		carry = copy(buf, buf[end:n])
+
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	err = e.EncodeToken(data.End())
+	if err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
 var (
 	impressumConfig     = impressumConfigStruct{}
 	impressum           = impressumStruct{}
@@ -210,7 +307,32 @@ func dsgvoExportHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dsgvo.Files, err = files.GetFilesForUser(user)
+	dsgvo.PostRevisions, err = database.GetPostRevisionsByUser(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	fs, err := files.GetFileMetadataForUser(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	dsgvo.Files = make([]DSGVOExportFile, len(fs))
+	for i := range fs {
+		dsgvo.Files[i] = DSGVOExportFile{fs[i]}
+	}
+
+	dsgvo.Conversations, err = database.GetConversationsOfUser(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	dsgvo.Messages, err = database.GetMessagesByUser(user)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
@@ -224,6 +346,13 @@ func dsgvoExportHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	dsgvo.Notifications, err = notifications.GetUserNotifications(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
 	users, err := database.GetAllUser()
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -251,6 +380,13 @@ func dsgvoExportHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	dsgvo.ConversationsLastRead, err = database.GetConversationReadTimes(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
 	dsgvo.AuthToken, err = authtoken.GetAuthtokenOfUser(user)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -258,15 +394,32 @@ func dsgvoExportHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dsgvo.NotExported = []string{"hashed password; algorithm: Argon2id (time=1, memory=64*1024)", "salt for password hash"}
+	dsgvo.PersonalToken, err = authtoken.GetPersonalTokenOfUser(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
 
-	b, err := xml.MarshalIndent(&dsgvo, "", "\t")
+	feedToken, ok, err := authtoken.GetFeedTokenOfUser(user)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
+	if ok {
+		dsgvo.FeedToken = []authtoken.FeedToken{feedToken}
+	}
+
+	dsgvo.NotExported = []string{"hashed password; algorithm: Argon2id (time=1, memory=64*1024)", "salt for password hash", "secret for two-factor authentication (TOTP)", "hashed recovery codes for two-factor authentication"}
 
+	// The export is streamed since it contains the content of all files of the user
 	rw.Write([]byte(xml.Header))
-	rw.Write(b)
+	e := xml.NewEncoder(rw)
+	e.Indent("", "\t")
+	err = e.Encode(&dsgvo)
+	if err != nil {
+		// The response has already started, the export is left incomplete
+		log.Printf("Can not write DSGVO export of %s: %s", user, err.Error())
+	}
 }
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021,2022 Marcus Soll
+// Copyright 2020,2021,2022,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -80,6 +80,10 @@ func invitationHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if rateLimited(rw, r, "") {
+		return
+	}
+
 	ok, err := database.TestInvitation(inv)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -99,6 +103,7 @@ func invitationHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		Translation:  t,
 	}
 	if !ok {
+		failedAttempt(r, "")
 		td.Error = "Einladung nicht gültig"
 		td.ShowRegister = false
 		td.ShowError = true
@@ -158,6 +163,10 @@ func registerInvitationHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if rateLimited(rw, r, "") {
+		return
+	}
+
 	valid, err := database.TestInvitation(inv)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -165,6 +174,7 @@ func registerInvitationHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !valid {
+		failedAttempt(r, "")
 		td := templateInvitationData{
 			ServerPath:   config.ServerPath,
 			ShowError:    true,
@@ -370,7 +380,7 @@ func registerInvitationHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		Date:         time.Now(),
 	}
 
-	_, err = events.SaveEvent(e)
+	_, err = saveEvent(e)
 	if err != nil {
 		log.Printf("Can not save event %+v: %s", e, err.Error())
 	}
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2022,2024 Marcus Soll
+// Copyright 2020,2022,2024,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -19,9 +19,7 @@ import (
 	"fmt"
 	"html/template"
 	"log"
-	"net"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/Top-Ranger/auth/data"
@@ -40,6 +38,8 @@ type loginLogoutData struct {
 	ServerPath       string
 	ForumName        string
 	Token            string
+	TOTPRequired     bool
+	TOTPUser         string
 	Translation      Translation
 }
 
@@ -53,18 +53,26 @@ func init() {
 
 	http.HandleFunc("/login.html", loginPageHandleFunc)
 	http.HandleFunc("/login/", loginHandleFunc)
+	http.HandleFunc("/loginTOTP/", loginTOTPHandleFunc)
 	http.HandleFunc("/logout/", logoutHandleFunc)
 }
 
 // SetCookies adds authentification cookies for a given user to the connection represented by a http.ResponseWriter.
 // Ater setting those, the user is authenticated and logged in.
+// The client address and user agent of the request are saved with the token so that the user can identify the session.
 // Token will be refreshed if needed by TestUser().
-func SetCookies(rw http.ResponseWriter, username string) error {
-	auth, err := authtoken.GetNewToken(username, config.CookieMinutes)
+func SetCookies(r *http.Request, rw http.ResponseWriter, username string) error {
+	auth, err := authtoken.GetNewToken(username, config.CookieMinutes, GetRealIP(r), r.UserAgent())
 	if err != nil {
 		return err
 	}
 
+	setLoginCookie(rw, auth.ID)
+	return nil
+}
+
+// setLoginCookie sets the login cookie to the given authtoken.
+func setLoginCookie(rw http.ResponseWriter, auth string) {
 	cookiePath := config.ServerPath
 	if cookiePath == "" {
 		cookiePath = "/"
@@ -72,22 +80,17 @@ func SetCookies(rw http.ResponseWriter, username string) error {
 
 	cookie := http.Cookie{}
 	cookie.Name = config.CookieLogin
-	cookie.Value = auth.ID
+	cookie.Value = auth
 	cookie.MaxAge = 60 * config.CookieMinutes
 	cookie.Path = cookiePath
 	cookie.SameSite = http.SameSiteLaxMode
 	cookie.HttpOnly = true
 	cookie.Secure = !config.InsecureAllowCookiesOverHTTP
 	http.SetCookie(rw, &cookie)
-
-	return nil
 }
 
-// RemoveCookies removes the authentification cookies from a given connection represented by a http.ResponseWriter.
-// It also removes the associated authtoken from the database.
-// This has the effect that the user is logged out.
-// Please note that the accesstoken (if present) is invalidated to prevent login if restored.
-func RemoveCookies(r *http.Request, rw http.ResponseWriter) error {
+// currentAuthtoken returns the authtoken sent by the client or an empty string if none is present.
+func currentAuthtoken(r *http.Request) string {
 	token := ""
 	c := r.Cookies()
 	for i := range c {
@@ -95,6 +98,15 @@ func RemoveCookies(r *http.Request, rw http.ResponseWriter) error {
 			token = c[i].Value
 		}
 	}
+	return token
+}
+
+// RemoveCookies removes the authentification cookies from a given connection represented by a http.ResponseWriter.
+// It also removes the associated authtoken from the database.
+// This has the effect that the user is logged out.
+// Please note that the accesstoken (if present) is invalidated to prevent login if restored.
+func RemoveCookies(r *http.Request, rw http.ResponseWriter) error {
+	token := currentAuthtoken(r)
 
 	if token != "" {
 		err := authtoken.DeleteToken(token)
@@ -121,16 +133,7 @@ func RemoveCookies(r *http.Request, rw http.ResponseWriter) error {
 // TestUser reports to a given connection represented by *http.Request whether a user is logged in and what his user name is.
 // Will refresh cookie and authtoken when needed.
 func TestUser(r *http.Request, rw http.ResponseWriter) (bool, string) {
-	c := r.Cookies()
-
-	auth := ""
-
-	// username
-	for i := range c {
-		if c[i].Name == config.CookieLogin {
-			auth = c[i].Value
-		}
-	}
+	auth := currentAuthtoken(r)
 
 	if auth == "" {
 		return false, ""
@@ -142,10 +145,11 @@ func TestUser(r *http.Request, rw http.ResponseWriter) (bool, string) {
 	}
 	if time.Until(validUntil)/time.Minute < time.Duration(config.CookieMinutes)/2 {
 		// Refresh token
-		log.Println("refresh")
-		err := SetCookies(rw, user)
+		_, err := authtoken.ExtendToken(auth, config.CookieMinutes)
 		if err != nil {
-			log.Println("login: can not refresh cookie:", err)
+			log.Println("login: can not refresh token:", err)
+		} else {
+			setLoginCookie(rw, auth)
 		}
 	}
 	return true, user
@@ -188,6 +192,10 @@ func loginHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if rateLimited(rw, r, user) {
+		return
+	}
+
 	b, err := database.VerifyUser(user, pw)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -199,10 +207,108 @@ func loginHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		if config.LogFailedLogin {
 			log.Printf("Failed login from %s", GetRealIP(r))
 		}
+		failedAttempt(r, user)
+		returnError()
+		return
+	}
+
+	secret, err := database.GetTOTPSecret(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if secret != "" {
+		// Second factor is needed before the user is logged in
+		token, err := data.GetStringsTimed(time.Now(), fmt.Sprintf("%s;TOTPLogin", user))
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+
+		l := loginLogoutData{RegisterPossible: config.CanRegister, ServerPath: config.ServerPath, ForumName: config.ForumName, Token: token, TOTPRequired: true, TOTPUser: user, Translation: t}
+		rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		rw.WriteHeader(http.StatusOK)
+		err = loginTemplate.Execute(rw, &l)
+		if err != nil {
+			log.Println("Error executing login template:", err)
+		}
+		return
+	}
+
+	successfulAttempt(user)
+	log.Println("Valid login from", user)
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+
+	err = SetCookies(r, rw, user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+}
+
+func loginTOTPHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	returnError := func() { http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound) }
+
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	q := r.Form
+	user := q.Get("name")
+	if user == "" {
+		returnError()
+		return
+	}
+
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusForbidden)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;TOTPLogin", user), time.Now(), totpLoginDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusForbidden)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	if rateLimited(rw, r, user) {
+		return
+	}
+
+	b, err := verifySecondFactor(user, q.Get("code"))
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !b {
+		if config.LogFailedLogin {
+			log.Printf("Failed second factor from %s", GetRealIP(r))
+		}
+		failedAttempt(r, user)
 		returnError()
 		return
 	}
 
+	successfulAttempt(user)
+
 	log.Println("Valid login from", user)
 
 	err = database.ModifyLastSeen(user)
@@ -210,7 +316,7 @@ func loginHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		log.Println("Can not modify last seen:", err)
 	}
 
-	err = SetCookies(rw, user)
+	err = SetCookies(r, rw, user)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
@@ -282,83 +388,3 @@ func loginPageHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		log.Println("Error executing login template:", err)
 	}
 }
-
-// GetRealIP tries to fing the real IP address of a client.
-// If an error is found, that error will be returned instead of an IP address.
-// A reverse proxy is only assumed if address is a loopback device (to avoid spoofing)
-func GetRealIP(r *http.Request) string {
-	ipPart, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return err.Error()
-	}
-	ip := net.ParseIP(strings.SplitN(ipPart, "%", 1)[0])
-	if ip != nil && !ip.IsLoopback() {
-		// We found a valid IP, this is most likely correct
-		goto returnIP
-	}
-
-	// This is likely behind a reverse proxy, try to find real address
-	{
-		header := r.Header.Get("Forwarded")
-		if header != "" {
-			headerParts := strings.Split(header, ";")
-			for i := range headerParts {
-				// Find for part of header
-				if strings.HasPrefix(headerParts[i], "for=") {
-					headerParts[i] = strings.TrimPrefix(headerParts[i], "for=")
-					ip = processSplittedHeader(strings.Split(headerParts[i], ","))
-					if ip != nil {
-						goto returnIP
-					}
-				}
-			}
-		}
-	}
-	{
-		header := r.Header.Get("X-Forwarded-For")
-		if header != "" {
-			ip = processSplittedHeader(strings.Split(header, ","))
-			if ip != nil {
-				goto returnIP
-			}
-
-		}
-	}
-
-returnIP:
-	if ip == nil {
-		return "unknown IP"
-	}
-	return ip.String()
-}
-
-func processSplittedHeader(split []string) net.IP {
-	for i := len(split) - 1; i >= 0; i++ {
-		// Go back to forward to find irst non local address. This way, fake addresses can't be spoofed by sending header (assumed proxy is trusted)
-
-		// Assume entry is an IP address. Handle other cases later.
-		s := split[i]
-		// Case IPv6 with brackets - should do nothing in all other cases
-		s = strings.TrimPrefix(s, "[")
-		s = strings.TrimSuffix(s, "]")
-		ip := net.ParseIP(strings.SplitN(s, "%", 1)[0])
-
-		if ip == nil {
-			// Maybe has form host:port?
-			ipPart, _, err := net.SplitHostPort(split[i])
-			if err != nil {
-				// Invalid entry - something is wrong, stop processing
-				return nil
-			}
-			ip := net.ParseIP(strings.SplitN(ipPart, "%", 1)[0])
-			if ip == nil {
-				// Invalid entry - something is wrong, stop processing
-				return nil
-			}
-		}
-		if ip != nil && !ip.IsLoopback() {
-			return ip
-		}
-	}
-	return nil
-}
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021,2022,2024 Marcus Soll
+// Copyright 2020,2021,2022,2024,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -19,6 +19,7 @@ package main
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"flag"
 	"log"
 	"net/http"
 	"runtime/debug"
@@ -28,8 +29,12 @@ import (
 	"github.com/Top-Ranger/discussiongo/database"
 	"github.com/Top-Ranger/discussiongo/events"
 	"github.com/Top-Ranger/discussiongo/files"
+	"github.com/Top-Ranger/discussiongo/notifications"
+	"github.com/Top-Ranger/discussiongo/webhooks"
 )
 
+var migrateFiles = flag.Bool("migrate-files", false, "move the content of uploaded files from the database into the file storage and exit")
+
 func printInfo() {
 	log.Println("DiscussionGo!")
 	bi, ok := debug.ReadBuildInfo()
@@ -56,18 +61,20 @@ func printInfo() {
 }
 
 func main() {
+	flag.Parse()
 	printInfo()
 
 	// Set Translation
 	SetDefaultTranslation(config.Language)
 
 	// Init databases
-	err := database.InitDB(config.DatabaseConfig)
+	// The access times are initialised first, as the SQLite database attaches them
+	err := accesstimes.InitDB(config.DatabaseConfig)
 	if err != nil {
 		panic(err)
 	}
 
-	err = accesstimes.InitDB(config.DatabaseConfig)
+	err = database.InitDB(config.DatabaseConfig)
 	if err != nil {
 		panic(err)
 	}
@@ -77,17 +84,48 @@ func main() {
 		panic(err)
 	}
 
+	err = initFileStorage(config.FileStorage)
+	if err != nil {
+		panic(err)
+	}
+
+	err = initUploadScanner(config.UploadScanner)
+	if err != nil {
+		panic(err)
+	}
+
+	if *migrateFiles {
+		log.Println("Moving files into the file storage")
+		count, err := files.MigrateToStorage()
+		log.Printf("Moved %d files", count)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
 	err = events.InitDB(config.DatabaseConfig)
 	if err != nil {
 		panic(err)
 	}
 
+	err = notifications.InitDB(config.DatabaseConfig)
+	if err != nil {
+		panic(err)
+	}
+
 	err = authtoken.InitDB(config.DatabaseConfig)
 	if err != nil {
 		panic(err)
 	}
 	authtoken.StartCleanupWorker()
 
+	err = webhooks.InitDB(config.DatabaseConfig)
+	if err != nil {
+		panic(err)
+	}
+	webhooks.StartWorker()
+
 	// Test SYSTEM
 	exists, err := database.UserExists("SYSTEM")
 
@@ -114,7 +152,7 @@ func main() {
 			panic(err)
 		}
 		password := base64.StdEncoding.EncodeToString(pw)
-		err = database.EditPassword("SYSTEM", password)
+		err = editPassword("SYSTEM", password)
 		if err != nil {
 			panic(err)
 		}
@@ -125,6 +163,11 @@ func main() {
 		panic(err)
 	}
 
+	err = initRateLimit(config.RateLimit)
+	if err != nil {
+		panic(err)
+	}
+
 	err = startAdminDeleteLoop(config.AdminEventDuration)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,4 @@
+CREATE TABLE discussiongo.webhooks (id BIGINT UNSIGNED AUTO_INCREMENT, url TEXT NOT NULL, secret VARCHAR(600) NOT NULL, events TEXT NOT NULL, created BIGINT UNSIGNED NOT NULL, PRIMARY KEY(id));
+CREATE TABLE discussiongo.deliveries (id BIGINT UNSIGNED AUTO_INCREMENT, webhook BIGINT UNSIGNED NOT NULL, event VARCHAR(600) NOT NULL, payload LONGBLOB NOT NULL, created BIGINT UNSIGNED NOT NULL, status VARCHAR(20) NOT NULL, attempts INTEGER DEFAULT 0, nextAttempt BIGINT UNSIGNED NOT NULL, lastAttempt BIGINT UNSIGNED DEFAULT 0, statusCode INTEGER DEFAULT 0, lastError VARCHAR(600) DEFAULT '', PRIMARY KEY(id));
+CREATE INDEX idx_deliveries_status_nextattempt ON discussiongo.deliveries (status, nextAttempt);
+UPDATE discussiongo.meta SET value='MySQL-11' WHERE mkey='version';
@@ -0,0 +1,2 @@
+CREATE TABLE discussiongo.feedtoken (id VARCHAR(600) NOT NULL PRIMARY KEY, user VARCHAR(600) NOT NULL UNIQUE, created BIGINT DEFAULT 0, lastUsed BIGINT DEFAULT 0);
+UPDATE discussiongo.meta SET value='MySQL-12' WHERE mkey='version';
@@ -0,0 +1,4 @@
+ALTER TABLE discussiongo.topic ADD COLUMN category BIGINT UNSIGNED DEFAULT 0;
+CREATE INDEX idx_topic_category ON discussiongo.topic (category);
+CREATE TABLE discussiongo.category (id BIGINT UNSIGNED AUTO_INCREMENT, name TEXT NOT NULL, description TEXT, position BIGINT DEFAULT 0, adminonly BOOL DEFAULT 0, PRIMARY KEY(id));
+UPDATE discussiongo.meta SET value='MySQL-13' WHERE mkey='version';
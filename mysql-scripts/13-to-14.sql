@@ -0,0 +1,4 @@
+CREATE TABLE discussiongo.tag (id BIGINT UNSIGNED AUTO_INCREMENT, name VARCHAR(600) NOT NULL UNIQUE, PRIMARY KEY(id));
+CREATE TABLE discussiongo.topictag (topic BIGINT UNSIGNED NOT NULL, tag BIGINT UNSIGNED NOT NULL, PRIMARY KEY(topic, tag), FOREIGN KEY(topic) REFERENCES topic(id) ON UPDATE CASCADE ON DELETE CASCADE, FOREIGN KEY(tag) REFERENCES tag(id) ON UPDATE CASCADE ON DELETE CASCADE);
+CREATE INDEX idx_topictag_tag ON discussiongo.topictag (tag);
+UPDATE discussiongo.meta SET value='MySQL-14' WHERE mkey='version';
@@ -0,0 +1,6 @@
+CREATE TABLE discussiongo.conversation (id BIGINT UNSIGNED AUTO_INCREMENT, title TEXT, creator VARCHAR(600), created BIGINT UNSIGNED, lastmodified BIGINT UNSIGNED, PRIMARY KEY(id));
+CREATE TABLE discussiongo.conversationparticipant (conversation BIGINT UNSIGNED NOT NULL, user VARCHAR(600) NOT NULL, lastread BIGINT UNSIGNED DEFAULT 0, PRIMARY KEY(conversation, user), FOREIGN KEY(conversation) REFERENCES conversation(id) ON UPDATE CASCADE ON DELETE CASCADE, FOREIGN KEY(user) REFERENCES user(name) ON UPDATE CASCADE ON DELETE CASCADE);
+CREATE INDEX idx_conversationparticipant_user ON discussiongo.conversationparticipant (user);
+CREATE TABLE discussiongo.message (id BIGINT UNSIGNED AUTO_INCREMENT, conversation BIGINT UNSIGNED NOT NULL, sender VARCHAR(600) NOT NULL, content LONGTEXT, time BIGINT UNSIGNED, FOREIGN KEY(conversation) REFERENCES conversation(id) ON UPDATE CASCADE ON DELETE CASCADE, PRIMARY KEY(id));
+CREATE INDEX idx_message_conversation_time_asc ON discussiongo.message (conversation, time ASC);
+UPDATE discussiongo.meta SET value='MySQL-15' WHERE mkey='version';
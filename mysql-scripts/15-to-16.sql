@@ -0,0 +1,4 @@
+CREATE INDEX idx_topic_created_desc ON discussiongo.topic (created DESC);
+CREATE INDEX idx_topic_creator ON discussiongo.topic (creator);
+CREATE INDEX idx_post_poster ON discussiongo.post (poster);
+UPDATE discussiongo.meta SET value='MySQL-16' WHERE mkey='version';
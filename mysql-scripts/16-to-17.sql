@@ -0,0 +1,4 @@
+ALTER TABLE discussiongo.files ADD COLUMN size BIGINT UNSIGNED DEFAULT 0;
+ALTER TABLE discussiongo.files ADD COLUMN storagekey VARCHAR(600) DEFAULT '';
+UPDATE discussiongo.files SET size=LENGTH(data);
+UPDATE discussiongo.meta SET value='MySQL-17' WHERE mkey='version';
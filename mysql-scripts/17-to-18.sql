@@ -0,0 +1,2 @@
+ALTER TABLE discussiongo.files ADD COLUMN thumbnailkey VARCHAR(600) DEFAULT '';
+UPDATE discussiongo.meta SET value='MySQL-18' WHERE mkey='version';
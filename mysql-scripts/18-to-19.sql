@@ -0,0 +1,2 @@
+ALTER TABLE discussiongo.files ADD COLUMN contenttype VARCHAR(600) DEFAULT '';
+UPDATE discussiongo.meta SET value='MySQL-19' WHERE mkey='version';
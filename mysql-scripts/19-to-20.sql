@@ -0,0 +1,2 @@
+CREATE INDEX idx_files_owner ON discussiongo.files (user);
+UPDATE discussiongo.meta SET value='MySQL-20' WHERE mkey='version';
@@ -0,0 +1,3 @@
+CREATE TABLE discussiongo.postrevision (id BIGINT UNSIGNED AUTO_INCREMENT, post BIGINT UNSIGNED, content LONGTEXT, time BIGINT UNSIGNED, FOREIGN KEY(post) REFERENCES post(id) ON UPDATE CASCADE ON DELETE CASCADE, PRIMARY KEY(id));
+CREATE INDEX idx_postrevision_post ON discussiongo.postrevision (post);
+UPDATE discussiongo.meta SET value='MySQL-3' WHERE mkey='version';
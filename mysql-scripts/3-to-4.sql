@@ -0,0 +1,4 @@
+CREATE FULLTEXT INDEX idx_topic_name_fulltext ON discussiongo.topic (name);
+CREATE FULLTEXT INDEX idx_post_content_fulltext ON discussiongo.post (content);
+CREATE FULLTEXT INDEX idx_files_name_fulltext ON discussiongo.files (name);
+UPDATE discussiongo.meta SET value='MySQL-4' WHERE mkey='version';
@@ -0,0 +1,2 @@
+CREATE TABLE discussiongo.lastupdate (topic BIGINT NOT NULL, time BIGINT, PRIMARY KEY(topic));
+UPDATE discussiongo.meta SET value='MySQL-5' WHERE mkey='version';
@@ -0,0 +1,3 @@
+CREATE TABLE discussiongo.notifications (id BIGINT UNSIGNED AUTO_INCREMENT, user VARCHAR(600) NOT NULL, actor VARCHAR(600) NOT NULL, topic VARCHAR(600) NOT NULL, post VARCHAR(600) NOT NULL, date BIGINT UNSIGNED NOT NULL, isread BOOL DEFAULT 0, PRIMARY KEY(id));
+CREATE INDEX idx_notifications_user ON discussiongo.notifications (user);
+UPDATE discussiongo.meta SET value='MySQL-6' WHERE mkey='version';
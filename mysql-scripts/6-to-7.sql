@@ -0,0 +1,4 @@
+ALTER TABLE discussiongo.user ADD COLUMN totpsecret VARCHAR(600) DEFAULT '';
+ALTER TABLE discussiongo.user ADD COLUMN totplaststep BIGINT DEFAULT 0;
+CREATE TABLE discussiongo.recoverycode (user VARCHAR(600) NOT NULL, code VARCHAR(600) NOT NULL, PRIMARY KEY(user, code), FOREIGN KEY(user) REFERENCES user(name) ON UPDATE CASCADE ON DELETE CASCADE);
+UPDATE discussiongo.meta SET value='MySQL-7' WHERE mkey='version';
@@ -0,0 +1,5 @@
+ALTER TABLE discussiongo.authtoken ADD COLUMN created BIGINT DEFAULT 0;
+ALTER TABLE discussiongo.authtoken ADD COLUMN lastUsed BIGINT DEFAULT 0;
+ALTER TABLE discussiongo.authtoken ADD COLUMN ip VARCHAR(600) DEFAULT '';
+ALTER TABLE discussiongo.authtoken ADD COLUMN userAgent VARCHAR(600) DEFAULT '';
+UPDATE discussiongo.meta SET value='MySQL-8' WHERE mkey='version';
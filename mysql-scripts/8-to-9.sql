@@ -0,0 +1,3 @@
+-- Token are stored as keyed hash now, the key is not part of the database. Existing token can not be converted, so all users have to log in again.
+DELETE FROM discussiongo.authtoken;
+UPDATE discussiongo.meta SET value='MySQL-9' WHERE mkey='version';
@@ -0,0 +1,2 @@
+CREATE TABLE discussiongo.personaltoken (id VARCHAR(600) NOT NULL PRIMARY KEY, user VARCHAR(600) NOT NULL, name VARCHAR(600) NOT NULL, scopes VARCHAR(600) NOT NULL, created BIGINT DEFAULT 0, lastUsed BIGINT DEFAULT 0, validUntil BIGINT DEFAULT 0);
+UPDATE discussiongo.meta SET value='MySQL-10' WHERE mkey='version';
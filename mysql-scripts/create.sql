@@ -1,17 +1,45 @@
 CREATE DATABASE discussiongo;
-CREATE TABLE discussiongo.user (name VARCHAR(600) NOT NULL, salt VARCHAR(600), encodedpasswort VARCHAR(600), admin BOOLEAN, comment LONGTEXT DEFAULT '', invitedby VARCHAR(600) DEFAULT '', invitationdirect BOOL DEFAULT 0, lastseen BIGINT UNSIGNED DEFAULT 0, PRIMARY KEY(name));
-CREATE TABLE discussiongo.topic (id BIGINT UNSIGNED AUTO_INCREMENT, name TEXT, creator VARCHAR(600), created BIGINT UNSIGNED, lastmodified BIGINT UNSIGNED, closed BOOL DEFAULT 0, pinned BOOL DEFAULT 0, PRIMARY KEY(id));
+CREATE TABLE discussiongo.user (name VARCHAR(600) NOT NULL, salt VARCHAR(600), encodedpasswort VARCHAR(600), admin BOOLEAN, comment LONGTEXT DEFAULT '', invitedby VARCHAR(600) DEFAULT '', invitationdirect BOOL DEFAULT 0, lastseen BIGINT UNSIGNED DEFAULT 0, totpsecret VARCHAR(600) DEFAULT '', totplaststep BIGINT DEFAULT 0, PRIMARY KEY(name));
+CREATE TABLE discussiongo.topic (id BIGINT UNSIGNED AUTO_INCREMENT, name TEXT, creator VARCHAR(600), created BIGINT UNSIGNED, lastmodified BIGINT UNSIGNED, closed BOOL DEFAULT 0, pinned BOOL DEFAULT 0, category BIGINT UNSIGNED DEFAULT 0, PRIMARY KEY(id));
 CREATE INDEX idx_topic_lastmodified_desc ON discussiongo.topic (lastmodified DESC);
+CREATE INDEX idx_topic_category ON discussiongo.topic (category);
+CREATE INDEX idx_topic_created_desc ON discussiongo.topic (created DESC);
+CREATE INDEX idx_topic_creator ON discussiongo.topic (creator);
+CREATE TABLE discussiongo.category (id BIGINT UNSIGNED AUTO_INCREMENT, name TEXT NOT NULL, description TEXT, position BIGINT DEFAULT 0, adminonly BOOL DEFAULT 0, PRIMARY KEY(id));
+CREATE FULLTEXT INDEX idx_topic_name_fulltext ON discussiongo.topic (name);
+CREATE TABLE discussiongo.tag (id BIGINT UNSIGNED AUTO_INCREMENT, name VARCHAR(600) NOT NULL UNIQUE, PRIMARY KEY(id));
+CREATE TABLE discussiongo.topictag (topic BIGINT UNSIGNED NOT NULL, tag BIGINT UNSIGNED NOT NULL, PRIMARY KEY(topic, tag), FOREIGN KEY(topic) REFERENCES topic(id) ON UPDATE CASCADE ON DELETE CASCADE, FOREIGN KEY(tag) REFERENCES tag(id) ON UPDATE CASCADE ON DELETE CASCADE);
+CREATE INDEX idx_topictag_tag ON discussiongo.topictag (tag);
 CREATE TABLE discussiongo.post (id BIGINT UNSIGNED AUTO_INCREMENT, content LONGTEXT, poster VARCHAR(600), time BIGINT UNSIGNED, topic BIGINT UNSIGNED, FOREIGN KEY(topic) REFERENCES topic(id) ON UPDATE CASCADE ON DELETE CASCADE, PRIMARY KEY(id));
 CREATE INDEX idx_post_topic_time_asc ON discussiongo.post (topic, time ASC);
+CREATE INDEX idx_post_poster ON discussiongo.post (poster);
+CREATE FULLTEXT INDEX idx_post_content_fulltext ON discussiongo.post (content);
+CREATE TABLE discussiongo.postrevision (id BIGINT UNSIGNED AUTO_INCREMENT, post BIGINT UNSIGNED, content LONGTEXT, time BIGINT UNSIGNED, FOREIGN KEY(post) REFERENCES post(id) ON UPDATE CASCADE ON DELETE CASCADE, PRIMARY KEY(id));
+CREATE INDEX idx_postrevision_post ON discussiongo.postrevision (post);
+CREATE TABLE discussiongo.conversation (id BIGINT UNSIGNED AUTO_INCREMENT, title TEXT, creator VARCHAR(600), created BIGINT UNSIGNED, lastmodified BIGINT UNSIGNED, PRIMARY KEY(id));
+CREATE TABLE discussiongo.conversationparticipant (conversation BIGINT UNSIGNED NOT NULL, user VARCHAR(600) NOT NULL, lastread BIGINT UNSIGNED DEFAULT 0, PRIMARY KEY(conversation, user), FOREIGN KEY(conversation) REFERENCES conversation(id) ON UPDATE CASCADE ON DELETE CASCADE, FOREIGN KEY(user) REFERENCES user(name) ON UPDATE CASCADE ON DELETE CASCADE);
+CREATE INDEX idx_conversationparticipant_user ON discussiongo.conversationparticipant (user);
+CREATE TABLE discussiongo.message (id BIGINT UNSIGNED AUTO_INCREMENT, conversation BIGINT UNSIGNED NOT NULL, sender VARCHAR(600) NOT NULL, content LONGTEXT, time BIGINT UNSIGNED, FOREIGN KEY(conversation) REFERENCES conversation(id) ON UPDATE CASCADE ON DELETE CASCADE, PRIMARY KEY(id));
+CREATE INDEX idx_message_conversation_time_asc ON discussiongo.message (conversation, time ASC);
+CREATE TABLE discussiongo.lastupdate (topic BIGINT NOT NULL, time BIGINT, PRIMARY KEY(topic));
+CREATE TABLE discussiongo.recoverycode (user VARCHAR(600) NOT NULL, code VARCHAR(600) NOT NULL, PRIMARY KEY(user, code), FOREIGN KEY(user) REFERENCES user(name) ON UPDATE CASCADE ON DELETE CASCADE);
 CREATE TABLE discussiongo.invitations (id VARCHAR(600) NOT NULL, creator VARCHAR(600), FOREIGN KEY(creator) REFERENCES user(name) ON UPDATE CASCADE ON DELETE CASCADE, PRIMARY KEY(id));
 CREATE TABLE discussiongo.times (name VARCHAR(600) NOT NULL, topic BIGINT UNSIGNED, time BIGINT UNSIGNED, PRIMARY KEY(name, topic), FOREIGN KEY(name) REFERENCES user(name) ON UPDATE CASCADE ON DELETE CASCADE, FOREIGN KEY(topic) REFERENCES topic(id) ON UPDATE CASCADE ON DELETE CASCADE);
 CREATE TABLE discussiongo.events (id BIGINT UNSIGNED AUTO_INCREMENT, type BIGINT UNSIGNED NOT NULL, user VARCHAR(600) NOT NULL, topic VARCHAR(600), date BIGINT UNSIGNED NOT NULL, data BLOB, affecteduser VARCHAR(600), PRIMARY KEY(id));
 CREATE INDEX idx_events_topic ON discussiongo.events (topic);
-CREATE TABLE discussiongo.files (id BIGINT UNSIGNED AUTO_INCREMENT, name VARCHAR(600) NOT NULL, user VARCHAR(600) NOT NULL, topic VARCHAR(600) NOT NULL, date BIGINT UNSIGNED, data LONGBLOB, FOREIGN KEY(user) REFERENCES user(name) ON UPDATE CASCADE ON DELETE CASCADE, PRIMARY KEY(id));
+CREATE TABLE discussiongo.files (id BIGINT UNSIGNED AUTO_INCREMENT, name VARCHAR(600) NOT NULL, user VARCHAR(600) NOT NULL, topic VARCHAR(600) NOT NULL, date BIGINT UNSIGNED, data LONGBLOB, size BIGINT UNSIGNED DEFAULT 0, storagekey VARCHAR(600) DEFAULT '', thumbnailkey VARCHAR(600) DEFAULT '', contenttype VARCHAR(600) DEFAULT '', FOREIGN KEY(user) REFERENCES user(name) ON UPDATE CASCADE ON DELETE CASCADE, PRIMARY KEY(id));
 CREATE INDEX idx_files_user ON discussiongo.files (name);
 CREATE INDEX idx_files_topic ON discussiongo.files (topic);
-CREATE TABLE discussiongo.authtoken (id VARCHAR(600) NOT NULL PRIMARY KEY, user TEXT NOT NULL, validUntil INTEGER NOT NULL);
+CREATE INDEX idx_files_owner ON discussiongo.files (user);
+CREATE FULLTEXT INDEX idx_files_name_fulltext ON discussiongo.files (name);
+CREATE TABLE discussiongo.notifications (id BIGINT UNSIGNED AUTO_INCREMENT, user VARCHAR(600) NOT NULL, actor VARCHAR(600) NOT NULL, topic VARCHAR(600) NOT NULL, post VARCHAR(600) NOT NULL, date BIGINT UNSIGNED NOT NULL, isread BOOL DEFAULT 0, PRIMARY KEY(id));
+CREATE INDEX idx_notifications_user ON discussiongo.notifications (user);
+CREATE TABLE discussiongo.authtoken (id VARCHAR(600) NOT NULL PRIMARY KEY, user TEXT NOT NULL, validUntil INTEGER NOT NULL, created BIGINT DEFAULT 0, lastUsed BIGINT DEFAULT 0, ip VARCHAR(600) DEFAULT '', userAgent VARCHAR(600) DEFAULT '');
 CREATE INDEX discussiongo.idx_authtoken_id ON authtoken (id);
+CREATE TABLE discussiongo.personaltoken (id VARCHAR(600) NOT NULL PRIMARY KEY, user VARCHAR(600) NOT NULL, name VARCHAR(600) NOT NULL, scopes VARCHAR(600) NOT NULL, created BIGINT DEFAULT 0, lastUsed BIGINT DEFAULT 0, validUntil BIGINT DEFAULT 0);
+CREATE TABLE discussiongo.feedtoken (id VARCHAR(600) NOT NULL PRIMARY KEY, user VARCHAR(600) NOT NULL UNIQUE, created BIGINT DEFAULT 0, lastUsed BIGINT DEFAULT 0);
+CREATE TABLE discussiongo.webhooks (id BIGINT UNSIGNED AUTO_INCREMENT, url TEXT NOT NULL, secret VARCHAR(600) NOT NULL, events TEXT NOT NULL, created BIGINT UNSIGNED NOT NULL, PRIMARY KEY(id));
+CREATE TABLE discussiongo.deliveries (id BIGINT UNSIGNED AUTO_INCREMENT, webhook BIGINT UNSIGNED NOT NULL, event VARCHAR(600) NOT NULL, payload LONGBLOB NOT NULL, created BIGINT UNSIGNED NOT NULL, status VARCHAR(20) NOT NULL, attempts INTEGER DEFAULT 0, nextAttempt BIGINT UNSIGNED NOT NULL, lastAttempt BIGINT UNSIGNED DEFAULT 0, statusCode INTEGER DEFAULT 0, lastError VARCHAR(600) DEFAULT '', PRIMARY KEY(id));
+CREATE INDEX idx_deliveries_status_nextattempt ON discussiongo.deliveries (status, nextAttempt);
 CREATE TABLE discussiongo.meta (mkey VARCHAR(600) NOT NULL, value VARCHAR(600), PRIMARY KEY(mkey));
-INSERT INTO discussiongo.meta VALUES ('version', 'MySQL-2');
\ No newline at end of file
+INSERT INTO discussiongo.meta VALUES ('version', 'MySQL-20');
\ No newline at end of file
@@ -0,0 +1,308 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Top-Ranger/auth/data"
+	"github.com/Top-Ranger/discussiongo/database"
+	"github.com/Top-Ranger/discussiongo/notifications"
+)
+
+const (
+	// maxMentions is the maximum number of users notified by a single post.
+	maxMentions = 20
+
+	// mentionTrailingPunctuation contains characters which are removed from the end of a mention if no user with the full name exists.
+	mentionTrailingPunctuation = ".,:;!?)]}\"'"
+
+	notificationSnippetLength = 200
+)
+
+type templateNotificationsData struct {
+	ServerPath    string
+	ForumName     string
+	Token         string
+	HasUnread     bool
+	Notifications []notificationData
+	Translation   Translation
+}
+
+type notificationData struct {
+	ID        string
+	Actor     string
+	TopicID   string
+	TopicName string
+	PostID    string
+	Date      string
+	Read      bool
+	Snippet   template.HTML
+}
+
+var notificationsTemplate *template.Template
+
+func init() {
+	var err error
+
+	notificationsTemplate, err = template.New("notifications").Funcs(evenOddFuncMap).ParseFS(templateFiles, "template/notifications.html")
+	if err != nil {
+		panic(err)
+	}
+
+	http.HandleFunc("/notifications.html", notificationsHandleFunc)
+	http.HandleFunc("/openNotification.html", openNotificationHandleFunc)
+	http.HandleFunc("/markNotificationsRead.html", markNotificationsReadHandleFunc)
+}
+
+// findMentions returns all users mentioned in a post (e.g. '@name').
+// Mentions inside of fenced code blocks are ignored.
+// Since user names may contain punctuation, trailing punctuation is only removed if no user with the full name exists.
+// exists is used to check whether a user exists.
+func findMentions(post string, exists func(string) (bool, error)) ([]string, error) {
+	mentions := make([]string, 0)
+	seen := make(map[string]bool)
+	inCode := false
+
+	for _, line := range strings.Split(post, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+
+		for _, f := range strings.Fields(line) {
+			if !strings.HasPrefix(f, "@") {
+				continue
+			}
+			name := f[1:]
+			for name != "" && !seen[name] {
+				ok, err := exists(name)
+				if err != nil {
+					return mentions, err
+				}
+				if ok {
+					seen[name] = true
+					mentions = append(mentions, name)
+					if len(mentions) == maxMentions {
+						return mentions, nil
+					}
+					break
+				}
+				r, size := utf8.DecodeLastRuneInString(name)
+				if !strings.ContainsRune(mentionTrailingPunctuation, r) {
+					break
+				}
+				name = name[:len(name)-size]
+			}
+		}
+	}
+	return mentions, nil
+}
+
+// notifyMentions saves a notification for every user mentioned in a post.
+// The author of the post is never notified.
+func notifyMentions(author, topicID, postID, post string) error {
+	mentions, err := findMentions(post, database.UserExists)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	n := make([]notifications.Notification, 0, len(mentions))
+	for i := range mentions {
+		if mentions[i] == author {
+			continue
+		}
+		n = append(n, notifications.Notification{
+			User:  mentions[i],
+			Actor: author,
+			Topic: topicID,
+			Post:  postID,
+			Date:  now,
+		})
+	}
+	return notifications.AddNotifications(n)
+}
+
+func notificationsHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	n, err := notifications.GetNotifications(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	token, err := data.GetStringsTimed(time.Now(), fmt.Sprintf("%s;Token", user))
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	td := templateNotificationsData{
+		ServerPath:    config.ServerPath,
+		ForumName:     config.ForumName,
+		Token:         token,
+		Notifications: make([]notificationData, 0, len(n)),
+		Translation:   GetDefaultTranslation(),
+	}
+
+	topicNames := make(map[string]string)
+	terms := []string{strings.ToLower("@" + user)}
+
+	for i := range n {
+		name, ok := topicNames[n[i].Topic]
+		if !ok {
+			topic, err := database.GetTopic(n[i].Topic)
+			if err == nil {
+				name = topic.Name
+			}
+			topicNames[n[i].Topic] = name
+		}
+		if name == "" {
+			// Topic was deleted
+			continue
+		}
+
+		post, err := database.GetSinglePost(n[i].Post)
+		if err != nil {
+			// Post was deleted
+			continue
+		}
+
+		if !n[i].Read {
+			td.HasUnread = true
+		}
+
+		td.Notifications = append(td.Notifications, notificationData{
+			ID:        n[i].ID,
+			Actor:     n[i].Actor,
+			TopicID:   n[i].Topic,
+			TopicName: name,
+			PostID:    n[i].Post,
+			Date:      n[i].Date.Format(time.RFC822),
+			Read:      n[i].Read,
+			Snippet:   searchSnippet(post.Content, terms, notificationSnippetLength),
+		})
+	}
+
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	err = notificationsTemplate.ExecuteTemplate(rw, "notifications.html", td)
+	if err != nil {
+		log.Println("Error executing notifications template:", err)
+	}
+}
+
+// openNotificationHandleFunc marks a notification as read and redirects to the post.
+func openNotificationHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	q := r.URL.Query()
+	id := q.Get("id")
+	if id == "" {
+		http.Redirect(rw, r, fmt.Sprintf("%s/notifications.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	token := q.Get("token")
+	if token == "" {
+		http.Redirect(rw, r, fmt.Sprintf("%s/notifications.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		http.Redirect(rw, r, fmt.Sprintf("%s/notifications.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	n, err := notifications.GetNotification(id)
+	if err != nil || n.User != user {
+		http.Redirect(rw, r, fmt.Sprintf("%s/notifications.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err = notifications.MarkRead(user, id)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s%s", config.ServerPath, postPath(n.Topic, n.Post)), http.StatusFound)
+}
+
+// markNotificationsReadHandleFunc marks a single notification as read.
+// If no ID is given, all notifications of the user are marked as read.
+func markNotificationsReadHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	q := r.URL.Query()
+	token := q.Get("token")
+	if token == "" {
+		http.Redirect(rw, r, fmt.Sprintf("%s/notifications.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		http.Redirect(rw, r, fmt.Sprintf("%s/notifications.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	var err error
+	id := q.Get("id")
+	if id == "" {
+		err = notifications.MarkAllRead(user)
+	} else {
+		err = notifications.MarkRead(user, id)
+	}
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/notifications.html", config.ServerPath), http.StatusFound)
+}
@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Notification represents a mention of a user in a post.
+type Notification struct {
+	ID string
+
+	// User is the user who receives the notification
+	User string
+
+	// Actor is the user who mentioned User
+	Actor string
+
+	Topic string
+	Post  string
+	Date  time.Time
+	Read  bool
+}
+
+// AddNotifications saves multiple notifications.
+// ID and Read will be ignored.
+func AddNotifications(n []Notification) error {
+	if len(n) == 0 {
+		return nil
+	}
+
+	var successful bool
+
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Transaction error:", err))
+	}
+
+	defer func() {
+		if !successful {
+			tx.Rollback()
+		}
+	}()
+
+	for i := range n {
+		_, err = tx.Exec("INSERT INTO notifications (user, actor, topic, post, date, isread) VALUES (?, ?, ?, ?, ?, ?)", n[i].User, n[i].Actor, n[i].Topic, n[i].Post, n[i].Date.Unix(), false)
+		if err != nil {
+			return errors.New(fmt.Sprintln("Database error:", err))
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Commit error:", err))
+	}
+
+	successful = true
+	return nil
+}
+
+// GetNotifications returns all notifications of a user.
+// The newest notifications are returned first.
+func GetNotifications(user string) ([]Notification, error) {
+	return queryNotifications("SELECT id, user, actor, topic, post, date, isread FROM notifications WHERE user=? ORDER BY date DESC, id DESC", user)
+}
+
+// GetUserNotifications returns all notifications associated with a user, i.e. all notifications the user has received or caused.
+func GetUserNotifications(user string) ([]Notification, error) {
+	return queryNotifications("SELECT id, user, actor, topic, post, date, isread FROM notifications WHERE user=? OR actor=?", user, user)
+}
+
+// GetNotification returns a notification by ID.
+func GetNotification(ID string) (Notification, error) {
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return Notification{}, errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	n, err := queryNotifications("SELECT id, user, actor, topic, post, date, isread FROM notifications WHERE id=?", intID)
+	if err != nil {
+		return Notification{}, err
+	}
+	if len(n) == 0 {
+		return Notification{}, errors.New("Can not read notification data")
+	}
+	return n[0], nil
+}
+
+func queryNotifications(query string, args ...interface{}) ([]Notification, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	n := make([]Notification, 0)
+	for rows.Next() {
+		var intID int64
+		var intDate int64
+		e := Notification{}
+		err = rows.Scan(&intID, &e.User, &e.Actor, &e.Topic, &e.Post, &intDate, &e.Read)
+		if err != nil {
+			return n, err
+		}
+		e.ID = strconv.FormatInt(intID, 10)
+		e.Date = time.Unix(intDate, 0)
+		n = append(n, e)
+	}
+	return n, nil
+}
+
+// CountUnread returns the number of unread notifications of a user.
+func CountUnread(user string) (int, error) {
+	rows, err := db.Query("SELECT COUNT(*) FROM notifications WHERE user=? AND isread=?", user, false)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	var count int
+	if rows.Next() {
+		err = rows.Scan(&count)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+// MarkRead marks a single notification of a user as read.
+// Notifications of other users are not affected.
+func MarkRead(user, ID string) error {
+	intID, err := strconv.ParseInt(ID, 10, 64)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Can not convert ID:", err))
+	}
+
+	_, err = db.Exec("UPDATE notifications SET isread=? WHERE id=? AND user=?", true, intID, user)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return nil
+}
+
+// MarkAllRead marks all notifications of a user as read.
+func MarkAllRead(user string) error {
+	_, err := db.Exec("UPDATE notifications SET isread=? WHERE user=?", true, user)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return nil
+}
+
+// DeletePostNotifications removes all notifications associated by a post.
+// It returns the number of deleted notifications.
+func DeletePostNotifications(post string) (int64, error) {
+	r, err := db.Exec("DELETE FROM notifications WHERE post=?", post)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+	return count, nil
+}
+
+// DeleteTopicNotifications removes all notifications associated by a topic.
+// It returns the number of deleted notifications.
+func DeleteTopicNotifications(topic string) (int64, error) {
+	r, err := db.Exec("DELETE FROM notifications WHERE topic=?", topic)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+	return count, nil
+}
+
+// DeleteUser removes all notifications the user has received or caused and returns the number of removed notifications.
+func DeleteUser(user string) (int64, error) {
+	r, err := db.Exec("DELETE FROM notifications WHERE user=? OR actor=?", user, user)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+	return count, nil
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"slices"
+
+	"github.com/Top-Ranger/discussiongo/database"
+	"github.com/Top-Ranger/discussiongo/files"
+)
+
+// The functions in this file contain the permission rules shared by the HTML handlers and the API.
+// user is the current user, which is empty if loggedIn is false.
+
+// canRead returns whether the forum content can be read.
+func canRead(loggedIn bool) bool {
+	return config.CanReadWithoutRegister || loggedIn
+}
+
+// canCloseTopic returns whether the user can close and reopen the topic.
+func canCloseTopic(user string, loggedIn, isAdmin bool, topic database.Topic) bool {
+	return (loggedIn && config.EveryoneCanCloseAndOpenTopics) || isAdmin || (loggedIn && user == topic.Creator)
+}
+
+// canPinTopic returns whether the user can pin and unpin topics.
+func canPinTopic(isAdmin bool) bool {
+	return isAdmin
+}
+
+// canRenameTopic returns whether the user can rename the topic.
+func canRenameTopic(user string, loggedIn, isAdmin bool, topic database.Topic) bool {
+	return isAdmin || (loggedIn && user == topic.Creator)
+}
+
+// canEditTopicTags returns whether the user can change the tags of the topic.
+func canEditTopicTags(user string, loggedIn, isAdmin bool, topic database.Topic) bool {
+	return isAdmin || (loggedIn && user == topic.Creator)
+}
+
+// canCreateTopic returns whether the user can create topics in the category.
+func canCreateTopic(loggedIn, isAdmin bool, category database.Category) bool {
+	return loggedIn && (!category.AdminOnly || isAdmin)
+}
+
+// canMoveTopic returns whether the user can move topics between categories.
+func canMoveTopic(isAdmin bool) bool {
+	return isAdmin
+}
+
+// canPost returns whether the user can add posts to the topic.
+// category must be the category of the topic.
+func canPost(loggedIn, isAdmin bool, topic database.Topic, category database.Category) bool {
+	return loggedIn && !topic.Closed && (!category.AdminOnly || isAdmin)
+}
+
+// canDeletePost returns whether the user can delete the post.
+func canDeletePost(user string, loggedIn, isAdmin bool, post database.Post) bool {
+	return isAdmin || (loggedIn && user == post.Poster)
+}
+
+// canEditPost returns whether the user can edit the post.
+// closed must be whether the topic of the post is closed.
+func canEditPost(user string, loggedIn, closed bool, post database.Post) bool {
+	return loggedIn && !closed && user == post.Poster
+}
+
+// canUploadFiles returns whether the user can upload files in general.
+// Uploading to a closed topic is never allowed.
+func canUploadFiles(loggedIn, isAdmin bool) bool {
+	return loggedIn && (config.EnableFileUpload || (config.EnableFileUploadAdmin && isAdmin))
+}
+
+// canDeleteFile returns whether the user can delete the file.
+// Files attached to conversations can only be deleted by the uploader.
+func canDeleteFile(user string, loggedIn, isAdmin bool, f files.File) bool {
+	if _, ok := conversationOfFile(f.Topic); ok {
+		return loggedIn && user == f.User
+	}
+	return isAdmin || (loggedIn && user == f.User)
+}
+
+// canAccessConversation returns whether the user can read and write in the conversation.
+// Only participants have access, this includes administrators.
+func canAccessConversation(user string, loggedIn bool, conversation database.Conversation) bool {
+	return loggedIn && slices.Contains(conversation.Participants, user)
+}
+
+// canAccessFile returns whether the user can download the file.
+// Files attached to conversations can only be accessed by the participants.
+func canAccessFile(user string, loggedIn bool, f files.File) (bool, error) {
+	id, ok := conversationOfFile(f.Topic)
+	if !ok {
+		return canRead(loggedIn), nil
+	}
+	if !loggedIn {
+		return false, nil
+	}
+	return database.IsConversationParticipant(id, user)
+}
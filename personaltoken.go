@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Top-Ranger/auth/data"
+	"github.com/Top-Ranger/discussiongo/authtoken"
+	"github.com/Top-Ranger/discussiongo/database"
+)
+
+// maxPersonalTokenDays is the maximal validity of a personal token with an expiry date.
+const maxPersonalTokenDays = 3650
+
+type personalTokenData struct {
+	Identifier string
+	Name       string
+	Scopes     string
+	Created    string
+	LastUsed   string
+	ValidUntil string
+}
+
+func init() {
+	http.HandleFunc("/newPersonalToken.html", newPersonalTokenHandleFunc)
+	http.HandleFunc("/revokePersonalToken.html", revokePersonalTokenHandleFunc)
+}
+
+// bearerToken returns the token of an 'Authorization: Bearer' header.
+// It returns an empty string if no such header is present.
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// TestPersonalToken is the equivalent of TestUser for personal tokens sent as 'Authorization: Bearer' header.
+// It returns whether a valid token was sent, the user of the token and the token itself.
+func TestPersonalToken(r *http.Request) (bool, string, authtoken.PersonalToken) {
+	token := bearerToken(r)
+	if token == "" {
+		return false, "", authtoken.PersonalToken{}
+	}
+
+	p, ok := authtoken.CheckPersonalToken(token)
+	if !ok {
+		return false, "", authtoken.PersonalToken{}
+	}
+	return true, p.User, p
+}
+
+// newPersonalTokenData converts a personal token into its template representation.
+func newPersonalTokenData(p authtoken.PersonalToken, t Translation) personalTokenData {
+	pd := personalTokenData{
+		Identifier: p.Identifier(),
+		Name:       p.Name,
+		Created:    p.Created.Format(time.RFC822),
+		LastUsed:   t.NeverUsed,
+		ValidUntil: t.Unlimited,
+	}
+
+	scopes := make([]string, len(p.Scopes))
+	for i := range p.Scopes {
+		switch p.Scopes[i] {
+		case authtoken.ScopeRead:
+			scopes[i] = t.ScopeRead
+		case authtoken.ScopePost:
+			scopes[i] = t.ScopePost
+		case authtoken.ScopeUpload:
+			scopes[i] = t.ScopeUpload
+		case authtoken.ScopeAdmin:
+			scopes[i] = t.ScopeAdmin
+		default:
+			scopes[i] = p.Scopes[i]
+		}
+	}
+	pd.Scopes = strings.Join(scopes, ", ")
+
+	if !p.LastUsed.IsZero() {
+		pd.LastUsed = p.LastUsed.Format(time.RFC822)
+	}
+	if !p.ValidUntil.IsZero() {
+		pd.ValidUntil = p.ValidUntil.Format(time.RFC822)
+	}
+	return pd
+}
+
+func newPersonalTokenHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	q := r.Form
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	name := q.Get("name")
+	if len(strings.TrimSpace(name)) == 0 {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	scopes := q["scope"]
+	if len(scopes) == 0 {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	for i := range scopes {
+		if scopes[i] == authtoken.ScopeAdmin && !isAdmin {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+
+	var validUntil time.Time
+	if d := strings.TrimSpace(q.Get("days")); d != "" {
+		days, err := strconv.Atoi(d)
+		if err != nil || days < 1 || days > maxPersonalTokenDays {
+			rw.WriteHeader(http.StatusBadRequest)
+			rw.Write([]byte(t.InvalidRequest))
+			return
+		}
+		validUntil = time.Now().AddDate(0, 0, days)
+	}
+
+	p, err := authtoken.NewPersonalToken(user, name, scopes, validUntil)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	// The token is only shown once since only its hash is saved
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.Write([]byte(fmt.Sprintf("%s (%s):\n%s\n\n%s\n\n%s%s/user.html#personaltokens\n", t.PersonalTokenCreated, p.Name, p.ID, t.PersonalTokenCreatedMessage, config.ServerPrefix, config.ServerPath)))
+}
+
+func revokePersonalTokenHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	q := r.Form
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	id := q.Get("id")
+	if id == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	ok, err := authtoken.DeletePersonalToken(user, id)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	if !ok {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/user.html#personaltokens", config.ServerPath), http.StatusFound)
+}
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021,2022,2024 Marcus Soll
+// Copyright 2020,2021,2022,2024,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -21,7 +21,10 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,41 +33,75 @@ import (
 	"github.com/Top-Ranger/discussiongo/database"
 	"github.com/Top-Ranger/discussiongo/events"
 	"github.com/Top-Ranger/discussiongo/files"
+	"github.com/Top-Ranger/discussiongo/notifications"
 
 	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 
 	"github.com/microcosm-cc/bluemonday"
 )
 
 type templatePostData struct {
-	ServerPath        string
-	ServerPrefix      string
-	ForumName         string
-	LoggedIn          bool
-	User              string
-	IsAdmin           bool
-	Topic             string
-	TopicID           string
-	Closed            bool
-	CanClose          bool
-	Pinned            bool
-	CanRename         bool
-	HasNew            bool
-	CanSaveFiles      bool
-	CurrentUpdate     int64
-	Timeline          []timelineData
-	Token             string
-	FileUploadMessage string
-	Translation       Translation
+	ServerPath          string
+	ServerPrefix        string
+	ForumName           string
+	LoggedIn            bool
+	User                string
+	IsAdmin             bool
+	Topic               string
+	TopicID             string
+	HasCategories       bool
+	Category            categoryData
+	Closed              bool
+	CanPost             bool
+	CanClose            bool
+	CanMove             bool
+	MoveCategories      []categoryData
+	Pinned              bool
+	CanRename           bool
+	Tags                []string
+	TagsValue           string
+	CanEditTags         bool
+	HasNew              bool
+	CanSaveFiles        bool
+	CurrentUpdate       int64
+	UnreadNotifications int
+	UnreadConversations int
+	HasPreviousPage     bool
+	HasNextPage         bool
+	PreviousPage        string
+	NextPage            string
+	FirstUnreadPost     string
+	Timeline            []timelineData
+	Token               string
+	FileUploadMessage   string
+	Translation         Translation
 }
 
+// timelineData is a single element of the timeline.
+// Gallery contains consecutive images shown together.
 type timelineData struct {
-	Time  time.Time
-	Post  *postData
-	File  *fileData
-	Event *eventData
+	Time    time.Time
+	Post    *postData
+	File    *fileData
+	Event   *eventData
+	Gallery []fileData
+}
+
+// timelineElementData is used to render a single element of the timeline.
+type timelineElementData struct {
+	Index   int
+	Element timelineData
+	Page    templatePostData
+}
+
+func newTimelineElementData(page templatePostData, index int, element timelineData) timelineElementData {
+	return timelineElementData{Index: index, Element: element, Page: page}
 }
 
 type postData struct {
@@ -77,6 +114,18 @@ type postData struct {
 	Creator    string
 	New        bool
 	CanDelete  bool
+	CanEdit    bool
+	LastEdited string
+}
+
+type postRevisionData struct {
+	Date string
+	Diff template.HTML
+}
+
+type postHistoryTemplateData struct {
+	History     []postRevisionData
+	Translation Translation
 }
 
 type fileData struct {
@@ -88,11 +137,26 @@ type fileData struct {
 	CanDelete bool
 	New       bool
 	Size      string
+	Thumbnail bool
+}
+
+// topicPage contains the posts of a single page of a topic timeline.
+// Files and events are shown on the page if they were created at or after Start and before End.
+// A zero End means the page is the last one.
+type topicPage struct {
+	Posts       []database.Post
+	HasPrevious bool
+	Start       time.Time
+	End         time.Time
 }
 
+// postsPerPage is the number of posts shown on a single page of a topic.
+const postsPerPage = 50
+
 var (
-	postTemplate *template.Template
-	policy       *bluemonday.Policy
+	postTemplate        *template.Template
+	postHistoryTemplate *template.Template
+	policy              *bluemonday.Policy
 )
 
 func init() {
@@ -105,12 +169,20 @@ func init() {
 	policy.AllowStandardURLs()
 	policy.AllowAttrs("href").OnElements("a")
 	policy.AllowAttrs("class").OnElements("code")
+	// Only images of the own topic are shown, see topicImageTransformer
+	policy.AllowAttrs("src").Matching(regexp.MustCompile(`^(/[^/?#:][^?#:]*)?/getFile\.html\?id=[0-9]+$`)).OnElements("img")
+	policy.AllowAttrs("alt", "title").OnElements("img")
 	policy.RequireNoReferrerOnLinks(true)
 	policy.AllowTables()
 	policy.AddTargetBlankToFullyQualifiedLinks(true)
 	policy.RequireNoFollowOnFullyQualifiedLinks(true)
 
-	postTemplate, err = template.New("posts").Funcs(evenOddFuncMap).ParseFS(templateFiles, "template/posts.html")
+	postTemplate, err = template.New("posts").Funcs(evenOddFuncMap).Funcs(template.FuncMap{"timelineElement": newTimelineElementData}).ParseFS(templateFiles, "template/posts.html", "template/timelineElement.html")
+	if err != nil {
+		panic(err)
+	}
+
+	postHistoryTemplate, err = template.ParseFS(templateFiles, "template/postHistory.html")
 	if err != nil {
 		panic(err)
 	}
@@ -118,12 +190,95 @@ func init() {
 	http.HandleFunc("/topic.html", postHandleFunc)
 	http.HandleFunc("/newPost.html", newPostHandleFunc)
 	http.HandleFunc("/deletePost.html", deletePostHandleFunc)
+	http.HandleFunc("/editPost.html", editPostHandleFunc)
 	http.HandleFunc("/getFormattedPost/", getFormattedPostHandleFunc)
+	http.HandleFunc("/postHistory.html", postHistoryHandleFunc)
+}
+
+// topicFiles contains the IDs of all files of a topic.
+type topicFiles map[string]bool
+
+// newTopicFiles returns the IDs of the files.
+func newTopicFiles(fs []files.File) topicFiles {
+	tf := make(topicFiles, len(fs))
+	for i := range fs {
+		tf[fs[i].ID] = true
+	}
+	return tf
+}
+
+// getTopicFiles returns the IDs of all files of a topic.
+func getTopicFiles(topic string) (topicFiles, error) {
+	fs, err := files.GetFileMetadataOfTopic(topic)
+	if err != nil {
+		return nil, err
+	}
+	return newTopicFiles(fs), nil
 }
 
+// topicImageTransformer removes all images from a post except those referencing a file of the topic.
+// This way, no remote content is loaded when a post is shown.
+type topicImageTransformer struct {
+	files topicFiles
+}
+
+// Transform implements parser.ASTTransformer.
+func (t topicImageTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	images := make([]*ast.Image, 0)
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if img, ok := n.(*ast.Image); ok && entering {
+			images = append(images, img)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, img := range images {
+		id, ok := localFileID(string(img.Destination))
+		if ok && t.files[id] {
+			img.Destination = []byte(fmt.Sprintf("%s/getFile.html?id=%s", config.ServerPath, id))
+			continue
+		}
+		img.Parent().RemoveChild(img.Parent(), img)
+	}
+}
+
+// localFileID returns the ID of the file if link points to a file of this forum.
+// Relative links as well as absolute links using the server prefix are supported.
+func localFileID(link string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", false
+	}
+
+	if u.Scheme != "" || u.Host != "" {
+		if fmt.Sprintf("%s://%s", u.Scheme, u.Host) != config.ServerPrefix {
+			return "", false
+		}
+	} else if u.Path == "getFile.html" {
+		u.Path = fmt.Sprintf("%s/getFile.html", config.ServerPath)
+	}
+
+	if u.Path != fmt.Sprintf("%s/getFile.html", config.ServerPath) {
+		return "", false
+	}
+
+	id := u.Query().Get("id")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+// formatPost renders the markdown of a post as sanitised HTML. Images are not shown.
 func formatPost(s string) template.HTML {
+	return formatTopicPost(s, nil)
+}
+
+// formatTopicPost renders the markdown of a post as sanitised HTML.
+// Images are only shown if they reference one of the files.
+func formatTopicPost(s string, tf topicFiles) template.HTML {
 	var buf bytes.Buffer
-	md := goldmark.New(goldmark.WithExtensions(extension.GFM), goldmark.WithRendererOptions(html.WithHardWraps()))
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM), goldmark.WithParserOptions(parser.WithASTTransformers(util.Prioritized(topicImageTransformer{files: tf}, 1000))), goldmark.WithRendererOptions(html.WithHardWraps()))
 	err := md.Convert([]byte(s), &buf)
 	if err != nil {
 		return template.HTML(policy.Sanitize(fmt.Sprintf("Error rendering markdown: %s", err.Error())))
@@ -134,7 +289,7 @@ func formatPost(s string) template.HTML {
 func postHandleFunc(rw http.ResponseWriter, r *http.Request) {
 	loggedIn, user := TestUser(r, rw)
 
-	if !config.CanReadWithoutRegister && !loggedIn {
+	if !canRead(loggedIn) {
 		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
 		return
 	}
@@ -157,6 +312,16 @@ func postHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	translation := GetDefaultTranslation()
+
+	// Read before the content so that changes in between cause a reload
+	currentUpdate, err := database.GetLastUpdateTopic(id)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
 	topic, err := database.GetTopic(id)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -164,27 +329,93 @@ func postHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	posts, err := database.GetPosts(id)
+	if loggedIn && q.Get("unread") == "1" {
+		l, err := accesstimes.GetTimes(user, []string{topic.ID})
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		unread, found, err := database.GetFirstPostAfter(topic.ID, l[0])
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		if found {
+			http.Redirect(rw, r, fmt.Sprintf("%s%s", config.ServerPath, postPath(topic.ID, unread.ID)), http.StatusFound)
+			return
+		}
+		http.Redirect(rw, r, fmt.Sprintf("%s/topic.html?id=%s&page=last#bot", config.ServerPath, url.QueryEscape(topic.ID)), http.StatusFound)
+		return
+	}
+
+	category, err := database.GetCategory(topic.Category)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
-	fs, err := files.GetFileMetadataOfTopic(id)
+	categories, err := database.GetCategories()
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
-	events, err := events.GetEventsOfTopic(id)
+	tags, err := database.GetTopicTags(id)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
+	page, err := loadTopicPage(id, q)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	posts := page.Posts
+
+	fs, err := files.GetFileMetadataOfTopicBetween(id, page.Start, page.End)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	// Posts can show images of all pages
+	tf, err := getTopicFiles(id)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	events, err := events.GetEventsOfTopicBetween(id, page.Start, page.End)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	revisions := make([]database.PostRevision, 0)
+	if len(posts) != 0 {
+		revisions, err = database.GetPostRevisionsOfPostsBetween(id, posts[0].Time, posts[len(posts)-1].Time)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	revisionMap := make(map[string][]database.PostRevision)
+	for i := range revisions {
+		revisionMap[revisions[i].PostID] = append(revisionMap[revisions[i].PostID], revisions[i])
+	}
+
 	td := templatePostData{
 		ServerPath:        config.ServerPath,
 		ServerPrefix:      config.ServerPrefix,
@@ -194,16 +425,42 @@ func postHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		IsAdmin:           isAdmin,
 		Topic:             topic.Name,
 		TopicID:           id,
+		HasCategories:     len(categories) != 0,
+		Category:          newCategoryData(category, translation),
 		Closed:            topic.Closed,
-		CanClose:          ((loggedIn && config.EveryoneCanCloseAndOpenTopics) || isAdmin || user == topic.Creator),
+		CanPost:           canPost(loggedIn, isAdmin, topic, category),
+		CanClose:          canCloseTopic(user, loggedIn, isAdmin, topic),
+		CanMove:           canMoveTopic(isAdmin) && len(categories) != 0,
 		Pinned:            topic.Pinned,
-		CanRename:         (isAdmin || user == topic.Creator),
+		CanRename:         canRenameTopic(user, loggedIn, isAdmin, topic),
+		Tags:              tags,
+		TagsValue:         strings.Join(tags, ", "),
+		CanEditTags:       canEditTopicTags(user, loggedIn, isAdmin, topic),
 		HasNew:            false,
-		CanSaveFiles:      config.EnableFileUpload || (isAdmin && config.EnableFileUploadAdmin),
-		CurrentUpdate:     database.GetLastUpdateTopicPost(),
+		CanSaveFiles:      canUploadFiles(loggedIn, isAdmin),
+		CurrentUpdate:     currentUpdate,
+		HasPreviousPage:   page.HasPrevious,
+		HasNextPage:       !page.End.IsZero(),
 		Timeline:          make([]timelineData, 0, len(posts)+len(fs)+len(events)),
 		FileUploadMessage: config.FileUploadMessage,
-		Translation:       GetDefaultTranslation(),
+		Translation:       translation,
+	}
+
+	if len(posts) != 0 {
+		td.PreviousPage = postCursor(posts[0])
+		td.NextPage = postCursor(posts[len(posts)-1])
+	}
+
+	if td.CanMove {
+		td.MoveCategories = make([]categoryData, 0, len(categories)+1)
+		for i := range categories {
+			c := newCategoryData(categories[i], translation)
+			c.Selected = c.ID == category.ID
+			td.MoveCategories = append(td.MoveCategories, c)
+		}
+		c := newCategoryData(database.Category{ID: database.NoCategory}, translation)
+		c.Selected = category.ID == database.NoCategory
+		td.MoveCategories = append(td.MoveCategories, c)
 	}
 
 	var lastUpdate time.Time
@@ -217,6 +474,20 @@ func postHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		}
 		td.Token = token
 
+		td.UnreadNotifications, err = notifications.CountUnread(user)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+
+		td.UnreadConversations, err = database.CountUnreadConversations(user)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+
 		u, err := database.GetUser(user)
 		if err != nil {
 			rw.WriteHeader(http.StatusInternalServerError)
@@ -231,26 +502,33 @@ func postHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		}
 		lastUpdate = l[0]
 
-		err = accesstimes.SaveTime(user, topic.ID, time.Now())
+		unread, found, err := database.GetFirstPostAfter(topic.ID, lastUpdate)
 		if err != nil {
 			rw.WriteHeader(http.StatusInternalServerError)
 			rw.Write([]byte(err.Error()))
 			return
 		}
+		if found {
+			td.FirstUnreadPost = unread.ID
+		}
+
+		// Only the content up to the end of the page has been read
+		readUntil := time.Now()
+		if !page.End.IsZero() {
+			readUntil = page.End.Add(-time.Second)
+		}
+		if readUntil.After(lastUpdate) {
+			err = accesstimes.SaveTime(user, topic.ID, readUntil)
+			if err != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+				rw.Write([]byte(err.Error()))
+				return
+			}
+		}
 	}
 
 	for i := range posts {
-		p := postData{
-			ID:         posts[i].ID,
-			TID:        posts[i].TopicID,
-			TName:      "unimportant",
-			Content:    formatPost(posts[i].Content),
-			RawContent: posts[i].Content,
-			Date:       posts[i].Time.Format(time.RFC822),
-			Creator:    posts[i].Poster,
-			New:        false,
-			CanDelete:  (isAdmin || user == posts[i].Poster),
-		}
+		p := newPostData(posts[i], revisionMap[posts[i].ID], tf, user, loggedIn, isAdmin, topic.Closed)
 		if loggedIn {
 			if lastUpdate.Before(posts[i].Time) {
 				p.New = true
@@ -264,15 +542,7 @@ func postHandleFunc(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	for i := range fs {
-		f := fileData{
-			ID:        fs[i].ID,
-			Name:      fs[i].Name,
-			User:      fs[i].User,
-			Date:      fs[i].Date.Format(time.RFC822),
-			CanDelete: (isAdmin || user == fs[i].User),
-			New:       false,
-			Size:      fileLengthToString(int(fs[i].Length)),
-		}
+		f := newFileData(fs[i], user, loggedIn, isAdmin)
 		if loggedIn {
 			if lastUpdate.Before(fs[i].Date) {
 				f.New = true
@@ -300,6 +570,7 @@ func postHandleFunc(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	sort.Slice(td.Timeline, func(i, j int) bool { return td.Timeline[i].Time.Before(td.Timeline[j].Time) })
+	td.Timeline = groupImages(td.Timeline)
 
 	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 
@@ -309,6 +580,206 @@ func postHandleFunc(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// groupImages combines consecutive images of a sorted timeline into galleries.
+func groupImages(timeline []timelineData) []timelineData {
+	grouped := make([]timelineData, 0, len(timeline))
+	for i := 0; i < len(timeline); {
+		j := i
+		for j < len(timeline) && timeline[j].File != nil && timeline[j].File.Thumbnail {
+			j++
+		}
+		if j-i < 2 {
+			grouped = append(grouped, timeline[i])
+			i++
+			continue
+		}
+
+		gallery := make([]fileData, 0, j-i)
+		for k := i; k < j; k++ {
+			gallery = append(gallery, *timeline[k].File)
+		}
+		grouped = append(grouped, timelineData{Time: timeline[i].Time, Gallery: gallery})
+		i = j
+	}
+	return grouped
+}
+
+// loadTopicPage returns the page of a topic requested by the query.
+// Pages are navigated with the keyset cursors "after" and "before" (see postCursor).
+// "post" and "file" select the page containing the element, "page=last" the last page. Otherwise, the first page is returned.
+func loadTopicPage(topicID string, q url.Values) (topicPage, error) {
+	if t, postID, ok := parsePostCursor(q.Get("after")); ok {
+		posts, err := database.GetPostsAfter(topicID, t, postID, postsPerPage+1)
+		if err != nil {
+			return topicPage{}, err
+		}
+		if len(posts) != 0 {
+			return newTopicPage(posts, true), nil
+		}
+		// The cursor is behind the last post
+		return loadTopicPageAt(topicID, -1)
+	}
+
+	if t, postID, ok := parsePostCursor(q.Get("before")); ok {
+		posts, err := database.GetPostsBefore(topicID, t, postID, postsPerPage+1)
+		if err != nil {
+			return topicPage{}, err
+		}
+		if len(posts) != 0 {
+			hasPrevious := len(posts) > postsPerPage
+			if hasPrevious {
+				posts = posts[1:]
+			}
+			page := newTopicPage(posts, hasPrevious)
+			page.End = t
+			return page, nil
+		}
+		return loadTopicPageAt(topicID, 0)
+	}
+
+	if postID := q.Get("post"); postID != "" {
+		p, err := database.GetSinglePost(postID)
+		if err == nil && p.TopicID == topicID {
+			count, err := database.CountPostsBefore(topicID, p.Time, p.ID)
+			if err != nil {
+				return topicPage{}, err
+			}
+			return loadTopicPageAt(topicID, count)
+		}
+	}
+
+	if fileID := q.Get("file"); fileID != "" {
+		f, err := files.GetFileMetadata(fileID)
+		if err == nil && f.Topic == topicID {
+			// The file is shown together with the last post created before it
+			count, err := database.CountPostsUntil(topicID, f.Date)
+			if err != nil {
+				return topicPage{}, err
+			}
+			return loadTopicPageAt(topicID, count-1)
+		}
+	}
+
+	if q.Get("page") == "last" {
+		return loadTopicPageAt(topicID, -1)
+	}
+
+	return loadTopicPageAt(topicID, 0)
+}
+
+// loadTopicPageAt returns the page containing the post at the given position (starting with 0).
+// A negative position selects the last page.
+func loadTopicPageAt(topicID string, position int) (topicPage, error) {
+	if position < 0 {
+		count, err := database.CountPosts(topicID)
+		if err != nil {
+			return topicPage{}, err
+		}
+		position = count - 1
+	}
+
+	if position < postsPerPage {
+		posts, err := database.GetPostsAfter(topicID, time.Time{}, "0", postsPerPage+1)
+		if err != nil {
+			return topicPage{}, err
+		}
+		return newTopicPage(posts, false), nil
+	}
+
+	// Use the last post of the previous page as the cursor so that pages always start at a multiple of postsPerPage
+	previous, err := database.GetPostAtPosition(topicID, position-position%postsPerPage-1)
+	if err != nil {
+		return topicPage{}, err
+	}
+	posts, err := database.GetPostsAfter(topicID, previous.Time, previous.ID, postsPerPage+1)
+	if err != nil {
+		return topicPage{}, err
+	}
+	return newTopicPage(posts, true), nil
+}
+
+// newTopicPage creates a page from posts, which may contain the first post of the next page as an additional element.
+func newTopicPage(posts []database.Post, hasPrevious bool) topicPage {
+	page := topicPage{Posts: posts, HasPrevious: hasPrevious}
+	if len(posts) > postsPerPage {
+		page.Posts = posts[:postsPerPage]
+		page.End = posts[postsPerPage].Time
+	}
+	if hasPrevious && len(page.Posts) != 0 {
+		page.Start = page.Posts[0].Time
+	}
+	return page
+}
+
+// postCursor returns the position of a post, which is used as a cursor for the pages of a topic.
+func postCursor(p database.Post) string {
+	return fmt.Sprintf("%d_%s", p.Time.Unix(), p.ID)
+}
+
+// parsePostCursor parses a cursor created by postCursor.
+func parsePostCursor(s string) (time.Time, string, bool) {
+	unix, id, ok := strings.Cut(s, "_")
+	if !ok {
+		return time.Time{}, "", false
+	}
+	t, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	_, err = strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return time.Unix(t, 0), id, true
+}
+
+// postPath returns the path (without server path) to a post.
+// The post ID is part of the query so that the page containing the post is shown.
+func postPath(topicID, postID string) string {
+	return fmt.Sprintf("/topic.html?id=%s&post=%s#post%s", url.QueryEscape(topicID), url.QueryEscape(postID), url.QueryEscape(postID))
+}
+
+// filePath returns the path (without server path) to a file in the timeline of a topic.
+func filePath(topicID, fileID string) string {
+	return fmt.Sprintf("/topic.html?id=%s&file=%s#file%s", url.QueryEscape(topicID), url.QueryEscape(fileID), url.QueryEscape(fileID))
+}
+
+// newPostData converts a post into its template representation.
+// revisions must be the revisions of the post as returned by database.GetPostRevisions, tf the files of the topic.
+func newPostData(post database.Post, revisions []database.PostRevision, tf topicFiles, user string, loggedIn, isAdmin, closed bool) postData {
+	p := postData{
+		ID:         post.ID,
+		TID:        post.TopicID,
+		TName:      "unimportant",
+		Content:    formatTopicPost(post.Content, tf),
+		RawContent: post.Content,
+		Date:       post.Time.Format(time.RFC822),
+		Creator:    post.Poster,
+		New:        false,
+		CanDelete:  canDeletePost(user, loggedIn, isAdmin, post),
+		CanEdit:    canEditPost(user, loggedIn, closed, post),
+	}
+	if len(revisions) != 0 {
+		// The history is loaded through postHistoryHandleFunc when it is opened
+		p.LastEdited = revisions[len(revisions)-1].Replaced.Format(time.RFC822)
+	}
+	return p
+}
+
+// newFileData converts the metadata of a file into its template representation.
+func newFileData(f files.File, user string, loggedIn, isAdmin bool) fileData {
+	return fileData{
+		ID:        f.ID,
+		Name:      f.Name,
+		User:      f.User,
+		Date:      f.Date.Format(time.RFC822),
+		CanDelete: canDeleteFile(user, loggedIn, isAdmin, f),
+		New:       false,
+		Size:      fileLengthToString(int(f.Length)),
+		Thumbnail: f.HasThumbnail(),
+	}
+}
+
 func newPostHandleFunc(rw http.ResponseWriter, r *http.Request) {
 	t := GetDefaultTranslation()
 	loggedIn, user := TestUser(r, rw)
@@ -344,9 +815,27 @@ func newPostHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		rw.Write([]byte(err.Error()))
 		return
 	}
-	if topic.Closed {
+	category, err := database.GetCategory(topic.Category)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !canPost(loggedIn, isAdmin, topic, category) {
 		rw.WriteHeader(http.StatusForbidden)
-		rw.Write([]byte(t.TopicIsClosed))
+		if topic.Closed {
+			rw.Write([]byte(t.TopicIsClosed))
+		} else {
+			rw.Write([]byte(t.AdminOnlyCategoryMessage))
+		}
 		return
 	}
 
@@ -361,28 +850,78 @@ func newPostHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	postID, err := database.AddPost(id, user, post)
+	postID, err := createPost(user, id, post)
 	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s%s", config.ServerPath, postPath(id, postID)), http.StatusFound)
+}
+
+func deletePostHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	q := r.URL.Query()
+	id := q.Get("id")
+	if id == "" {
 		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
 		return
 	}
 
-	err = database.ModifyLastSeen(user)
+	tid := q.Get("tid")
+	if tid == "" {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	token := q.Get("token")
+	if token == "" {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	post, err := database.GetSinglePost(id)
 	if err != nil {
-		log.Println("Can not modify last seen:", err)
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	if !canDeletePost(user, loggedIn, isAdmin, post) {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
 	}
 
-	err = database.TopicModifyTime(id)
+	err = deletePost(user, post)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
-	http.Redirect(rw, r, fmt.Sprintf("%s/topic.html?id=%s#post%s", config.ServerPath, id, postID), http.StatusFound)
+	http.Redirect(rw, r, fmt.Sprintf("%s/topic.html?id=%s", config.ServerPath, tid), http.StatusFound)
 }
 
-func deletePostHandleFunc(rw http.ResponseWriter, r *http.Request) {
+func editPostHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
 	loggedIn, user := TestUser(r, rw)
 
 	if !loggedIn {
@@ -390,22 +929,22 @@ func deletePostHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	isAdmin, err := database.IsAdmin(user)
+	err := r.ParseForm()
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
-	q := r.URL.Query()
-	id := q.Get("id")
-	if id == "" {
+	q := r.Form
+	content := q.Get("post")
+	if len(strings.TrimSpace(content)) == 0 {
 		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
 		return
 	}
 
-	tid := q.Get("tid")
-	if tid == "" {
+	id := q.Get("id")
+	if id == "" {
 		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
 		return
 	}
@@ -427,37 +966,183 @@ func deletePostHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !isAdmin && user != post.Poster {
+	if user != post.Poster {
 		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
 		return
 	}
 
-	err = database.DeletePost(tid, id)
+	topic, err := database.GetTopic(post.TopicID)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
+	if topic.Closed {
+		rw.WriteHeader(http.StatusForbidden)
+		rw.Write([]byte(t.TopicIsClosed))
+		return
+	}
 
-	_, err = events.SaveEvent(events.Event{
-		Type:  EventPostDeleted,
+	if content == post.Content {
+		http.Redirect(rw, r, fmt.Sprintf("%s%s", config.ServerPath, postPath(post.TopicID, post.ID)), http.StatusFound)
+		return
+	}
+
+	err = database.EditPost(post.TopicID, post.ID, content)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	_, err = saveEvent(events.Event{
+		Type:  EventPostEdited,
 		User:  user,
 		Topic: post.TopicID,
-		Date:  post.Time,
+		Date:  time.Now(),
+		Data:  []byte(post.ID),
 	})
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
 
+	err = database.TopicModifyTime(post.TopicID)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
+	http.Redirect(rw, r, fmt.Sprintf("%s%s", config.ServerPath, postPath(post.TopicID, post.ID)), http.StatusFound)
+}
+
+// createPost adds a new post to a topic and notifies all mentioned users.
+// It returns the ID of the new post. Permissions must be checked by the caller.
+func createPost(user, topicID, content string) (string, error) {
+	postID, err := database.AddPost(topicID, user, content)
+	if err != nil {
+		return "", err
+	}
+
 	err = database.ModifyLastSeen(user)
 	if err != nil {
 		log.Println("Can not modify last seen:", err)
 	}
 
-	http.Redirect(rw, r, fmt.Sprintf("%s/topic.html?id=%s", config.ServerPath, tid), http.StatusFound)
+	err = database.TopicModifyTime(topicID)
+	if err != nil {
+		return "", err
+	}
+
+	err = notifyMentions(user, topicID, postID, content)
+	if err != nil {
+		log.Println("Can not save mentions:", err)
+	}
+
+	triggerWebhook(webhookPostCreated, webhookPostData{
+		ID:      postID,
+		Topic:   topicID,
+		Poster:  user,
+		Content: content,
+		URL:     absoluteURL(postPath(topicID, postID)),
+	})
+	return postID, nil
+}
+
+// deletePost removes a post together with its notifications and saves the corresponding event.
+// Permissions must be checked by the caller.
+func deletePost(user string, post database.Post) error {
+	err := database.DeletePost(post.TopicID, post.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = notifications.DeletePostNotifications(post.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = saveEvent(events.Event{
+		Type:  EventPostDeleted,
+		User:  user,
+		Topic: post.TopicID,
+		Date:  post.Time,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+	return nil
+}
+
+// postHistory returns the changes between all revisions of a post, newest change first.
+// revisions must be sorted by time (oldest first), current is the current content of the post.
+func postHistory(revisions []database.PostRevision, current string) []postRevisionData {
+	history := make([]postRevisionData, 0, len(revisions))
+	for i := len(revisions) - 1; i >= 0; i-- {
+		next := current
+		if i+1 < len(revisions) {
+			next = revisions[i+1].Content
+		}
+		history = append(history, postRevisionData{
+			Date: revisions[i].Replaced.Format(time.RFC822),
+			Diff: diffToHTML(revisions[i].Content, next),
+		})
+	}
+	return history
+}
+
+// postHistoryHandleFunc renders the changes between all revisions of a post.
+// It is loaded into the topic page when the history of a post is opened.
+func postHistoryHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	loggedIn, _ := TestUser(r, rw)
+
+	if !canRead(loggedIn) {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	post, err := database.GetSinglePost(id)
+	if err != nil {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	revisions, err := database.GetPostRevisions(post.ID)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	td := postHistoryTemplateData{
+		History:     postHistory(revisions, post.Content),
+		Translation: GetDefaultTranslation(),
+	}
+
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	err = postHistoryTemplate.Execute(rw, td)
+	if err != nil {
+		log.Println("Error executing post history template:", err)
+	}
 }
 
 func getFormattedPostHandleFunc(rw http.ResponseWriter, r *http.Request) {
@@ -497,7 +1182,18 @@ func getFormattedPostHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rw.Write([]byte(formatPost(post)))
+	// Posts of a topic can show images of the topic
+	var tf topicFiles
+	if topic := q.Get("topic"); topic != "" {
+		tf, err = getTopicFiles(topic)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	rw.Write([]byte(formatTopicPost(post, tf)))
 }
 
 func fileLengthToString(length int) string {
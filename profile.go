@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021,2022,2024 Marcus Soll
+// Copyright 2020,2021,2022,2024,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -27,15 +27,16 @@ import (
 )
 
 type templateProfileData struct {
-	ServerPath  string
-	ForumName   string
-	User        string
-	Comment     template.HTML
-	HasComment  bool
-	Topics      []topicData
-	Posts       []postData
-	Files       []fileData
-	Translation Translation
+	ServerPath     string
+	ForumName      string
+	User           string
+	Comment        template.HTML
+	HasComment     bool
+	CanSendMessage bool
+	Topics         []topicData
+	Posts          []postData
+	Files          []fileData
+	Translation    Translation
 }
 
 var (
@@ -54,10 +55,11 @@ func init() {
 }
 
 func profileHandleFunc(rw http.ResponseWriter, r *http.Request) {
-	loggedIn, _ := TestUser(r, rw)
+	loggedIn, user := TestUser(r, rw)
 
-	if !config.CanReadWithoutRegister && !loggedIn {
+	if !canRead(loggedIn) {
 		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
 	}
 
 	q := r.URL.Query()
@@ -94,17 +96,19 @@ func profileHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		rw.Write([]byte(err.Error()))
 		return
 	}
+	files = withoutConversationFiles(files)
 
 	td := templateProfileData{
-		ServerPath:  config.ServerPath,
-		ForumName:   config.ForumName,
-		User:        u.Name,
-		Comment:     formatPost(u.Comment),
-		HasComment:  u.Comment != "",
-		Topics:      make([]topicData, 0, len(topics)),
-		Posts:       make([]postData, 0, len(posts)),
-		Files:       make([]fileData, 0, len(files)),
-		Translation: GetDefaultTranslation(),
+		ServerPath:     config.ServerPath,
+		ForumName:      config.ForumName,
+		User:           u.Name,
+		Comment:        formatPost(u.Comment),
+		HasComment:     u.Comment != "",
+		CanSendMessage: loggedIn && user != u.Name,
+		Topics:         make([]topicData, 0, len(topics)),
+		Posts:          make([]postData, 0, len(posts)),
+		Files:          make([]fileData, 0, len(files)),
+		Translation:    GetDefaultTranslation(),
 	}
 
 	for i := range topics {
@@ -127,11 +131,17 @@ func profileHandleFunc(rw http.ResponseWriter, r *http.Request) {
 			rw.Write([]byte(err.Error()))
 			return
 		}
+		tf, err := getTopicFiles(posts[i].TopicID)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
 		td.Posts = append(td.Posts, postData{
 			ID:         posts[i].ID,
 			TID:        posts[i].TopicID,
 			TName:      t.Name,
-			Content:    formatPost(posts[i].Content),
+			Content:    formatTopicPost(posts[i].Content, tf),
 			RawContent: posts[i].Content,
 			Date:       posts[i].Time.Format(time.RFC822),
 			Creator:    posts[i].Poster,
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/Top-Ranger/discussiongo/ratelimit"
+)
+
+var (
+	ipLimiter           *ratelimit.Limiter
+	accountLimiter      *ratelimit.Limiter
+	registrationLimiter *ratelimit.Limiter
+)
+
+// initRateLimit creates the rate limiter for IP addresses, accounts and registrations.
+// It must be called before the server is started.
+func initRateLimit(c rateLimitConfig) error {
+	durations := []struct {
+		name  string
+		value string
+		d     time.Duration
+	}{
+		{name: "BaseDelay", value: c.BaseDelay},
+		{name: "MaxDelay", value: c.MaxDelay},
+		{name: "LockoutDuration", value: c.LockoutDuration},
+		{name: "ResetAfter", value: c.ResetAfter},
+	}
+
+	for i := range durations {
+		d, err := time.ParseDuration(durations[i].value)
+		if err != nil {
+			return fmt.Errorf("can not parse RateLimit.%s: %w", durations[i].name, err)
+		}
+		if d < 0 {
+			return fmt.Errorf("RateLimit.%s (%s) is negative", durations[i].name, d.String())
+		}
+		durations[i].d = d
+	}
+
+	if c.MaxFailuresIP < 0 || c.MaxFailuresAccount < 0 || c.MaxRegistrationsIP < 0 {
+		return fmt.Errorf("RateLimit.MaxFailuresIP, RateLimit.MaxFailuresAccount and RateLimit.MaxRegistrationsIP must not be negative")
+	}
+
+	rc := ratelimit.Config{
+		BaseDelay:  durations[0].d,
+		MaxDelay:   durations[1].d,
+		Lockout:    durations[2].d,
+		ResetAfter: durations[3].d,
+	}
+
+	rc.MaxFailures = c.MaxFailuresIP
+	ipLimiter = ratelimit.New(rc)
+	rc.MaxFailures = c.MaxFailuresAccount
+	accountLimiter = ratelimit.New(rc)
+	rc.MaxFailures = c.MaxRegistrationsIP
+	registrationLimiter = ratelimit.New(rc)
+	return nil
+}
+
+// rateLimited reports whether the client or the account (if not empty) is currently blocked.
+// If so, an error is written to rw and no further processing should be done.
+func rateLimited(rw http.ResponseWriter, r *http.Request, account string) bool {
+	now := time.Now()
+	d := ipLimiter.Blocked(GetRealIP(r), now)
+	if account != "" {
+		if a := accountLimiter.Blocked(account, now); a > d {
+			d = a
+		}
+	}
+
+	if d <= 0 {
+		return false
+	}
+
+	writeRateLimited(rw, d)
+	return true
+}
+
+// registrationLimited records a registration attempt of the client and reports whether the client is blocked.
+// Every attempt is counted, not only failed ones, so that a single client can not create an unlimited number of accounts.
+// If the client is blocked, an error is written to rw, the attempt is not counted and no further processing should be done.
+func registrationLimited(rw http.ResponseWriter, r *http.Request) bool {
+	now := time.Now()
+	ip := GetRealIP(r)
+	d := registrationLimiter.Blocked(ip, now)
+	if d <= 0 {
+		registrationLimiter.Fail(ip, now)
+		return false
+	}
+
+	writeRateLimited(rw, d)
+	return true
+}
+
+// writeRateLimited writes an error to rw telling the client to retry after d.
+func writeRateLimited(rw http.ResponseWriter, d time.Duration) {
+	seconds := int(math.Ceil(d.Seconds()))
+	t := GetDefaultTranslation()
+	rw.Header().Set("Retry-After", fmt.Sprint(seconds))
+	rw.WriteHeader(http.StatusTooManyRequests)
+	rw.Write([]byte(fmt.Sprintf(t.TooManyAttempts, time.Duration(seconds)*time.Second)))
+}
+
+// failedAttempt records a failed attempt of the client and the account (if not empty).
+func failedAttempt(r *http.Request, account string) {
+	now := time.Now()
+	ipLimiter.Fail(GetRealIP(r), now)
+	if account != "" {
+		accountLimiter.Fail(account, now)
+	}
+}
+
+// successfulAttempt resets the failed attempts of an account.
+// The failed attempts of the client are kept so that an attacker can not reset them with an own account.
+func successfulAttempt(account string) {
+	accountLimiter.Reset(account)
+}
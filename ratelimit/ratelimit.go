@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit keeps track of failed attempts (e.g. logins) and blocks keys with too many failures.
+// After every failure, a key is blocked for an exponentially growing delay.
+// Once the maximum number of failures is reached, the key is locked for a longer time.
+// All data is kept in memory and is lost on restart.
+package ratelimit
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Config holds the thresholds of a Limiter.
+type Config struct {
+	// MaxFailures is the number of failures after which a key is locked.
+	MaxFailures int
+	// BaseDelay is the time a key is blocked after the first failure. It doubles with every further failure.
+	BaseDelay time.Duration
+	// MaxDelay is the maximal time a key is blocked before it is locked.
+	MaxDelay time.Duration
+	// Lockout is the time a key is locked.
+	Lockout time.Duration
+	// ResetAfter is the time without failures after which all failures of a key are forgotten.
+	ResetAfter time.Duration
+}
+
+// Entry represents the state of a single key.
+type Entry struct {
+	Key          string
+	Failures     int
+	LastFailure  time.Time
+	BlockedUntil time.Time
+	Locked       bool
+}
+
+// sweepThreshold is the minimal number of entries before expired entries are removed in Fail.
+const sweepThreshold = 1024
+
+// Limiter keeps track of failures of keys.
+// It is safe for concurrent use.
+type Limiter struct {
+	config    Config
+	mutex     sync.Mutex
+	entries   map[string]*Entry
+	nextSweep int
+}
+
+// New returns a new Limiter using the given config.
+func New(c Config) *Limiter {
+	return &Limiter{
+		config:    c,
+		entries:   make(map[string]*Entry),
+		nextSweep: sweepThreshold,
+	}
+}
+
+// delay returns the time a key is blocked after the given number of failures.
+func (l *Limiter) delay(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	if l.config.MaxFailures > 0 && failures >= l.config.MaxFailures {
+		return l.config.Lockout
+	}
+	d := l.config.BaseDelay
+	for i := 1; i < failures; i++ {
+		d *= 2
+		if d >= l.config.MaxDelay {
+			return l.config.MaxDelay
+		}
+	}
+	if d > l.config.MaxDelay {
+		return l.config.MaxDelay
+	}
+	return d
+}
+
+// get returns the entry of a key.
+// Expired entries are removed and nil is returned.
+// The mutex must be held by the caller.
+func (l *Limiter) get(key string, now time.Time) *Entry {
+	e, ok := l.entries[key]
+	if !ok {
+		return nil
+	}
+	if now.After(e.BlockedUntil) && now.Sub(e.LastFailure) >= l.config.ResetAfter {
+		delete(l.entries, key)
+		return nil
+	}
+	return e
+}
+
+// Blocked returns the remaining time a key is blocked.
+// If the key is not blocked, 0 is returned.
+func (l *Limiter) Blocked(key string, now time.Time) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	e := l.get(key, now)
+	if e == nil || !e.BlockedUntil.After(now) {
+		return 0
+	}
+	return e.BlockedUntil.Sub(now)
+}
+
+// Fail records a failure of a key.
+// It returns the time the key is blocked now.
+func (l *Limiter) Fail(key string, now time.Time) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	e := l.get(key, now)
+	if e == nil {
+		if len(l.entries) >= l.nextSweep {
+			l.sweep(now)
+		}
+		e = &Entry{Key: key}
+		l.entries[key] = e
+	}
+
+	e.Failures++
+	e.LastFailure = now
+	d := l.delay(e.Failures)
+	e.Locked = l.config.MaxFailures > 0 && e.Failures >= l.config.MaxFailures
+	if now.Add(d).After(e.BlockedUntil) {
+		e.BlockedUntil = now.Add(d)
+	}
+	return e.BlockedUntil.Sub(now)
+}
+
+// sweep removes all expired entries.
+// Entries are otherwise only removed when their key is used again, so keys which are never seen again (e.g. random user names) would be kept forever.
+// To keep the costs of Fail amortised constant, the next sweep happens once the number of entries has doubled.
+// The mutex must be held by the caller.
+func (l *Limiter) sweep(now time.Time) {
+	for k := range l.entries {
+		l.get(k, now)
+	}
+	l.nextSweep = max(sweepThreshold, 2*len(l.entries))
+}
+
+// Reset forgets all failures of a key.
+func (l *Limiter) Reset(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.entries, key)
+}
+
+// Locked returns all keys which are currently locked, sorted by key.
+// Keys which are only blocked because of the delay between failures are not returned.
+func (l *Limiter) Locked(now time.Time) []Entry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	locked := make([]Entry, 0)
+	for k := range l.entries {
+		e := l.get(k, now)
+		if e == nil || !e.Locked || !e.BlockedUntil.After(now) {
+			continue
+		}
+		locked = append(locked, *e)
+	}
+	sort.Slice(locked, func(i, j int) bool { return locked[i].Key < locked[j].Key })
+	return locked
+}
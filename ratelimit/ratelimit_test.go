@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+var testConfig = Config{
+	MaxFailures: 5,
+	BaseDelay:   time.Second,
+	MaxDelay:    5 * time.Second,
+	Lockout:     time.Minute,
+	ResetAfter:  time.Hour,
+}
+
+func TestDelay(t *testing.T) {
+	tests := []struct {
+		config   Config
+		failures int
+		want     time.Duration
+	}{
+		{config: testConfig, failures: 0, want: 0},
+		{config: testConfig, failures: 1, want: time.Second},
+		{config: testConfig, failures: 2, want: 2 * time.Second},
+		{config: testConfig, failures: 3, want: 4 * time.Second},
+		{config: testConfig, failures: 4, want: 5 * time.Second},
+		{config: testConfig, failures: 5, want: time.Minute},
+		{config: testConfig, failures: 100, want: time.Minute},
+		// Without MaxFailures, keys are never locked
+		{config: Config{BaseDelay: time.Second, MaxDelay: time.Hour}, failures: 100, want: time.Hour},
+		{config: Config{BaseDelay: 10 * time.Second, MaxDelay: time.Second}, failures: 1, want: time.Second},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%v/%d", tc.config, tc.failures), func(t *testing.T) {
+			got := New(tc.config).delay(tc.failures)
+			if got != tc.want {
+				t.Fatalf("got %s, expected %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFailBlocked(t *testing.T) {
+	l := New(testConfig)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if d := l.Blocked("a", now); d != 0 {
+		t.Fatalf("unknown key blocked for %s", d)
+	}
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if d := l.Fail("a", now); d != want {
+			t.Fatalf("failure %d: got %s, expected %s", i+1, d, want)
+		}
+		if d := l.Blocked("a", now); d != want {
+			t.Fatalf("failure %d: blocked for %s, expected %s", i+1, d, want)
+		}
+		if d := l.Blocked("a", now.Add(want)); d != 0 {
+			t.Fatalf("failure %d: still blocked for %s after delay", i+1, d)
+		}
+	}
+
+	if d := l.Blocked("b", now); d != 0 {
+		t.Fatalf("other key blocked for %s", d)
+	}
+
+	// An earlier failure must not shorten the time a key is blocked
+	l.Fail("c", now)
+	l.Fail("c", now)
+	if d := l.Fail("c", now.Add(-time.Minute)); d != time.Minute+2*time.Second {
+		t.Fatalf("got %s", d)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	l := New(testConfig)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	l.Fail("a", now)
+	l.Fail("a", now)
+
+	// Failures are kept until ResetAfter has passed since the last failure
+	if d := l.Fail("a", now.Add(time.Hour-time.Second)); d != 4*time.Second {
+		t.Fatalf("failures forgotten too early: blocked for %s", d)
+	}
+	if d := l.Fail("a", now.Add(2*time.Hour)); d != time.Second {
+		t.Fatalf("failures not forgotten: blocked for %s", d)
+	}
+
+	// A locked key stays locked even if ResetAfter is shorter than the lockout
+	c := testConfig
+	c.ResetAfter = time.Second
+	l = New(c)
+	for i := 0; i < c.MaxFailures; i++ {
+		l.Fail("b", now)
+	}
+	if d := l.Blocked("b", now.Add(30*time.Second)); d != 30*time.Second {
+		t.Fatalf("lockout expired early: blocked for %s", d)
+	}
+	if d := l.Blocked("b", now.Add(time.Minute+time.Second)); d != 0 {
+		t.Fatalf("lockout not expired: blocked for %s", d)
+	}
+	if len(l.entries) != 0 {
+		t.Fatalf("expired entry kept")
+	}
+}
+
+func TestReset(t *testing.T) {
+	l := New(testConfig)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < testConfig.MaxFailures; i++ {
+		l.Fail("a", now)
+		l.Fail("b", now)
+	}
+	l.Reset("a")
+	l.Reset("unknown")
+
+	if d := l.Blocked("a", now); d != 0 {
+		t.Fatalf("reset key blocked for %s", d)
+	}
+	if d := l.Fail("a", now); d != time.Second {
+		t.Fatalf("failures not reset: blocked for %s", d)
+	}
+	if d := l.Blocked("b", now); d != time.Minute {
+		t.Fatalf("other key blocked for %s", d)
+	}
+}
+
+func TestLocked(t *testing.T) {
+	l := New(testConfig)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < testConfig.MaxFailures; i++ {
+		l.Fail("b", now)
+		l.Fail("a", now)
+	}
+	l.Fail("c", now)
+
+	locked := l.Locked(now)
+	if len(locked) != 2 || locked[0].Key != "a" || locked[1].Key != "b" {
+		t.Fatalf("got %v", locked)
+	}
+	if locked[0].Failures != testConfig.MaxFailures || !locked[0].BlockedUntil.Equal(now.Add(time.Minute)) {
+		t.Fatalf("got %v", locked[0])
+	}
+
+	if locked := l.Locked(now.Add(time.Minute)); len(locked) != 0 {
+		t.Fatalf("got %v after lockout", locked)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l := New(testConfig)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Every key is used only once, e.g. random user names
+	for i := 0; i < 10*sweepThreshold; i++ {
+		l.Fail(fmt.Sprint(i), now.Add(time.Duration(i)*time.Second))
+	}
+
+	// Only keys with a failure within ResetAfter may be kept, with some slack for the sweep interval
+	if len(l.entries) > 2*max(sweepThreshold, int(testConfig.ResetAfter/time.Second)) {
+		t.Fatalf("%d entries kept", len(l.entries))
+	}
+	if d := l.Blocked(fmt.Sprint(10*sweepThreshold-1), now.Add(time.Duration(10*sweepThreshold-1)*time.Second)); d != time.Second {
+		t.Fatalf("active key blocked for %s", d)
+	}
+}
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseTrustedProxies parses a list of networks in CIDR notation.
+// Single IP addresses are accepted as well.
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy '%s'", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s': %w", p, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// isTrustedProxy reports whether ip is contained in one of the networks.
+func isTrustedProxy(ip net.IP, trusted []*net.IPNet) bool {
+	for i := range trusted {
+		if trusted[i].Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRealIP tries to find the real IP address of a client.
+// Forwarded and X-Forwarded-For headers are only honoured if the request comes from one of the TrustedProxies.
+// If an error is found, that error will be returned instead of an IP address.
+func GetRealIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return err.Error()
+	}
+	remote := parseNodeIP(host)
+	if remote == nil {
+		return "unknown IP"
+	}
+
+	var chain []string
+	if forwarded := r.Header.Values("Forwarded"); len(forwarded) != 0 {
+		chain = parseForwardedFor(strings.Join(forwarded, ","))
+	} else if xff := r.Header.Values("X-Forwarded-For"); len(xff) != 0 {
+		for _, s := range strings.Split(strings.Join(xff, ","), ",") {
+			chain = append(chain, strings.TrimSpace(s))
+		}
+	}
+
+	return clientIP(remote, chain, config.trustedProxies).String()
+}
+
+// clientIP returns the address of the client given the address of the peer and the forwarding chain (client first).
+// The chain is walked backwards as long as the addresses belong to trusted proxies.
+// If an entry can not be parsed (e.g. 'unknown' or an obfuscated identifier), the last trusted address is returned.
+func clientIP(remote net.IP, chain []string, trusted []*net.IPNet) net.IP {
+	ip := remote
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !isTrustedProxy(ip, trusted) {
+			return ip
+		}
+		next := parseNodeIP(chain[i])
+		if next == nil {
+			return ip
+		}
+		ip = next
+	}
+	return ip
+}
+
+// parseForwardedFor returns all 'for' parameters of a Forwarded header (RFC 7239) in order.
+// Elements without a 'for' parameter are returned as empty strings so that the position in the chain is kept.
+func parseForwardedFor(header string) []string {
+	result := make([]string, 0)
+	for _, element := range splitQuoted(header, ',') {
+		if strings.TrimSpace(element) == "" {
+			continue
+		}
+		value := ""
+		for _, pair := range splitQuoted(element, ';') {
+			name, v, ok := strings.Cut(pair, "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(name), "for") {
+				continue
+			}
+			value = unquote(strings.TrimSpace(v))
+			break
+		}
+		result = append(result, value)
+	}
+	return result
+}
+
+// splitQuoted splits s at sep, ignoring separators inside of quoted strings.
+func splitQuoted(s string, sep rune) []string {
+	parts := make([]string, 0)
+	var sb strings.Builder
+	quoted := false
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, sb.String())
+			sb.Reset()
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return append(parts, sb.String())
+}
+
+// unquote removes the quotes of a quoted string and resolves escaped characters.
+// Strings which are not quoted are returned unchanged.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	var sb strings.Builder
+	escaped := false
+	for _, r := range s {
+		if !escaped && r == '\\' {
+			escaped = true
+			continue
+		}
+		escaped = false
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// parseNodeIP parses a node of a forwarding chain.
+// Accepted forms are IPv4 and IPv6 addresses with or without port, IPv6 addresses in brackets and addresses with zone.
+// nil is returned for all other forms.
+func parseNodeIP(node string) net.IP {
+	node = strings.TrimSpace(node)
+	if node == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(node, "[") {
+		end := strings.Index(node, "]")
+		if end == -1 {
+			return nil
+		}
+		rest := node[end+1:]
+		if rest != "" && !strings.HasPrefix(rest, ":") {
+			return nil
+		}
+		node = node[1:end]
+	} else if strings.Count(node, ":") == 1 {
+		// IPv4 with port
+		node, _, _ = strings.Cut(node, ":")
+	}
+
+	// Remove zone
+	node, _, _ = strings.Cut(node, "%")
+	return net.ParseIP(node)
+}
@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		valid   bool
+		count   int
+	}{
+		{name: "empty", proxies: []string{}, valid: true, count: 0},
+		{name: "cidr", proxies: []string{"127.0.0.0/8", "::1/128"}, valid: true, count: 2},
+		{name: "single addresses", proxies: []string{"10.0.0.1", " 2001:db8::1 "}, valid: true, count: 2},
+		{name: "invalid mask", proxies: []string{"10.0.0.0/33"}, valid: false},
+		{name: "invalid network", proxies: []string{"10.0.0/8"}, valid: false},
+		{name: "invalid address", proxies: []string{"localhost"}, valid: false},
+		{name: "empty entry", proxies: []string{""}, valid: false},
+		{name: "one invalid", proxies: []string{"127.0.0.0/8", "fe80::/129"}, valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			nets, err := parseTrustedProxies(tc.proxies)
+			if tc.valid && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !tc.valid {
+				if err == nil {
+					t.Fatalf("expected error, got %v", nets)
+				}
+				return
+			}
+			if len(nets) != tc.count {
+				t.Fatalf("got %d networks, expected %d", len(nets), tc.count)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesSingleAddress(t *testing.T) {
+	nets, err := parseTrustedProxies([]string{"10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isTrustedProxy(net.ParseIP("10.0.0.1"), nets) {
+		t.Error("10.0.0.1 not trusted")
+	}
+	if isTrustedProxy(net.ParseIP("10.0.0.2"), nets) {
+		t.Error("10.0.0.2 trusted")
+	}
+}
+
+func TestParseForwardedFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []string
+	}{
+		{name: "ipv4", header: "for=192.0.2.60", want: []string{"192.0.2.60"}},
+		{name: "ipv4 with port", header: `for="192.0.2.60:8080"`, want: []string{"192.0.2.60:8080"}},
+		{name: "quoted ipv6 with port", header: `for="[2001:db8::1]:4711"`, want: []string{"[2001:db8::1]:4711"}},
+		{name: "case insensitive", header: "For=192.0.2.60", want: []string{"192.0.2.60"}},
+		{name: "unknown", header: "for=unknown", want: []string{"unknown"}},
+		{name: "obfuscated", header: "for=_hidden, for=192.0.2.60", want: []string{"_hidden", "192.0.2.60"}},
+		{name: "multiple parameters", header: "proto=https;for=192.0.2.60;by=203.0.113.43", want: []string{"192.0.2.60"}},
+		{name: "multiple elements", header: "for=192.0.2.43, for=198.51.100.17;proto=http", want: []string{"192.0.2.43", "198.51.100.17"}},
+		{name: "element without for", header: "proto=https, for=192.0.2.60", want: []string{"", "192.0.2.60"}},
+		{name: "separators in quotes", header: `for="_a,b;c", for=192.0.2.60`, want: []string{"_a,b;c", "192.0.2.60"}},
+		{name: "empty elements", header: ", ,for=192.0.2.60", want: []string{"192.0.2.60"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseForwardedFor(tc.header)
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("got %q, expected %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseNodeIP(t *testing.T) {
+	tests := []struct {
+		node string
+		want string
+	}{
+		{node: "192.0.2.60", want: "192.0.2.60"},
+		{node: "192.0.2.60:8080", want: "192.0.2.60"},
+		{node: "2001:db8::1", want: "2001:db8::1"},
+		{node: "[2001:db8::1]", want: "2001:db8::1"},
+		{node: "[2001:db8::1]:4711", want: "2001:db8::1"},
+		{node: "fe80::1%eth0", want: "fe80::1"},
+		{node: "unknown", want: ""},
+		{node: "_hidden", want: ""},
+		{node: "[2001:db8::1", want: ""},
+		{node: "[2001:db8::1]x", want: ""},
+		{node: "", want: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.node, func(t *testing.T) {
+			got := parseNodeIP(tc.node)
+			if tc.want == "" {
+				if got != nil {
+					t.Fatalf("got %s, expected nil", got)
+				}
+				return
+			}
+			if !got.Equal(net.ParseIP(tc.want)) {
+				t.Fatalf("got %s, expected %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8", "2001:db8:ffff::/48"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		remote string
+		header string
+		want   string
+	}{
+		{name: "no proxy", remote: "192.0.2.1", header: "", want: "192.0.2.1"},
+		{name: "untrusted peer", remote: "192.0.2.1", header: "for=198.51.100.7", want: "192.0.2.1"},
+		{name: "trusted peer", remote: "10.0.0.1", header: "for=198.51.100.7", want: "198.51.100.7"},
+		{name: "trusted chain", remote: "10.0.0.1", header: "for=198.51.100.7, for=10.0.0.2", want: "198.51.100.7"},
+		{name: "spoofed leftmost entry", remote: "10.0.0.1", header: "for=203.0.113.66, for=198.51.100.7", want: "198.51.100.7"},
+		{name: "spoofed behind trusted chain", remote: "10.0.0.1", header: "for=203.0.113.66, for=198.51.100.7, for=10.0.0.2", want: "198.51.100.7"},
+		{name: "quoted ipv6 with port", remote: "10.0.0.1", header: `for="[2001:db8::1]:4711"`, want: "2001:db8::1"},
+		{name: "trusted ipv6 proxy", remote: "2001:db8:ffff::1", header: "for=192.0.2.60", want: "192.0.2.60"},
+		{name: "ipv4 with port", remote: "10.0.0.1", header: `for="192.0.2.60:8080"`, want: "192.0.2.60"},
+		{name: "unknown", remote: "10.0.0.1", header: "for=unknown", want: "10.0.0.1"},
+		{name: "obfuscated proxy", remote: "10.0.0.1", header: "for=192.0.2.60, for=_hidden", want: "10.0.0.1"},
+		{name: "element without for", remote: "10.0.0.1", header: "for=192.0.2.60, proto=https", want: "10.0.0.1"},
+		{name: "multiple parameters", remote: "10.0.0.1", header: "for=192.0.2.43;proto=http, by=10.0.0.5;for=10.0.0.2;proto=https", want: "192.0.2.43"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := clientIP(net.ParseIP(tc.remote), parseForwardedFor(tc.header), trusted)
+			if !got.Equal(net.ParseIP(tc.want)) {
+				t.Fatalf("got %s, expected %s", got, tc.want)
+			}
+		})
+	}
+}
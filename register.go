@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021,2022 Marcus Soll
+// Copyright 2020,2021,2022,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -113,6 +113,10 @@ func registerUserHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if rateLimited(rw, r, "") || registrationLimited(rw, r) {
+		return
+	}
+
 	err = r.ParseForm()
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -181,6 +185,7 @@ func registerUserHandleFunc(rw http.ResponseWriter, r *http.Request) {
 
 	valid := captcha.VerifyStringsTimed(captchaID, captchaValue, time.Now(), time.Duration(config.CookieMinutes)*time.Minute)
 	if !valid {
+		failedAttempt(r, "")
 		td := templateRegisterData{
 			ServerPath:   config.ServerPath,
 			ForumName:    config.ForumName,
@@ -292,7 +297,7 @@ func registerUserHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		Date:  time.Now(),
 	}
 
-	_, err = events.SaveEvent(e)
+	_, err = saveEvent(e)
 	if err != nil {
 		log.Printf("Can not save event %+v: %s", e, err.Error())
 	}
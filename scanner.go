@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/Top-Ranger/discussiongo/events"
+	"github.com/Top-Ranger/discussiongo/files"
+	"github.com/Top-Ranger/discussiongo/scanner"
+)
+
+var (
+	// uploadScanner checks uploads for malware. It is nil if no scanner is configured.
+	uploadScanner scanner.Scanner
+
+	// errFileInfected is returned if the scanner found malware in an upload.
+	errFileInfected = errors.New("file rejected by malware scan")
+
+	// errFileScanFailed is returned if an upload could not be scanned and UploadScanner.FailOpen is not set.
+	errFileScanFailed = errors.New("file could not be scanned")
+)
+
+// initUploadScanner creates the scanner for uploads.
+// It must be called before the server is started.
+func initUploadScanner(c uploadScannerConfig) error {
+	if c.Type == "" {
+		return nil
+	}
+
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return fmt.Errorf("can not parse UploadScanner.Timeout: %w", err)
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("UploadScanner.Timeout (%s) is not positive", timeout.String())
+	}
+
+	switch c.Type {
+	case "clamd":
+		uploadScanner, err = scanner.NewClamd(c.Network, c.Address, timeout)
+	case "command":
+		uploadScanner, err = scanner.NewCommand(c.Command, c.Arguments, timeout)
+	default:
+		return fmt.Errorf("unknown upload scanner type '%s'", c.Type)
+	}
+	return err
+}
+
+// scanUpload checks the content of a new file with the upload scanner. r is rewound afterwards.
+// Rejected uploads are saved as admin events.
+func scanUpload(f files.File, r io.ReadSeeker) error {
+	if uploadScanner == nil {
+		return nil
+	}
+
+	reason, err := checkUpload(uploadScanner, config.UploadScanner.FailOpen, f, r)
+	if reason != "" {
+		saveUploadRejectedEvent(f, reason)
+	}
+	return err
+}
+
+// checkUpload scans the content of a new file with s. r is rewound afterwards.
+// If the upload is rejected, the reason is returned together with the error.
+// If the content can not be scanned, it is accepted if failOpen is set and rejected otherwise.
+func checkUpload(s scanner.Scanner, failOpen bool, f files.File, r io.ReadSeeker) (string, error) {
+	result, err := s.Scan(r)
+	_, seekErr := r.Seek(0, io.SeekStart)
+	if seekErr != nil {
+		return "", seekErr
+	}
+
+	if err != nil {
+		if failOpen {
+			log.Printf("Can not scan file '%s' of %s, accepting it: %s", f.Name, f.User, err.Error())
+			return "", nil
+		}
+		log.Printf("Can not scan file '%s' of %s, rejecting it: %s", f.Name, f.User, err.Error())
+		return err.Error(), errFileScanFailed
+	}
+
+	if result.Infected {
+		log.Printf("Rejected file '%s' of %s: %s", f.Name, f.User, result.Signature)
+		return result.Signature, fmt.Errorf("%w: %s", errFileInfected, result.Signature)
+	}
+	return "", nil
+}
+
+// saveUploadRejectedEvent saves an admin event for a rejected upload.
+func saveUploadRejectedEvent(f files.File, reason string) {
+	e := events.Event{
+		Type:  EventUploadRejected,
+		User:  f.User,
+		Topic: eventAdminPseudoTopic,
+		Date:  time.Now(),
+		Data:  eventCreateUploadRejectedData(f.Name, reason),
+	}
+	_, err := saveEvent(e)
+	if err != nil {
+		log.Printf("Can not save event %+v: %s", e, err.Error())
+	}
+}
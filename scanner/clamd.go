@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the maximal size of a chunk sent to clamd.
+const clamdChunkSize = 64 * 1024
+
+// Clamd scans content with a ClamAV daemon using the INSTREAM command.
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamd returns a scanner using the clamd listening on address.
+// network is either 'unix' (address is the path of the socket) or 'tcp' (address is 'host:port').
+// A single scan including the transfer of the content must finish within timeout.
+func NewClamd(network, address string, timeout time.Duration) (*Clamd, error) {
+	if network != "unix" && network != "tcp" {
+		return nil, fmt.Errorf("scanner: unknown clamd network '%s'", network)
+	}
+	if address == "" {
+		return nil, errors.New("scanner: no clamd address configured")
+	}
+	return &Clamd{network: network, address: address, timeout: timeout}, nil
+}
+
+// Scan sends the content read from r to clamd.
+func (c *Clamd) Scan(r io.Reader) (Result, error) {
+	conn, err := net.DialTimeout(c.network, c.address, c.timeout)
+	if err != nil {
+		return Result{}, fmt.Errorf("scanner: can not connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	if c.timeout > 0 {
+		err = conn.SetDeadline(time.Now().Add(c.timeout))
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	// The 'z' prefix means that commands and replies are terminated by a null byte
+	_, err = conn.Write([]byte("zINSTREAM\x00"))
+	if err != nil {
+		return Result{}, fmt.Errorf("scanner: can not send command to clamd: %w", err)
+	}
+
+	// Content is sent as chunks, each prefixed with its length. A chunk of length 0 ends the stream.
+	chunk := make([]byte, 4+clamdChunkSize)
+	for {
+		n, readErr := io.ReadFull(r, chunk[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(chunk[:4], uint32(n))
+			_, err = conn.Write(chunk[:4+n])
+			if err != nil {
+				// clamd closes the connection if the stream is too large, the reply explains why
+				break
+			}
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			_, err = conn.Write([]byte{0, 0, 0, 0})
+			break
+		}
+		if readErr != nil {
+			return Result{}, readErr
+		}
+	}
+
+	reply, replyErr := bufio.NewReader(conn).ReadString(0)
+	if replyErr != nil && reply == "" {
+		if err != nil {
+			return Result{}, fmt.Errorf("scanner: can not send content to clamd: %w", err)
+		}
+		return Result{}, fmt.Errorf("scanner: can not read reply of clamd: %w", replyErr)
+	}
+	return parseClamdReply(reply)
+}
+
+// parseClamdReply parses a reply to INSTREAM, e.g. 'stream: OK' or 'stream: Eicar-Signature FOUND'.
+func parseClamdReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case reply == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	default:
+		return Result{}, fmt.Errorf("scanner: clamd returned '%s'", reply)
+	}
+}
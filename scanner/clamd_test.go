@@ -0,0 +1,267 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clamdStream is the content received by fakeClamd for a single INSTREAM command.
+type clamdStream struct {
+	chunks  []int
+	content []byte
+	err     error
+}
+
+// fakeClamd is a clamd speaking INSTREAM on a unix socket.
+type fakeClamd struct {
+	address string
+	streams chan clamdStream
+	// reply returns the reply to a stream. If it is empty, no reply is sent.
+	reply func(content []byte) string
+	// limit is the maximal size of a stream. Larger streams are aborted like clamd does with StreamMaxLength.
+	limit int
+}
+
+func newFakeClamd(t *testing.T, reply func(content []byte) string, limit int) *fakeClamd {
+	t.Helper()
+
+	c := &fakeClamd{
+		address: filepath.Join(t.TempDir(), "clamd.sock"),
+		streams: make(chan clamdStream, 10),
+		reply:   reply,
+		limit:   limit,
+	}
+	l, err := net.Listen("unix", c.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go c.handle(conn)
+		}
+	}()
+	return c
+}
+
+func (c *fakeClamd) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var s clamdStream
+	defer func() { c.streams <- s }()
+
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil {
+		s.err = err
+		return
+	}
+	if command != "zINSTREAM\x00" {
+		s.err = fmt.Errorf("unknown command %q", command)
+		return
+	}
+
+	length := make([]byte, 4)
+	for {
+		_, err = io.ReadFull(r, length)
+		if err != nil {
+			s.err = err
+			return
+		}
+		n := binary.BigEndian.Uint32(length)
+		if n == 0 {
+			break
+		}
+		chunk := make([]byte, n)
+		_, err = io.ReadFull(r, chunk)
+		if err != nil {
+			s.err = err
+			return
+		}
+		s.chunks = append(s.chunks, int(n))
+		s.content = append(s.content, chunk...)
+
+		if c.limit > 0 && len(s.content) > c.limit {
+			conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			return
+		}
+	}
+
+	reply := c.reply(s.content)
+	if reply == "" {
+		// Wait until the client gives up
+		io.Copy(io.Discard, r)
+		return
+	}
+	conn.Write([]byte(reply + "\x00"))
+}
+
+func eicarReply(content []byte) string {
+	if bytes.Contains(content, []byte("EICAR")) {
+		return "stream: Eicar-Signature FOUND"
+	}
+	return "stream: OK"
+}
+
+func TestClamdScan(t *testing.T) {
+	fake := newFakeClamd(t, eicarReply, 0)
+	c, err := NewClamd("unix", fake.address, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	large := bytes.Repeat([]byte("a"), 2*clamdChunkSize+clamdChunkSize/2)
+
+	tests := []struct {
+		name      string
+		content   []byte
+		chunks    []int
+		infected  bool
+		signature string
+	}{
+		{name: "empty", content: []byte{}, chunks: nil},
+		{name: "clean", content: []byte("hello world"), chunks: []int{11}},
+		{name: "exact chunk", content: large[:clamdChunkSize], chunks: []int{clamdChunkSize}},
+		{name: "multiple chunks", content: large, chunks: []int{clamdChunkSize, clamdChunkSize, clamdChunkSize / 2}},
+		{name: "infected", content: []byte("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR"), chunks: []int{33}, infected: true, signature: "Eicar-Signature"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := c.Scan(bytes.NewReader(tc.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Infected != tc.infected || result.Signature != tc.signature {
+				t.Errorf("got %+v", result)
+			}
+
+			s := <-fake.streams
+			if s.err != nil {
+				t.Fatalf("invalid stream: %s", s.err)
+			}
+			if !slices.Equal(s.chunks, tc.chunks) {
+				t.Errorf("got chunks %v, expected %v", s.chunks, tc.chunks)
+			}
+			if !bytes.Equal(s.content, tc.content) {
+				t.Errorf("content changed")
+			}
+		})
+	}
+}
+
+func TestClamdSizeLimit(t *testing.T) {
+	fake := newFakeClamd(t, eicarReply, clamdChunkSize)
+	c, err := NewClamd("unix", fake.address, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Scan(bytes.NewReader(bytes.Repeat([]byte("a"), 100*clamdChunkSize)))
+	if err == nil || !strings.Contains(err.Error(), "size limit exceeded") {
+		t.Fatalf("got error %v", err)
+	}
+}
+
+func TestClamdTimeout(t *testing.T) {
+	fake := newFakeClamd(t, func([]byte) string { return "" }, 0)
+	c, err := NewClamd("unix", fake.address, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = c.Scan(strings.NewReader("hello world"))
+	if err == nil {
+		t.Fatal("no error")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("scan took %s", d)
+	}
+}
+
+func TestClamdNotRunning(t *testing.T) {
+	c, err := NewClamd("unix", filepath.Join(t.TempDir(), "missing.sock"), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Scan(strings.NewReader("hello world"))
+	if err == nil {
+		t.Fatal("no error")
+	}
+}
+
+func TestNewClamd(t *testing.T) {
+	for _, tc := range []struct {
+		network string
+		address string
+		valid   bool
+	}{
+		{network: "unix", address: "/run/clamav/clamd.ctl", valid: true},
+		{network: "tcp", address: "localhost:3310", valid: true},
+		{network: "udp", address: "localhost:3310", valid: false},
+		{network: "unix", address: "", valid: false},
+	} {
+		_, err := NewClamd(tc.network, tc.address, time.Second)
+		if (err == nil) != tc.valid {
+			t.Errorf("%s %s: got error %v", tc.network, tc.address, err)
+		}
+	}
+}
+
+func TestParseClamdReply(t *testing.T) {
+	tests := []struct {
+		reply  string
+		result Result
+		valid  bool
+	}{
+		{reply: "stream: OK\x00", result: Result{}, valid: true},
+		{reply: "stream: OK\n", result: Result{}, valid: true},
+		{reply: "stream: Eicar-Signature FOUND\x00", result: Result{Infected: true, Signature: "Eicar-Signature"}, valid: true},
+		{reply: "stream: Win.Test.EICAR_HDB-1 FOUND", result: Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}, valid: true},
+		{reply: "INSTREAM size limit exceeded. ERROR\x00", valid: false},
+		{reply: "stream: Can't allocate memory ERROR\x00", valid: false},
+		{reply: "", valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.reply, func(t *testing.T) {
+			result, err := parseClamdReply(tc.reply)
+			if (err == nil) != tc.valid {
+				t.Fatalf("got error %v", err)
+			}
+			if result != tc.result {
+				t.Fatalf("got %+v, expected %+v", result, tc.result)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// maxCommandOutput is the number of bytes of the output of a command kept for the result.
+const maxCommandOutput = 4096
+
+// Command scans content by running an external command which reads the content from its standard input.
+// Like clamscan, the command must exit with 0 if the content is clean and with 1 if it is infected.
+// All other exit codes are treated as errors. The first line of the output is used as signature.
+type Command struct {
+	path    string
+	args    []string
+	timeout time.Duration
+}
+
+// NewCommand returns a scanner running path with args.
+// A single scan must finish within timeout, otherwise the command is killed.
+func NewCommand(path string, args []string, timeout time.Duration) (*Command, error) {
+	if path == "" {
+		return nil, errors.New("scanner: no command configured")
+	}
+	return &Command{path: path, args: args, timeout: timeout}, nil
+}
+
+// Scan runs the command with the content read from r as standard input.
+func (c *Command) Scan(r io.Reader) (Result, error) {
+	ctx := context.Background()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	output := &limitedBuffer{limit: maxCommandOutput}
+	cmd := exec.CommandContext(ctx, c.path, c.args...)
+	cmd.Stdin = r
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// Child processes might keep the output open after the command was killed
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if err == nil {
+		return Result{}, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && ctx.Err() == nil {
+		signature, _, _ := strings.Cut(strings.TrimSpace(output.String()), "\n")
+		return Result{Infected: true, Signature: strings.TrimSpace(signature)}, nil
+	}
+	if ctx.Err() != nil {
+		return Result{}, fmt.Errorf("scanner: command '%s' timed out", c.path)
+	}
+	return Result{}, fmt.Errorf("scanner: command '%s' failed: %w (%s)", c.path, err, strings.TrimSpace(output.String()))
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+// Write never fails so that the command does not block on a full pipe.
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.Buffer.Write(p[:remaining])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
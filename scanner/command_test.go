@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandScan(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+
+	tests := []struct {
+		name     string
+		script   string
+		result   Result
+		valid    bool
+		contains string
+	}{
+		{name: "clean", script: `cat >/dev/null; exit 0`, result: Result{}, valid: true},
+		{name: "content on stdin", script: `test "$(cat)" = "hello world" || exit 1`, result: Result{}, valid: true},
+		{name: "infected", script: `cat >/dev/null; echo "stdin: Eicar-Signature FOUND"; echo "Scanned files: 1"; exit 1`, result: Result{Infected: true, Signature: "stdin: Eicar-Signature FOUND"}, valid: true},
+		{name: "infected without output", script: `exit 1`, result: Result{Infected: true}, valid: true},
+		{name: "error", script: `echo "Can not open database" >&2; exit 2`, valid: false, contains: "Can not open database"},
+		{name: "killed", script: `kill -9 $$`, valid: false},
+		{name: "timeout", script: `sleep 10`, valid: false, contains: "timed out"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewCommand(sh, []string{"-c", tc.script}, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			result, err := c.Scan(strings.NewReader("hello world"))
+			if (err == nil) != tc.valid {
+				t.Fatalf("got error %v", err)
+			}
+			if err != nil && !strings.Contains(err.Error(), tc.contains) {
+				t.Errorf("error %q does not contain %q", err.Error(), tc.contains)
+			}
+			if result != tc.result {
+				t.Errorf("got %+v, expected %+v", result, tc.result)
+			}
+			if d := time.Since(start); d > 5*time.Second {
+				t.Errorf("scan took %s", d)
+			}
+		})
+	}
+}
+
+func TestCommandMissing(t *testing.T) {
+	_, err := NewCommand("", nil, time.Second)
+	if err == nil {
+		t.Error("no error for empty command")
+	}
+
+	c, err := NewCommand("/nonexistent/scanner", nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Scan(strings.NewReader("hello world"))
+	if err == nil {
+		t.Error("no error for missing command")
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{limit: 5}
+	for _, s := range []string{"abc", "defgh", "ijk"} {
+		n, err := b.Write([]byte(s))
+		if n != len(s) || err != nil {
+			t.Fatalf("got %d, %v", n, err)
+		}
+	}
+	if b.String() != "abcde" {
+		t.Fatalf("got %q", b.String())
+	}
+}
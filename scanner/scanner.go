@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scanner checks uploaded content for malware before it is stored.
+// Content can be sent to a ClamAV daemon (clamd) or to an external command.
+package scanner
+
+import (
+	"io"
+)
+
+// Result is the outcome of a scan.
+// If Infected is true, Signature names what was found.
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner checks content for malware.
+// An error is returned if the content could not be checked, e.g. because the scanner is not reachable.
+// All methods must be safe for concurrent use.
+type Scanner interface {
+	// Scan reads r until EOF and returns the result.
+	Scan(r io.Reader) (Result, error)
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Top-Ranger/discussiongo/files"
+	"github.com/Top-Ranger/discussiongo/scanner"
+)
+
+// stubScanner returns a fixed result after reading all content.
+type stubScanner struct {
+	result scanner.Result
+	err    error
+}
+
+func (s stubScanner) Scan(r io.Reader) (scanner.Result, error) {
+	_, err := io.Copy(io.Discard, r)
+	if err != nil {
+		return scanner.Result{}, err
+	}
+	return s.result, s.err
+}
+
+func TestCheckUpload(t *testing.T) {
+	scanErr := errors.New("clamd not running")
+
+	tests := []struct {
+		name     string
+		scanner  stubScanner
+		failOpen bool
+		reason   string
+		err      error
+	}{
+		{name: "clean", scanner: stubScanner{}},
+		{name: "clean fail open", scanner: stubScanner{}, failOpen: true},
+		{name: "infected", scanner: stubScanner{result: scanner.Result{Infected: true, Signature: "Eicar"}}, reason: "Eicar", err: errFileInfected},
+		{name: "infected fail open", scanner: stubScanner{result: scanner.Result{Infected: true, Signature: "Eicar"}}, failOpen: true, reason: "Eicar", err: errFileInfected},
+		{name: "fail closed", scanner: stubScanner{err: scanErr}, reason: scanErr.Error(), err: errFileScanFailed},
+		{name: "fail open", scanner: stubScanner{err: scanErr}, failOpen: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := strings.NewReader("hello world")
+			reason, err := checkUpload(tc.scanner, tc.failOpen, files.File{Name: "a.txt", User: "user"}, r)
+			if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+				t.Fatalf("got error %v, expected %v", err, tc.err)
+			}
+			if reason != tc.reason {
+				t.Errorf("got reason %q, expected %q", reason, tc.reason)
+			}
+
+			// The content must be saved after the scan
+			content, _ := io.ReadAll(r)
+			if string(content) != "hello world" {
+				t.Errorf("reader not rewound, got %q", content)
+			}
+		})
+	}
+}
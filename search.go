@@ -0,0 +1,245 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Top-Ranger/discussiongo/database"
+	"github.com/Top-Ranger/discussiongo/files"
+)
+
+const (
+	searchResultLimit   = 50
+	searchSnippetLength = 200
+)
+
+type templateSearchData struct {
+	ServerPath  string
+	ForumName   string
+	LoggedIn    bool
+	Query       string
+	HasQuery    bool
+	Topics      []searchResultData
+	Posts       []searchResultData
+	Files       []searchResultData
+	Translation Translation
+}
+
+type searchResultData struct {
+	ID        string
+	TopicID   string
+	TopicName string
+	User      string
+	Date      string
+	Snippet   template.HTML
+}
+
+var searchTemplate *template.Template
+
+func init() {
+	var err error
+
+	searchTemplate, err = template.New("search").Funcs(evenOddFuncMap).ParseFS(templateFiles, "template/search.html")
+	if err != nil {
+		panic(err)
+	}
+
+	http.HandleFunc("/search.html", searchHandleFunc)
+}
+
+func searchHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	loggedIn, _ := TestUser(r, rw)
+
+	if !canRead(loggedIn) {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	terms := database.SearchTerms(query)
+
+	td := templateSearchData{
+		ServerPath:  config.ServerPath,
+		ForumName:   config.ForumName,
+		LoggedIn:    loggedIn,
+		Query:       query,
+		HasQuery:    len(terms) != 0,
+		Topics:      make([]searchResultData, 0),
+		Posts:       make([]searchResultData, 0),
+		Files:       make([]searchResultData, 0),
+		Translation: GetDefaultTranslation(),
+	}
+
+	if td.HasQuery {
+		topicNames := make(map[string]string)
+		topicName := func(id string) (string, bool) {
+			name, ok := topicNames[id]
+			if ok {
+				return name, name != ""
+			}
+			t, err := database.GetTopic(id)
+			if err != nil {
+				// Topic might be deleted or belong to no topic at all
+				topicNames[id] = ""
+				return "", false
+			}
+			topicNames[id] = t.Name
+			return t.Name, true
+		}
+
+		topics, err := database.SearchTopics(terms, searchResultLimit)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		for i := range topics {
+			topicNames[topics[i].ID] = topics[i].Name
+			td.Topics = append(td.Topics, searchResultData{
+				ID:        topics[i].ID,
+				TopicID:   topics[i].ID,
+				TopicName: topics[i].Name,
+				User:      topics[i].Creator,
+				Date:      topics[i].LastModified.Format(time.RFC822),
+				Snippet:   searchSnippet(topics[i].Name, terms, 0),
+			})
+		}
+
+		posts, err := database.SearchPosts(terms, searchResultLimit)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		for i := range posts {
+			name, ok := topicName(posts[i].TopicID)
+			if !ok {
+				continue
+			}
+			td.Posts = append(td.Posts, searchResultData{
+				ID:        posts[i].ID,
+				TopicID:   posts[i].TopicID,
+				TopicName: name,
+				User:      posts[i].Poster,
+				Date:      posts[i].Time.Format(time.RFC822),
+				Snippet:   searchSnippet(posts[i].Content, terms, searchSnippetLength),
+			})
+		}
+
+		// Files of conversations are private
+		fs, err := files.SearchFiles(terms, conversationFilesPrefix, searchResultLimit)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		for i := range fs {
+			name, ok := topicName(fs[i].Topic)
+			if !ok {
+				continue
+			}
+			td.Files = append(td.Files, searchResultData{
+				ID:        fs[i].ID,
+				TopicID:   fs[i].Topic,
+				TopicName: name,
+				User:      fs[i].User,
+				Date:      fs[i].Date.Format(time.RFC822),
+				Snippet:   searchSnippet(fs[i].Name, terms, 0),
+			})
+		}
+	}
+
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	err := searchTemplate.ExecuteTemplate(rw, "search.html", td)
+	if err != nil {
+		log.Println("Error executing search template:", err)
+	}
+}
+
+// searchSnippet returns an excerpt of s around the first occurrence of a term.
+// All occurrences of the terms are highlighted. A length of 0 returns the whole text.
+// Terms must be in lower case as returned by database.SearchTerms.
+func searchSnippet(s string, terms []string, length int) template.HTML {
+	text := []rune(strings.Join(strings.Fields(s), " "))
+
+	// Lower case every rune on its own so that positions stay the same
+	lower := make([]rune, len(text))
+	for i := range text {
+		lower[i] = unicode.ToLower(text[i])
+	}
+
+	highlight := make([]bool, len(text))
+	first := -1
+	for _, t := range terms {
+		term := []rune(t)
+		for i := 0; i+len(term) <= len(lower); i++ {
+			if string(lower[i:i+len(term)]) != t {
+				continue
+			}
+			if first == -1 || i < first {
+				first = i
+			}
+			for j := i; j < i+len(term); j++ {
+				highlight[j] = true
+			}
+		}
+	}
+
+	start, end := 0, len(text)
+	if length > 0 && len(text) > length {
+		if first > length/3 {
+			start = first - length/3
+		}
+		end = start + length
+		if end > len(text) {
+			end = len(text)
+			start = end - length
+		}
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	marked := false
+	for i := start; i < end; i++ {
+		if highlight[i] != marked {
+			if highlight[i] {
+				sb.WriteString("<mark>")
+			} else {
+				sb.WriteString("</mark>")
+			}
+			marked = highlight[i]
+		}
+		sb.WriteString(html.EscapeString(string(text[i])))
+	}
+	if marked {
+		sb.WriteString("</mark>")
+	}
+	if end < len(text) {
+		sb.WriteString("…")
+	}
+	return template.HTML(sb.String())
+}
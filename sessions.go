@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Top-Ranger/auth/data"
+	"github.com/Top-Ranger/discussiongo/authtoken"
+	"github.com/Top-Ranger/discussiongo/database"
+)
+
+func init() {
+	http.HandleFunc("/revokeSession.html", revokeSessionHandleFunc)
+	http.HandleFunc("/logoutEverywhereElse.html", logoutEverywhereElseHandleFunc)
+}
+
+// editPassword changes the password of a user.
+// All sessions of the user are revoked afterwards, so old sessions can not be used with the new password.
+func editPassword(user, pw string) error {
+	err := database.EditPassword(user, pw)
+	if err != nil {
+		return err
+	}
+
+	_, err = authtoken.DeleteUserToken(user)
+	if err != nil {
+		return fmt.Errorf("can not revoke sessions of '%s' after password change: %w", user, err)
+	}
+	return nil
+}
+
+func revokeSessionHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	q := r.Form
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	session := q.Get("session")
+	if session == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	ok, err := authtoken.DeleteSession(user, session)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	if !ok {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/user.html#sessions", config.ServerPath), http.StatusFound)
+}
+
+func logoutEverywhereElseHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	token := r.Form.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	_, err = authtoken.DeleteUserTokenExcept(user, currentAuthtoken(r))
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/user.html#sessions", config.ServerPath), http.StatusFound)
+}
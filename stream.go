@@ -0,0 +1,243 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Top-Ranger/auth/data"
+	"github.com/Top-Ranger/discussiongo/accesstimes"
+	"github.com/Top-Ranger/discussiongo/broadcast"
+	"github.com/Top-Ranger/discussiongo/database"
+	"github.com/Top-Ranger/discussiongo/events"
+	"github.com/Top-Ranger/discussiongo/files"
+)
+
+// streamKeepAlive is the interval in which a comment is sent to keep idle connections open.
+const streamKeepAlive = 30 * time.Second
+
+func init() {
+	http.HandleFunc("/stream", streamHandleFunc)
+	http.HandleFunc("/timelineElement.html", timelineElementHandleFunc)
+}
+
+// streamHandleFunc sends all changes as Server-Sent Events.
+// If the parameter 'topic' is set, only changes of that topic are sent, otherwise all changes are sent (for the topic list).
+// Changes not associated with a single topic are always sent.
+func streamHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	loggedIn, _ := TestUser(r, rw)
+
+	if !canRead(loggedIn) {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	rc := http.NewResponseController(rw)
+	topic := r.URL.Query().Get("topic")
+
+	messages, unsubscribe := broadcast.Subscribe()
+	defer unsubscribe()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+
+	_, err := rw.Write([]byte(": connected\n\n"))
+	if err != nil {
+		return
+	}
+	err = rc.Flush()
+	if err != nil {
+		log.Println("Can not flush event stream:", err)
+		return
+	}
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			_, err = rw.Write([]byte(": keep-alive\n\n"))
+		case m, ok := <-messages:
+			if !ok {
+				return
+			}
+			if topic != "" && m.Topic != "" && m.Topic != topic {
+				continue
+			}
+			if _, ok := conversationOfFile(m.Topic); ok {
+				// Conversations are private
+				continue
+			}
+			var b []byte
+			b, err = json.Marshal(m)
+			if err != nil {
+				log.Println("Can not marshal broadcast message:", err)
+				continue
+			}
+			_, err = fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", m.Type, b)
+		}
+		if err != nil {
+			return
+		}
+		err = rc.Flush()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// timelineElementHandleFunc renders a single element of a topic timeline.
+// It is used to add new elements to an open topic page.
+func timelineElementHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	loggedIn, user := TestUser(r, rw)
+
+	if !canRead(loggedIn) {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	isAdmin := false
+	if loggedIn {
+		var err error
+		isAdmin, err = database.IsAdmin(user)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	q := r.URL.Query()
+	id := q.Get("id")
+	if id == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	index, err := strconv.Atoi(q.Get("index"))
+	if err != nil {
+		index = 0
+	}
+
+	var element timelineData
+	var topicID string
+
+	switch q.Get("type") {
+	case "post":
+		post, err := database.GetSinglePost(id)
+		if err != nil {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		topic, err := database.GetTopic(post.TopicID)
+		if err != nil {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		revisions, err := database.GetPostRevisions(post.ID)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		tf, err := getTopicFiles(post.TopicID)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		p := newPostData(post, revisions, tf, user, loggedIn, isAdmin, topic.Closed)
+		p.New = true
+		element = timelineData{Time: post.Time, Post: &p}
+		topicID = post.TopicID
+	case "file":
+		file, err := files.GetFileMetadata(id)
+		if err != nil {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if _, ok := conversationOfFile(file.Topic); ok {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f := newFileData(file, user, loggedIn, isAdmin)
+		f.New = true
+		element = timelineData{Time: file.Date, File: &f}
+		topicID = file.Topic
+	case "event":
+		event, err := events.GetEvent(id)
+		if err != nil {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if event.Topic == eventAdminPseudoTopic {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+		e := eventToEventData(event)
+		e.New = true
+		element = timelineData{Time: event.Date, Event: &e}
+		topicID = event.Topic
+	default:
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	td := templatePostData{
+		ServerPath:   config.ServerPath,
+		ServerPrefix: config.ServerPrefix,
+		LoggedIn:     loggedIn,
+		User:         user,
+		IsAdmin:      isAdmin,
+		TopicID:      topicID,
+		Translation:  GetDefaultTranslation(),
+	}
+
+	if loggedIn {
+		token, err := data.GetStringsTimed(time.Now(), fmt.Sprintf("%s;Token", user))
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		td.Token = token
+
+		// The user is looking at the topic, so the new element has been read
+		err = accesstimes.SaveTime(user, topicID, time.Now())
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	err = postTemplate.ExecuteTemplate(rw, "timelineElement", newTimelineElementData(td, index, element))
+	if err != nil {
+		log.Println("Error executing timeline element template:", err)
+	}
+}
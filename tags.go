@@ -0,0 +1,326 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Top-Ranger/auth/data"
+	"github.com/Top-Ranger/discussiongo/database"
+	"github.com/Top-Ranger/discussiongo/events"
+)
+
+// maxTagsPerTopic is the maximal number of tags a single topic can have.
+const maxTagsPerTopic = 10
+
+// maxTagLength is the maximal number of characters of a tag.
+const maxTagLength = 50
+
+// eventTagSeparator separates the tags in the data of EventTopicTagsChanged.
+// It can not be part of a tag since tags are separated by commas when entered.
+const eventTagSeparator = ", "
+
+type tagsTemplateData struct {
+	ServerPath  string
+	ForumName   string
+	Tags        []database.Tag
+	Token       string
+	Translation Translation
+}
+
+var (
+	tagsTemplate *template.Template
+)
+
+func init() {
+	var err error
+
+	tagsTemplate, err = template.New("tags").Funcs(evenOddFuncMap).ParseFS(templateFiles, "template/tags.html")
+	if err != nil {
+		panic(err)
+	}
+
+	http.HandleFunc("/editTopicTags.html", editTopicTagsHandleFunc)
+	http.HandleFunc("/adminTags.html", adminTagsHandleFunc)
+	http.HandleFunc("/adminRenameTag.html", adminRenameTagHandleFunc)
+}
+
+// normaliseTag returns the canonical form of a tag.
+// Tags are case insensitive and must not contain commas.
+func normaliseTag(tag string) string {
+	tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+	tag = strings.ReplaceAll(tag, ",", "")
+	if t := []rune(tag); len(t) > maxTagLength {
+		tag = strings.TrimSpace(string(t[:maxTagLength]))
+	}
+	return tag
+}
+
+// parseTags parses a comma separated list of tags.
+// The returned tags are normalised, sorted and free of duplicates.
+// It returns false if there are too many tags.
+func parseTags(s string) ([]string, bool) {
+	tags := make([]string, 0)
+	for _, t := range strings.Split(s, ",") {
+		t = normaliseTag(t)
+		if t == "" {
+			continue
+		}
+		tags = append(tags, t)
+	}
+	slices.Sort(tags)
+	tags = slices.Compact(tags)
+	return tags, len(tags) <= maxTagsPerTopic
+}
+
+// splitEventTags splits the tags saved by eventCreateTopicTagsData.
+func splitEventTags(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, eventTagSeparator)
+}
+
+// setTopicTags replaces the tags of a topic and saves the corresponding event.
+// tags must be the result of parseTags.
+// Permissions must be checked by the caller.
+func setTopicTags(user string, topic database.Topic, tags []string) error {
+	old, err := database.GetTopicTags(topic.ID)
+	if err != nil {
+		return err
+	}
+
+	if slices.Equal(old, tags) {
+		return nil
+	}
+
+	err = database.SetTopicTags(topic.ID, tags)
+	if err != nil {
+		return err
+	}
+
+	_, err = saveEvent(events.Event{
+		Type:  EventTopicTagsChanged,
+		User:  user,
+		Topic: topic.ID,
+		Date:  time.Now(),
+		Data:  eventCreateTopicTagsData(old, tags),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+	return nil
+}
+
+func editTopicTagsHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	q := r.Form
+
+	token := q.Get("token")
+	if token == "" {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	id := q.Get("id")
+	if id == "" {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	topic, err := database.GetTopic(id)
+	if err != nil {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !canEditTopicTags(user, loggedIn, isAdmin, topic) {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	tags, ok := parseTags(q.Get("tags"))
+	if !ok {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(fmt.Sprintf(t.TooManyTags, maxTagsPerTopic)))
+		return
+	}
+
+	err = setTopicTags(user, topic, tags)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/topic.html?id=%s", config.ServerPath, id), http.StatusFound)
+}
+
+func adminTagsHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	tags, err := database.GetTags()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	token, err := data.GetStringsTimed(time.Now(), fmt.Sprintf("%s;Token", user))
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	td := tagsTemplateData{
+		ServerPath:  config.ServerPath,
+		ForumName:   config.ForumName,
+		Tags:        tags,
+		Token:       token,
+		Translation: GetDefaultTranslation(),
+	}
+
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	err = tagsTemplate.ExecuteTemplate(rw, "tags.html", td)
+	if err != nil {
+		log.Println("Error executing tags template:", err)
+	}
+}
+
+// adminRenameTagHandleFunc renames a tag globally.
+// If the new name is an existing tag, both tags are merged.
+func adminRenameTagHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	token := r.Form.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	tag := r.Form.Get("tag")
+	newName := normaliseTag(r.Form.Get("newname"))
+	if tag == "" || newName == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	merged, err := database.RenameTag(tag, newName)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if merged {
+		log.Printf("%s merged tag '%s' into '%s'", user, tag, newName)
+	} else {
+		log.Printf("%s renamed tag '%s' to '%s'", user, tag, newName)
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/adminTags.html", config.ServerPath), http.StatusFound)
+}
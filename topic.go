@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021,2022,2024 Marcus Soll
+// Copyright 2020,2021,2022,2024,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -20,6 +20,8 @@ import (
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,23 +30,43 @@ import (
 	"github.com/Top-Ranger/discussiongo/database"
 	"github.com/Top-Ranger/discussiongo/events"
 	"github.com/Top-Ranger/discussiongo/files"
+	"github.com/Top-Ranger/discussiongo/notifications"
 )
 
 type templateTopicData struct {
-	ServerPath    string
-	ForumName     string
-	LoggedIn      bool
-	User          string
-	IsAdmin       bool
-	HasPinned     bool
-	HasClosed     bool
-	HasNew        bool
-	CurrentUpdate int64
-	Topics        []topicData
-	TopicsPinned  []topicData
-	TopicsClosed  []topicData
-	Token         string
-	Translation   Translation
+	ServerPath          string
+	ForumName           string
+	LoggedIn            bool
+	User                string
+	IsAdmin             bool
+	HasPinned           bool
+	HasNew              bool
+	CurrentUpdate       int64
+	UnreadNotifications int
+	UnreadConversations int
+	Overview            bool
+	Categories          []categoryData
+	InCategory          bool
+	Category            categoryData
+	CanCreateTopic      bool
+	NewTopicCategories  []categoryData
+	Tag                 string
+	Sort                string
+	OnlyNew             bool
+	OnlyMine            bool
+	OnlyParticipated    bool
+	ShowClosed          bool
+	ToggleClosedLink    string
+	Page                int
+	Pages               int
+	FirstPageLink       string
+	PreviousPageLink    string
+	NextPageLink        string
+	LastPageLink        string
+	Topics              []topicData
+	TopicsPinned        []topicData
+	Token               string
+	Translation         Translation
 }
 
 type topicData struct {
@@ -55,6 +77,18 @@ type topicData struct {
 	Closed   bool
 	Pinned   bool
 	New      bool
+	Tags     []string
+}
+
+const topicsPerPage = 50
+
+// topicSorts maps the values of the parameter 'sort' to the sort order of the topic list.
+// The empty value is the default order.
+var topicSorts = map[string]database.TopicSort{
+	"":        database.SortLastModified,
+	"created": database.SortCreated,
+	"creator": database.SortCreator,
+	"posts":   database.SortPosts,
 }
 
 var (
@@ -78,10 +112,20 @@ func init() {
 }
 
 func topicHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	serveTopicList(rw, r, "")
+}
+
+// serveTopicList renders the list of topics.
+// If categoryID is empty and categories exist, an overview of all categories is shown.
+// Otherwise, the topics of the category (or all topics if categoryID is empty) are shown page by page.
+// The list can be changed through the parameters 'tag', 'sort', 'new', 'mine', 'participated', 'closed' and 'page'.
+// If any of them is set, the overview is skipped.
+func serveTopicList(rw http.ResponseWriter, r *http.Request, categoryID string) {
 	loggedIn, user := TestUser(r, rw)
 
-	if !config.CanReadWithoutRegister && !loggedIn {
+	if !canRead(loggedIn) {
 		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
 	}
 
 	isAdmin := false
@@ -95,30 +139,65 @@ func topicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	topics, err := database.GetTopics()
+	translation := GetDefaultTranslation()
+	q := r.URL.Query()
+
+	// Read before the content so that changes in between cause a reload
+	currentUpdate, err := database.GetLastUpdateTopicList()
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
-	td := templateTopicData{
-		ServerPath:    config.ServerPath,
-		ForumName:     config.ForumName,
-		LoggedIn:      loggedIn,
-		User:          user,
-		IsAdmin:       isAdmin,
-		HasPinned:     false,
-		HasClosed:     false,
-		HasNew:        false,
-		CurrentUpdate: database.GetLastUpdateTopicPost(),
-		Topics:        make([]topicData, 0, len(topics)),
-		TopicsPinned:  make([]topicData, 0, len(topics)),
-		TopicsClosed:  make([]topicData, 0, len(topics)),
-		Translation:   GetDefaultTranslation(),
+	categories, err := database.GetCategories()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
 	}
 
-	var times []time.Time
+	td := templateTopicData{
+		ServerPath:       config.ServerPath,
+		ForumName:        config.ForumName,
+		LoggedIn:         loggedIn,
+		User:             user,
+		IsAdmin:          isAdmin,
+		HasPinned:        false,
+		HasNew:           false,
+		CurrentUpdate:    currentUpdate,
+		Translation:      translation,
+		Tag:              normaliseTag(q.Get("tag")),
+		Sort:             q.Get("sort"),
+		OnlyNew:          loggedIn && q.Get("new") == "1",
+		OnlyMine:         loggedIn && q.Get("mine") == "1",
+		OnlyParticipated: loggedIn && q.Get("participated") == "1",
+		ShowClosed:       q.Get("closed") == "1",
+		Page:             1,
+		Pages:            1,
+	}
+
+	sort, ok := topicSorts[td.Sort]
+	if !ok {
+		td.Sort = ""
+		sort = database.SortLastModified
+	}
+
+	if categoryID != "" {
+		category, err := database.GetCategory(categoryID)
+		if err != nil {
+			rw.WriteHeader(http.StatusNotFound)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		td.InCategory = true
+		td.Category = newCategoryData(category, translation)
+		td.CanCreateTopic = canCreateTopic(loggedIn, isAdmin, category)
+	} else {
+		listRequested := td.Tag != "" || td.Sort != "" || td.OnlyNew || td.OnlyMine || td.OnlyParticipated || td.ShowClosed || q.Get("page") != ""
+		td.Overview = len(categories) != 0 && !listRequested
+		td.CanCreateTopic = canCreateTopic(loggedIn, isAdmin, database.Category{ID: database.NoCategory})
+	}
 
 	if loggedIn {
 		var err error
@@ -130,10 +209,152 @@ func topicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		}
 		td.Token = token
 
-		ids := make([]string, len(topics))
-		for i := range topics {
-			ids[i] = topics[i].ID
+		td.UnreadNotifications, err = notifications.CountUnread(user)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+
+		td.UnreadConversations, err = database.CountUnreadConversations(user)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	var topics []database.Topic
+
+	if td.Overview {
+		// Topics without a (known) category are summarised under NoCategory
+		categoryIndex := make(map[string]int, len(categories)+1)
+		td.Categories = make([]categoryData, 0, len(categories)+1)
+		for i := range categories {
+			categoryIndex[categories[i].ID] = len(td.Categories)
+			td.Categories = append(td.Categories, newCategoryData(categories[i], translation))
+		}
+		categoryIndex[database.NoCategory] = len(td.Categories)
+		td.Categories = append(td.Categories, newCategoryData(database.Category{ID: database.NoCategory}, translation))
+
+		td.NewTopicCategories = make([]categoryData, 0, len(td.Categories))
+		for i := range categories {
+			if canCreateTopic(loggedIn, isAdmin, categories[i]) {
+				td.NewTopicCategories = append(td.NewTopicCategories, td.Categories[categoryIndex[categories[i].ID]])
+			}
+		}
+		td.NewTopicCategories = append(td.NewTopicCategories, td.Categories[categoryIndex[database.NoCategory]])
+
+		unreadBy := ""
+		if loggedIn {
+			unreadBy = user
+		}
+		states, err := database.GetCategoryStates(unreadBy)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+
+		for i := range states {
+			index, ok := categoryIndex[states[i].Category]
+			if !ok {
+				index = categoryIndex[database.NoCategory]
+			}
+			c := &td.Categories[index]
+			c.Topics += states[i].Topics
+			c.New = c.New || states[i].Unread > 0
+			td.HasNew = td.HasNew || states[i].Unread > 0
+			if states[i].LastModified.After(c.lastModified) {
+				c.lastModified = states[i].LastModified
+				c.Modified = states[i].LastModified.Format(time.RFC822)
+			}
+		}
+
+		if td.Categories[len(td.Categories)-1].Topics == 0 {
+			// Only show topics without category if there are any
+			td.Categories = td.Categories[:len(td.Categories)-1]
+		}
+
+		// Pinned topics are still shown directly on the overview
+		count, err := database.CountTopics(database.TopicFilter{OnlyOpen: true, OnlyPinned: true})
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		topics, err = database.GetTopicsFiltered(database.TopicFilter{OnlyOpen: true, OnlyPinned: true}, database.SortLastModified, 0, count)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+	} else {
+		filter := database.TopicFilter{
+			Category:   categoryID,
+			Tag:        td.Tag,
+			OnlyOpen:   !td.ShowClosed,
+			OnlyClosed: td.ShowClosed,
+		}
+		if td.OnlyMine {
+			filter.Creator = user
+		}
+		if td.OnlyParticipated {
+			filter.Participant = user
+		}
+		if td.OnlyNew {
+			filter.UnreadBy = user
+		}
+
+		count, err := database.CountTopics(filter)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+
+		td.Pages = (count + topicsPerPage - 1) / topicsPerPage
+		if td.Pages < 1 {
+			td.Pages = 1
+		}
+		if page, err := strconv.Atoi(q.Get("page")); err == nil {
+			td.Page = min(max(page, 1), td.Pages)
 		}
+
+		topics, err = database.GetTopicsFiltered(filter, sort, (td.Page-1)*topicsPerPage, topicsPerPage)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+
+		td.FirstPageLink = topicListLink(categoryID, td, 1)
+		td.LastPageLink = topicListLink(categoryID, td, td.Pages)
+		if td.Page > 1 {
+			td.PreviousPageLink = topicListLink(categoryID, td, td.Page-1)
+		}
+		if td.Page < td.Pages {
+			td.NextPageLink = topicListLink(categoryID, td, td.Page+1)
+		}
+		toggled := td
+		toggled.ShowClosed = !td.ShowClosed
+		td.ToggleClosedLink = topicListLink(categoryID, toggled, 1)
+	}
+
+	ids := make([]string, len(topics))
+	for i := range topics {
+		ids[i] = topics[i].ID
+	}
+
+	tags, err := database.GetTagsOfTopics(ids)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	var times []time.Time
+	if loggedIn {
 		times, err = accesstimes.GetTimes(user, ids)
 		if err != nil {
 			rw.WriteHeader(http.StatusInternalServerError)
@@ -142,6 +363,9 @@ func topicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	td.Topics = make([]topicData, 0, len(topics))
+	td.TopicsPinned = make([]topicData, 0)
+
 	for i := range topics {
 		t := topicData{
 			ID:       topics[i].ID,
@@ -151,6 +375,7 @@ func topicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 			Closed:   topics[i].Closed,
 			Pinned:   topics[i].Pinned,
 			New:      false,
+			Tags:     tags[topics[i].ID],
 		}
 
 		if loggedIn {
@@ -160,10 +385,7 @@ func topicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		if t.Closed {
-			td.TopicsClosed = append(td.TopicsClosed, t)
-			td.HasClosed = true
-		} else if t.Pinned {
+		if t.Pinned && !t.Closed {
 			td.TopicsPinned = append(td.TopicsPinned, t)
 			td.HasPinned = true
 		} else {
@@ -179,6 +401,37 @@ func topicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// topicListLink returns the link to the given page of the topic list described by td.
+func topicListLink(categoryID string, td templateTopicData, page int) string {
+	v := url.Values{}
+	path := "/"
+	if categoryID != "" {
+		path = "/category.html"
+		v.Set("id", categoryID)
+	}
+	if td.Tag != "" {
+		v.Set("tag", td.Tag)
+	}
+	if td.Sort != "" {
+		v.Set("sort", td.Sort)
+	}
+	if td.OnlyNew {
+		v.Set("new", "1")
+	}
+	if td.OnlyMine {
+		v.Set("mine", "1")
+	}
+	if td.OnlyParticipated {
+		v.Set("participated", "1")
+	}
+	if td.ShowClosed {
+		v.Set("closed", "1")
+	}
+	// Always set the page so that the overview is not shown instead of the list
+	v.Set("page", strconv.Itoa(page))
+	return fmt.Sprintf("%s%s?%s", config.ServerPath, path, v.Encode())
+}
+
 func newTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 	loggedIn, user := TestUser(r, rw)
 
@@ -213,16 +466,35 @@ func newTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := database.AddTopic(topic, user)
+	categoryID := q.Get("category")
+	if categoryID == "" {
+		categoryID = database.NoCategory
+	}
+
+	category, err := database.GetCategory(categoryID)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
-	err = database.ModifyLastSeen(user)
+	if !canCreateTopic(loggedIn, isAdmin, category) {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	id, err := createTopic(user, topic, category.ID)
 	if err != nil {
-		log.Println("Can not modify last seen:", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
 	}
 
 	http.Redirect(rw, r, fmt.Sprintf("%s/topic.html?id=%s", config.ServerPath, id), http.StatusFound)
@@ -286,6 +558,13 @@ func deleteTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	_, err = notifications.DeleteTopicNotifications(id)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
 	err = database.DeleteTopic(id)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -306,7 +585,7 @@ func deleteTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		Data:  []byte(topic.Name),
 	}
 
-	_, err = events.SaveEvent(deletionEvent)
+	_, err = saveEvent(deletionEvent)
 	if err != nil {
 		log.Printf("Can not save event %+v: %s", deletionEvent, err.Error())
 	}
@@ -361,41 +640,18 @@ func closeTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !config.EveryoneCanCloseAndOpenTopics && !isAdmin && user != topic.Creator {
+	if !canCloseTopic(user, loggedIn, isAdmin, topic) {
 		rw.WriteHeader(http.StatusForbidden)
 		return
 	}
 
-	err = database.TopicSetClosed(id, closed == "1")
+	err = setTopicClosed(user, id, closed == "1")
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
-	event := events.Event{
-		Type:  EventOpenTopic,
-		User:  user,
-		Topic: id,
-		Date:  time.Now(),
-	}
-
-	if closed == "1" {
-		event.Type = EventCloseTopic
-	}
-
-	_, err = events.SaveEvent(event)
-	if err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		rw.Write([]byte(err.Error()))
-		return
-	}
-
-	err = database.ModifyLastSeen(user)
-	if err != nil {
-		log.Println("Can not modify last seen:", err)
-	}
-
 	http.Redirect(rw, r, fmt.Sprintf("%s/#topic%s", config.ServerPath, id), http.StatusFound)
 }
 
@@ -413,7 +669,7 @@ func pinTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		rw.Write([]byte(err.Error()))
 		return
 	}
-	if !isAdmin {
+	if !canPinTopic(isAdmin) {
 		rw.WriteHeader(http.StatusForbidden)
 		return
 	}
@@ -450,36 +706,13 @@ func pinTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = database.TopicSetPinned(id, pin == "1")
+	err = setTopicPinned(user, id, pin == "1")
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
-	event := events.Event{
-		Type:  EventUnpinTopic,
-		User:  user,
-		Topic: id,
-		Date:  time.Now(),
-	}
-
-	if pin == "1" {
-		event.Type = EventPinTopic
-	}
-
-	_, err = events.SaveEvent(event)
-	if err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		rw.Write([]byte(err.Error()))
-		return
-	}
-
-	err = database.ModifyLastSeen(user)
-	if err != nil {
-		log.Println("Can not modify last seen:", err)
-	}
-
 	http.Redirect(rw, r, fmt.Sprintf("%s/#topic%s", config.ServerPath, id), http.StatusFound)
 }
 
@@ -537,44 +770,136 @@ func renameTopicHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !isAdmin && user != topic.Creator {
+	if !canRenameTopic(user, loggedIn, isAdmin, topic) {
 		rw.WriteHeader(http.StatusForbidden)
 		return
 	}
 
-	err = database.RenameTopic(id, newtopic)
+	err = renameTopic(user, topic, newtopic)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
+	http.Redirect(rw, r, fmt.Sprintf("%s/topic.html?id=%s", config.ServerPath, id), http.StatusFound)
+}
+
+// createTopic adds a new topic created by the user in the category and returns its ID.
+// Permissions must be checked by the caller.
+func createTopic(user, name, category string) (string, error) {
+	id, err := database.AddTopic(name, user, category)
+	if err != nil {
+		return "", err
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+
+	triggerWebhook(webhookTopicCreated, webhookTopicData{
+		ID:       id,
+		Name:     name,
+		Creator:  user,
+		Category: category,
+		URL:      topicURL(id),
+	})
+	return id, nil
+}
+
+// setTopicClosed closes or reopens a topic and saves the corresponding event.
+// Permissions must be checked by the caller.
+func setTopicClosed(user, id string, closed bool) error {
+	err := database.TopicSetClosed(id, closed)
+	if err != nil {
+		return err
+	}
+
+	event := events.Event{
+		Type:  EventOpenTopic,
+		User:  user,
+		Topic: id,
+		Date:  time.Now(),
+	}
+
+	if closed {
+		event.Type = EventCloseTopic
+	}
+
+	_, err = saveEvent(event)
+	if err != nil {
+		return err
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+	return nil
+}
+
+// setTopicPinned pins or unpins a topic and saves the corresponding event.
+// Permissions must be checked by the caller.
+func setTopicPinned(user, id string, pinned bool) error {
+	err := database.TopicSetPinned(id, pinned)
+	if err != nil {
+		return err
+	}
+
+	event := events.Event{
+		Type:  EventUnpinTopic,
+		User:  user,
+		Topic: id,
+		Date:  time.Now(),
+	}
+
+	if pinned {
+		event.Type = EventPinTopic
+	}
+
+	_, err = saveEvent(event)
+	if err != nil {
+		return err
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+	return nil
+}
+
+// renameTopic renames a topic and saves the corresponding events.
+// Permissions must be checked by the caller.
+func renameTopic(user string, topic database.Topic, name string) error {
+	err := database.RenameTopic(topic.ID, name)
+	if err != nil {
+		return err
+	}
+
 	e := events.Event{
 		Type:  EventTopicRenamed,
 		User:  user,
-		Topic: id,
+		Topic: topic.ID,
 		Date:  time.Now(),
-		Data:  eventCreateTopicRenameData(topic.Name, newtopic),
+		Data:  eventCreateTopicRenameData(topic.Name, name),
 	}
-	_, err = events.SaveEvent(e)
+	_, err = saveEvent(e)
 	if err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		rw.Write([]byte(err.Error()))
-		return
+		return err
 	}
 
+	// The copy for the admin log must not trigger the webhooks a second time
 	e.Topic = eventAdminPseudoTopic
 	_, err = events.SaveEvent(e)
 	if err != nil {
-		rw.WriteHeader(http.StatusInternalServerError)
-		rw.Write([]byte(err.Error()))
-		return
+		return err
 	}
 
 	err = database.ModifyLastSeen(user)
 	if err != nil {
 		log.Println("Can not modify last seen:", err)
 	}
-
-	http.Redirect(rw, r, fmt.Sprintf("%s/topic.html?id=%s", config.ServerPath, id), http.StatusFound)
+	return nil
 }
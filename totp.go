@@ -0,0 +1,369 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Top-Ranger/auth/data"
+	"github.com/Top-Ranger/discussiongo/database"
+)
+
+const (
+	// totpPeriod is the length of a TOTP time step in seconds (RFC 6238).
+	totpPeriod = 30
+
+	// totpDigits is the number of digits of a TOTP code.
+	totpDigits = 6
+
+	// totpSkew is the number of time steps before and after the current one which are accepted to allow for clock drift.
+	totpSkew = 1
+
+	// totpSecretLength is the length of a TOTP secret in bytes.
+	totpSecretLength = 20
+
+	// recoveryCodeCount is the number of recovery codes generated at once.
+	recoveryCodeCount = 10
+
+	// totpLoginDuration is the time a user has to enter the second factor after entering the password.
+	totpLoginDuration = 5 * time.Minute
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func init() {
+	http.HandleFunc("/enableTOTP.html", enableTOTPHandleFunc)
+	http.HandleFunc("/disableTOTP.html", disableTOTPHandleFunc)
+	http.HandleFunc("/newRecoveryCodes.html", newRecoveryCodesHandleFunc)
+}
+
+// newTOTPSecret returns a new random TOTP secret encoded as base32.
+func newTOTPSecret() (string, error) {
+	b := make([]byte, totpSecretLength)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// newRecoveryCodes returns new random recovery codes.
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	b := make([]byte, 7)
+	for i := range codes {
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		s := totpEncoding.EncodeToString(b)
+		codes[i] = fmt.Sprintf("%s-%s", s[0:5], s[5:10])
+	}
+	return codes, nil
+}
+
+// totpURI returns an URI which can be used to add a TOTP secret to an authenticator app.
+func totpURI(issuer, user, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	return fmt.Sprintf("otpauth://totp/%s:%s?%s", url.PathEscape(issuer), url.PathEscape(user), q.Encode())
+}
+
+// hotp calculates a HOTP value according to RFC 4226.
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// verifyTOTP checks a TOTP code according to RFC 6238 and returns the matching time step.
+// The caller must ensure that each time step is only accepted once.
+func verifyTOTP(secret, code string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	step := t.Unix() / totpPeriod
+	for i := step - totpSkew; i <= step+totpSkew; i++ {
+		if i < 0 {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(i))), []byte(code)) == 1 {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// verifySecondFactor checks a TOTP code or a recovery code of a user.
+// Used TOTP time steps and recovery codes are invalidated.
+func verifySecondFactor(user, code string) (bool, error) {
+	secret, err := database.GetTOTPSecret(user)
+	if err != nil {
+		return false, err
+	}
+	if secret == "" {
+		return false, nil
+	}
+
+	step, ok := verifyTOTP(secret, code, time.Now())
+	if ok {
+		return database.UseTOTPStep(user, step)
+	}
+	return database.UseRecoveryCode(user, code)
+}
+
+// writeRecoveryCodes shows new recovery codes to the user.
+func writeRecoveryCodes(rw http.ResponseWriter, codes []string) {
+	t := GetDefaultTranslation()
+	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.Write([]byte(fmt.Sprintf("%s:\n%s\n\n%s\n\n%s%s/user.html#totp\n", t.RecoveryCodes, strings.Join(codes, "\n"), t.RecoveryCodesMessage, config.ServerPrefix, config.ServerPath)))
+}
+
+func enableTOTPHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	q := r.Form
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	// The secret was generated when showing the user page
+	secret := q.Get("secret")
+	step, ok := verifyTOTP(secret, q.Get("code"), time.Now())
+	if !ok {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.AuthenticationCodeInvalid))
+		return
+	}
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	err = database.EnableTOTP(user, secret, codes)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	// The code used for enabling must not be used for login
+	_, err = database.UseTOTPStep(user, step)
+	if err != nil {
+		log.Println("Can not save TOTP step:", err)
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+
+	writeRecoveryCodes(rw, codes)
+}
+
+func disableTOTPHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	q := r.Form
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	if rateLimited(rw, r, user) {
+		return
+	}
+
+	ok, err := verifySecondFactor(user, q.Get("code"))
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	if !ok {
+		failedAttempt(r, user)
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.AuthenticationCodeInvalid))
+		return
+	}
+
+	err = database.DisableTOTP(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/user.html#totp", config.ServerPath), http.StatusFound)
+}
+
+func newRecoveryCodesHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/login.html", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	q := r.Form
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	if rateLimited(rw, r, user) {
+		return
+	}
+
+	ok, err := verifySecondFactor(user, q.Get("code"))
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	if !ok {
+		failedAttempt(r, user)
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.AuthenticationCodeInvalid))
+		return
+	}
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	err = database.SetRecoveryCodes(user, codes)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	err = database.ModifyLastSeen(user)
+	if err != nil {
+		log.Println("Can not modify last seen:", err)
+	}
+
+	writeRecoveryCodes(rw, codes)
+}
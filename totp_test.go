@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the secret used by the test vectors of RFC 4226 and RFC 6238 (SHA1).
+const rfcSecret = "12345678901234567890"
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226, Appendix D
+	tests := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for counter, want := range tests {
+		t.Run(fmt.Sprint(counter), func(t *testing.T) {
+			got := hotp([]byte(rfcSecret), uint64(counter))
+			if got != want {
+				t.Fatalf("got %s, expected %s", got, want)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte(rfcSecret))
+
+	// RFC 6238, Appendix B (SHA1). The vectors have 8 digits, a code with totpDigits digits consists of the last digits.
+	tests := []struct {
+		time int64
+		code string
+	}{
+		{time: 59, code: "94287082"},
+		{time: 1111111109, code: "07081804"},
+		{time: 1111111111, code: "14050471"},
+		{time: 1234567890, code: "89005924"},
+		{time: 2000000000, code: "69279037"},
+		{time: 20000000000, code: "65353130"},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprint(tc.time), func(t *testing.T) {
+			code := tc.code[len(tc.code)-totpDigits:]
+			step, ok := verifyTOTP(secret, code, time.Unix(tc.time, 0))
+			if !ok {
+				t.Fatalf("code %s rejected", code)
+			}
+			if step != tc.time/totpPeriod {
+				t.Fatalf("got step %d, expected %d", step, tc.time/totpPeriod)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPWindow(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte(rfcSecret))
+	const step = 1234567890 / totpPeriod
+	code := hotp([]byte(rfcSecret), step)
+
+	tests := []struct {
+		name   string
+		offset int64
+		valid  bool
+	}{
+		{name: "two steps before", offset: -2, valid: false},
+		{name: "one step before", offset: -1, valid: true},
+		{name: "same step", offset: 0, valid: true},
+		{name: "one step after", offset: 1, valid: true},
+		{name: "two steps after", offset: 2, valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Test both the start and the end of the step
+			for _, second := range []int64{0, totpPeriod - 1} {
+				got, ok := verifyTOTP(secret, code, time.Unix((step+tc.offset)*totpPeriod+second, 0))
+				if ok != tc.valid {
+					t.Fatalf("second %d: got %t, expected %t", second, ok, tc.valid)
+				}
+				// The step of the code is returned, not the current one, so that the code is only accepted once
+				if ok && got != step {
+					t.Fatalf("second %d: got step %d, expected %d", second, got, step)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPInvalid(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte(rfcSecret))
+	now := time.Unix(59, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		valid  bool
+	}{
+		{name: "valid", secret: secret, code: "287082", valid: true},
+		{name: "spaces", secret: secret, code: "287 082", valid: true},
+		{name: "lower case secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: "287082", valid: true},
+		{name: "wrong code", secret: secret, code: "287083", valid: false},
+		{name: "too short", secret: secret, code: "28708", valid: false},
+		{name: "too long", secret: secret, code: "94287082", valid: false},
+		{name: "empty", secret: secret, code: "", valid: false},
+		{name: "invalid secret", secret: "not base32!", code: "287082", valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok := verifyTOTP(tc.secret, tc.code, now)
+			if ok != tc.valid {
+				t.Fatalf("got %t, expected %t", ok, tc.valid)
+			}
+		})
+	}
+}
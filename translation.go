@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020,2021 Marcus Soll
+// Copyright 2020,2021,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -121,6 +121,11 @@ type Translation struct {
 	UploadFile                     string
 	Files                          string
 	FileTooLarge                   string
+	FileTypeNotAllowed             string
+	UserQuotaExceeded              string
+	TopicQuotaExceeded             string
+	FileRejectedByScanner          string
+	FileScanFailed                 string
 	Size                           string
 	RenameTopic                    string
 	Event                          string
@@ -142,6 +147,175 @@ type Translation struct {
 	EventUserRegisteredByAdmin     string
 	EventSetAdministrator          string
 	EventRemoveAdministrator       string
+	EditPost                       string
+	SaveChanges                    string
+	ShowHistory                    string
+	LastEdited                     string
+	Revision                       string
+	NoChanges                      string
+	EventPostEdited                string
+	Search                         string
+	NoResults                      string
+	SearchIndex                    string
+	RebuildSearchIndex             string
+	Inbox                          string
+	MentionedBy                    string
+	NoNotifications                string
+	MarkAsRead                     string
+	MarkAllNotificationsRead       string
+	Unread                         string
+	ShowPost                       string
+	TwoFactorAuthentication        string
+	TOTPIsEnabled                  string
+	TOTPSetupMessage               string
+	TOTPKey                        string
+	OpenInAuthenticatorApp         string
+	AuthenticationCode             string
+	AuthenticationCodeInvalid      string
+	EnableTOTP                     string
+	DisableTOTP                    string
+	RecoveryCodes                  string
+	RecoveryCodesMessage           string
+	UnusedRecoveryCodes            string
+	NewRecoveryCodes               string
+	TOTPLoginMessage               string
+	ResetTOTP                      string
+	EventTOTPReset                 string
+	Sessions                       string
+	CurrentSession                 string
+	IPAddress                      string
+	UserAgent                      string
+	RevokeSession                  string
+	LogoutEverywhereElse           string
+	SessionsMessage                string
+	TooManyAttempts                string
+	LockedAccounts                 string
+	NoLockedAccounts               string
+	FailedAttempts                 string
+	LockedUntil                    string
+	Unlock                         string
+	PersonalTokens                 string
+	PersonalTokensMessage          string
+	PersonalTokenName              string
+	Scopes                         string
+	ScopeRead                      string
+	ScopePost                      string
+	ScopeUpload                    string
+	ScopeAdmin                     string
+	ValidForDays                   string
+	ValidUntil                     string
+	Unlimited                      string
+	NeverUsed                      string
+	NoPersonalTokens               string
+	CreatePersonalToken            string
+	RevokePersonalToken            string
+	PersonalTokenCreated           string
+	PersonalTokenCreatedMessage    string
+	Webhooks                       string
+	AddWebhook                     string
+	DeleteWebhook                  string
+	NoWebhooks                     string
+	WebhookURL                     string
+	WebhookSecret                  string
+	WebhookEvents                  string
+	AllEvents                      string
+	WebhookEventsHint              string
+	Created                        string
+	WebhookDeliveries              string
+	NoWebhookDeliveries            string
+	DeliveryStatus                 string
+	DeliveryPending                string
+	DeliveryDelivered              string
+	DeliveryFailed                 string
+	DeliveryAttempts               string
+	LastAttempt                    string
+	NextAttempt                    string
+	StatusCode                     string
+	LastError                      string
+	RetryDelivery                  string
+	Feeds                          string
+	FeedsMessage                   string
+	FeedTokenMessage               string
+	NoFeedToken                    string
+	CreateFeedToken                string
+	RevokeFeedToken                string
+	FeedTokenCreated               string
+	FeedTokenCreatedMessage        string
+	LatestTopics                   string
+	LatestPosts                    string
+	NoCategory                     string
+	EventTopicMoved                string
+	Categories                     string
+	Category                       string
+	CategoryDescription            string
+	Position                       string
+	AdminOnlyCategory              string
+	AdminOnlyCategoryMessage       string
+	AddCategory                    string
+	EditCategory                   string
+	DeleteCategory                 string
+	NoCategories                   string
+	CategoryDeleteHint             string
+	MoveTopic                      string
+	TopicCount                     string
+	BackToOverview                 string
+	Tag                            string
+	Tags                           string
+	NoTags                         string
+	EventTopicTagsChanged          string
+	EventFilesAdminDeleted         string
+	EventUploadRejected            string
+	EditTags                       string
+	EditTagsHint                   string
+	TooManyTags                    string
+	RemoveTagFilter                string
+	ManageTags                     string
+	RenameTag                      string
+	MergeTags                      string
+	RenameTagHint                  string
+	Conversations                  string
+	Conversation                   string
+	NewConversation                string
+	NoConversations                string
+	ConversationTitle              string
+	Participants                   string
+	ParticipantsHint               string
+	Message                        string
+	SendMessage                    string
+	LeaveConversation              string
+	LeaveConversationHint          string
+	ConversationNotFound           string
+	ConversationTitleTooLong       string
+	NoParticipants                 string
+	TooManyParticipants            string
+	UnknownParticipant             string
+	ConversationPrivateHint        string
+	FirstPage                      string
+	PreviousPage                   string
+	NextPage                       string
+	LastPage                       string
+	JumpToFirstUnread              string
+	SortBy                         string
+	MostPosts                      string
+	OnlyNew                        string
+	OnlyMine                       string
+	OnlyParticipated               string
+	ShowTopics                     string
+	ShowOpenTopics                 string
+	ShowClosedTopics               string
+	AllTopics                      string
+	Page                           string
+	Storage                        string
+	StorageUsed                    string
+	StorageQuota                   string
+	StorageByUser                  string
+	StorageByTopic                 string
+	NoStoredFiles                  string
+	DeleteSelectedFiles            string
+	Download                       string
+	DownloadFiles                  string
+	DownloadFilesMarkdown          string
+	DownloadFilesHTML              string
 }
 
 const defaultLanguage = "de"
@@ -1,5 +1,5 @@
 // SPDX-License-Identifier: Apache-2.0
-// Copyright 2020 Marcus Soll
+// Copyright 2020,2026 Marcus Soll
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -18,6 +18,7 @@ package main
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/Top-Ranger/discussiongo/database"
 )
@@ -30,10 +31,31 @@ func init() {
 	http.HandleFunc("/updateTopicPost.json", updateTopicPostHandleFunc)
 }
 
+// updateTopicPostHandleFunc returns a value representing the last change.
+// If the parameter 'topic' is set, the value only changes if that topic was changed, otherwise it changes on any change of the topic list.
 func updateTopicPostHandleFunc(rw http.ResponseWriter, r *http.Request) {
 	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 
-	u := updateTopicPost{database.GetLastUpdateTopicPost()}
+	var lastUpdate int64
+	var err error
+	topic := r.URL.Query().Get("topic")
+	if topic != "" {
+		if _, err := strconv.ParseInt(topic, 10, 64); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			rw.Write([]byte(GetDefaultTranslation().InvalidRequest))
+			return
+		}
+		lastUpdate, err = database.GetLastUpdateTopic(topic)
+	} else {
+		lastUpdate, err = database.GetLastUpdateTopicList()
+	}
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	u := updateTopicPost{lastUpdate}
 	b, err := json.Marshal(&u)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
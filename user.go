@@ -29,6 +29,7 @@ import (
 	"github.com/Top-Ranger/discussiongo/database"
 	"github.com/Top-Ranger/discussiongo/events"
 	"github.com/Top-Ranger/discussiongo/files"
+	"github.com/Top-Ranger/discussiongo/notifications"
 )
 
 type templateUserData struct {
@@ -45,9 +46,30 @@ type templateUserData struct {
 	ServerPrefix            string
 	CreateInvitationMessage string
 	Token                   string
+	TOTPEnabled             bool
+	TOTPSecret              string
+	TOTPURI                 template.URL
+	RecoveryCodes           int
+	Sessions                []sessionData
+	PersonalTokens          []personalTokenData
+	FeedsPublic             bool
+	HasFeedToken            bool
+	FeedTokenCreated        string
+	FeedTokenLastUsed       string
+	StorageUsed             string
+	StorageQuota            string
 	Translation             Translation
 }
 
+type sessionData struct {
+	Session   string
+	Created   string
+	LastUsed  string
+	IP        string
+	UserAgent string
+	Current   bool
+}
+
 var (
 	userTemplate        *template.Template
 	protectedUserRegexp = regexp.MustCompile("S\\s*Y\\s*S\\s*T\\s*E\\s*M")
@@ -113,9 +135,95 @@ func userHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		ServerPrefix:            config.ServerPrefix,
 		CreateInvitationMessage: config.CreateInvitationMessage,
 		Token:                   token,
+		TOTPEnabled:             u.TOTPEnabled,
 		Translation:             GetDefaultTranslation(),
 	}
 
+	if u.TOTPEnabled {
+		td.RecoveryCodes, err = database.CountRecoveryCodes(user)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+	} else {
+		// The secret is only saved after the user entered a valid code
+		td.TOTPSecret, err = newTOTPSecret()
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		issuer := config.ForumName
+		if issuer == "" {
+			issuer = "DiscussionGo!"
+		}
+		td.TOTPURI = template.URL(totpURI(issuer, user, td.TOTPSecret))
+	}
+
+	sessions, err := authtoken.GetAuthtokenOfUser(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	current := authtoken.SessionOfToken(currentAuthtoken(r))
+	now := time.Now()
+	for i := range sessions {
+		if sessions[i].ValidUntil.Before(now) {
+			continue
+		}
+		td.Sessions = append(td.Sessions, sessionData{
+			Session:   sessions[i].Session(),
+			Created:   sessions[i].Created.Format(time.RFC822),
+			LastUsed:  sessions[i].LastUsed.Format(time.RFC822),
+			IP:        sessions[i].IP,
+			UserAgent: sessions[i].UserAgent,
+			Current:   sessions[i].Session() == current,
+		})
+	}
+
+	personalTokens, err := authtoken.GetPersonalTokenOfUser(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	for i := range personalTokens {
+		if !personalTokens[i].ValidUntil.IsZero() && personalTokens[i].ValidUntil.Before(now) {
+			continue
+		}
+		td.PersonalTokens = append(td.PersonalTokens, newPersonalTokenData(personalTokens[i], td.Translation))
+	}
+
+	td.FeedsPublic = config.CanReadWithoutRegister
+	feedToken, ok, err := authtoken.GetFeedTokenOfUser(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	if ok {
+		td.HasFeedToken = true
+		td.FeedTokenCreated = feedToken.Created.Format(time.RFC822)
+		td.FeedTokenLastUsed = td.Translation.NeverUsed
+		if !feedToken.LastUsed.IsZero() {
+			td.FeedTokenLastUsed = feedToken.LastUsed.Format(time.RFC822)
+		}
+	}
+
+	storage, err := files.GetUserStorage(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	td.StorageUsed = fileLengthToString(int(storage))
+	td.StorageQuota = td.Translation.Unlimited
+	if config.FileQuotaUserMB > 0 {
+		td.StorageQuota = fileLengthToString(config.FileQuotaUserMB * 1000000)
+	}
+
 	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 
 	err = userTemplate.Execute(rw, td)
@@ -345,18 +453,13 @@ func userChangePasswordHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = database.EditPassword(user, new)
+	err = editPassword(user, new)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
-	_, err = authtoken.DeleteUserToken(user)
-	if err != nil {
-		log.Printf("Can not delete auth tokens for '%s' after password change: %s", user, err.Error())
-	}
-
 	http.Redirect(rw, r, fmt.Sprintf("%s/user.html", config.ServerPath), http.StatusFound)
 }
 
@@ -406,7 +509,14 @@ func userDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userfiles, err := files.GetFilesForUser(user)
+	userfiles, err := files.GetFileMetadataForUser(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	conversations, err := database.GetConversationsOfUser(user)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
@@ -427,6 +537,9 @@ func userDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	for i := range userfiles {
+		if _, ok := conversationOfFile(userfiles[i].Topic); ok {
+			continue
+		}
 		e = append(e, events.Event{
 			Type:  EventFileDeleted,
 			User:  user,
@@ -435,6 +548,7 @@ func userDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	// Webhooks are only triggered by the deletion event of the user
 	err = events.SaveEvents(e)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -468,6 +582,15 @@ func userDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Request) {
 
 	count += c
 
+	c, err = deleteAbandonedConversationFiles(conversations)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	count += c
+
 	c, err = events.AnonymiseUserEvents(user)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -477,6 +600,15 @@ func userDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Request) {
 
 	count += c
 
+	c, err = notifications.DeleteUser(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	count += c
+
 	for i := range topics {
 		c, err = files.DeleteTopicFiles(topics[i].ID)
 		if err != nil {
@@ -493,6 +625,14 @@ func userDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Request) {
 			return
 		}
 		count += c
+
+		c, err = notifications.DeleteTopicNotifications(topics[i].ID)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		count += c
 	}
 
 	deletionEvent := events.Event{
@@ -511,7 +651,25 @@ func userDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Request) {
 
 	count += c
 
-	_, err = events.SaveEvent(deletionEvent)
+	c, err = authtoken.DeleteUserPersonalToken(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	count += c
+
+	c, err = authtoken.DeleteUserFeedToken(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	count += c
+
+	_, err = saveEvent(deletionEvent)
 	if err != nil {
 		log.Printf("Can not save event %+v: %s", deletionEvent, err.Error())
 	}
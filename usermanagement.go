@@ -31,6 +31,8 @@ import (
 	"github.com/Top-Ranger/discussiongo/database"
 	"github.com/Top-Ranger/discussiongo/events"
 	"github.com/Top-Ranger/discussiongo/files"
+	"github.com/Top-Ranger/discussiongo/notifications"
+	"github.com/Top-Ranger/discussiongo/webhooks"
 )
 
 var (
@@ -38,15 +40,43 @@ var (
 )
 
 type usermanagementTemplateData struct {
-	ServerPath  string
-	ForumName   string
-	Username    string
-	User        []userManagementStruct
-	Events      []eventData
-	Token       string
-	Translation Translation
+	ServerPath    string
+	ForumName     string
+	Username      string
+	User          []userManagementStruct
+	Events        []eventData
+	Locked        []lockedStruct
+	Categories    []categoryData
+	Webhooks      []webhookTemplateData
+	WebhookEvents []string
+	Deliveries    []deliveryTemplateData
+	StorageUsers  []storageTemplateData
+	StorageTopics []storageTemplateData
+	Token         string
+	Translation   Translation
 }
 
+type lockedStruct struct {
+	Type     string
+	Key      string
+	IsIP     bool
+	Failures int
+	Until    string
+}
+
+// storageTemplateData describes the storage used by a user or a topic.
+// Key is the name of the user or the ID of the topic.
+type storageTemplateData struct {
+	Key          string
+	Name         string
+	Conversation bool
+	Files        int64
+	Size         string
+}
+
+// storageReportSize is the number of users and topics shown in the storage report.
+const storageReportSize = 25
+
 type userManagementStruct struct {
 	Name               string
 	Admin              bool
@@ -54,6 +84,7 @@ type userManagementStruct struct {
 	InvitedBy          string
 	InvitationIndirect bool
 	LastSeen           string
+	TOTPEnabled        bool
 }
 
 func init() {
@@ -67,9 +98,13 @@ func init() {
 	http.HandleFunc("/usermanagement.html", usermanagementHandleFunc)
 	http.HandleFunc("/setAdmin.html", usermanagementSetAdminHandleFunc)
 	http.HandleFunc("/adminResetPasswort.html", usermanagementAdminResetPasswortHandleFunc)
+	http.HandleFunc("/adminResetTOTP.html", usermanagementAdminResetTOTPHandleFunc)
 	http.HandleFunc("/adminRegisterUser.html", usermanagementAdminRegisterUserHandleFunc)
 	http.HandleFunc("/adminDeleteUser.html", usermanagementAdminDeleteUserHandleFunc)
 	http.HandleFunc("/adminDeleteAllInvitations.html", usermanagementAdminDeleteAllInvitationsHandleFunc)
+	http.HandleFunc("/adminRebuildSearchIndex.html", usermanagementAdminRebuildSearchIndexHandleFunc)
+	http.HandleFunc("/adminUnlock.html", usermanagementAdminUnlockHandleFunc)
+	http.HandleFunc("/adminDeleteFiles.html", usermanagementAdminDeleteFilesHandleFunc)
 }
 
 func usermanagementHandleFunc(rw http.ResponseWriter, r *http.Request) {
@@ -110,6 +145,41 @@ func usermanagementHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	categories, err := database.GetCategories()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	hooks, err := webhooks.GetWebhooks()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	deliveries, err := webhooks.GetDeliveries(maxDeliveriesShown)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	storageUsers, err := files.GetStorageByUser(storageReportSize)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	storageTopics, err := files.GetStorageByTopic(storageReportSize)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
 	token, err := data.GetStringsTimed(time.Now(), fmt.Sprintf("%s;Token", user))
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -118,13 +188,23 @@ func usermanagementHandleFunc(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	td := usermanagementTemplateData{
-		ServerPath:  config.ServerPath,
-		ForumName:   config.ForumName,
-		Username:    user,
-		User:        make([]userManagementStruct, 0, len(userlist)),
-		Events:      make([]eventData, 0, len(eventlist)),
-		Token:       token,
-		Translation: GetDefaultTranslation(),
+		ServerPath:    config.ServerPath,
+		ForumName:     config.ForumName,
+		Username:      user,
+		User:          make([]userManagementStruct, 0, len(userlist)),
+		Events:        make([]eventData, 0, len(eventlist)),
+		Categories:    make([]categoryData, 0, len(categories)),
+		Webhooks:      make([]webhookTemplateData, 0, len(hooks)),
+		WebhookEvents: webhookEventNames(),
+		Deliveries:    make([]deliveryTemplateData, 0, len(deliveries)),
+		StorageUsers:  make([]storageTemplateData, 0, len(storageUsers)),
+		StorageTopics: make([]storageTemplateData, 0, len(storageTopics)),
+		Token:         token,
+		Translation:   GetDefaultTranslation(),
+	}
+
+	for i := range categories {
+		td.Categories = append(td.Categories, newCategoryData(categories[i], td.Translation))
 	}
 
 	for i := range userlist {
@@ -135,6 +215,7 @@ func usermanagementHandleFunc(rw http.ResponseWriter, r *http.Request) {
 			InvitedBy:          userlist[i].InvidedBy,
 			InvitationIndirect: !userlist[i].InvitationDirect,
 			LastSeen:           userlist[i].LastSeen.Format(time.RFC822),
+			TOTPEnabled:        userlist[i].TOTPEnabled,
 		})
 	}
 
@@ -142,6 +223,88 @@ func usermanagementHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		td.Events = append(td.Events, eventToEventData(eventlist[i]))
 	}
 
+	hookURL := make(map[string]string, len(hooks))
+	for i := range hooks {
+		hookURL[hooks[i].ID] = hooks[i].URL
+		td.Webhooks = append(td.Webhooks, webhookTemplateData{
+			ID:      hooks[i].ID,
+			URL:     hooks[i].URL,
+			Secret:  hooks[i].Secret,
+			Events:  strings.Join(hooks[i].Events, ", "),
+			Created: hooks[i].Created.Format(time.RFC822),
+		})
+	}
+
+	for i := range deliveries {
+		d := deliveryTemplateData{
+			ID:         deliveries[i].ID,
+			URL:        hookURL[deliveries[i].Webhook],
+			Event:      deliveries[i].Event,
+			Created:    deliveries[i].Created.Format(time.RFC822),
+			Failed:     deliveries[i].Status == webhooks.StatusFailed,
+			Attempts:   deliveries[i].Attempts,
+			StatusCode: deliveries[i].StatusCode,
+			LastError:  deliveries[i].LastError,
+		}
+		switch deliveries[i].Status {
+		case webhooks.StatusPending:
+			d.Status = td.Translation.DeliveryPending
+		case webhooks.StatusDelivered:
+			d.Status = td.Translation.DeliveryDelivered
+		case webhooks.StatusFailed:
+			d.Status = td.Translation.DeliveryFailed
+		}
+		if !deliveries[i].LastAttempt.IsZero() {
+			d.LastAttempt = deliveries[i].LastAttempt.Format(time.RFC822)
+		}
+		if deliveries[i].Status == webhooks.StatusPending {
+			d.NextAttempt = deliveries[i].NextAttempt.Format(time.RFC822)
+		}
+		td.Deliveries = append(td.Deliveries, d)
+	}
+
+	for i := range storageUsers {
+		td.StorageUsers = append(td.StorageUsers, storageTemplateData{
+			Key:   storageUsers[i].Key,
+			Name:  storageUsers[i].Key,
+			Files: storageUsers[i].Files,
+			Size:  fileLengthToString(int(storageUsers[i].Bytes)),
+		})
+	}
+
+	for i := range storageTopics {
+		d := storageTemplateData{
+			Key:   storageTopics[i].Key,
+			Files: storageTopics[i].Files,
+			Size:  fileLengthToString(int(storageTopics[i].Bytes)),
+		}
+		if _, ok := conversationOfFile(d.Key); ok {
+			// The titles of conversations are private
+			d.Name = td.Translation.Conversation
+			d.Conversation = true
+		} else {
+			topic, err := database.GetTopic(d.Key)
+			if err != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+				rw.Write([]byte(err.Error()))
+				return
+			}
+			d.Name = topic.Name
+		}
+		td.StorageTopics = append(td.StorageTopics, d)
+	}
+
+	now := time.Now()
+	for _, e := range accountLimiter.Locked(now) {
+		td.Locked = append(td.Locked, lockedStruct{Type: "account", Key: e.Key, Failures: e.Failures, Until: e.BlockedUntil.Format(time.RFC822)})
+	}
+	for _, e := range ipLimiter.Locked(now) {
+		td.Locked = append(td.Locked, lockedStruct{Type: "ip", Key: e.Key, IsIP: true, Failures: e.Failures, Until: e.BlockedUntil.Format(time.RFC822)})
+	}
+	for _, e := range registrationLimiter.Locked(now) {
+		td.Locked = append(td.Locked, lockedStruct{Type: "registration", Key: e.Key, IsIP: true, Failures: e.Failures, Until: e.BlockedUntil.Format(time.RFC822)})
+	}
+
 	rw.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 
 	err = usermanagementTemplate.ExecuteTemplate(rw, "usermanagement.html", td)
@@ -223,7 +386,77 @@ func usermanagementSetAdminHandleFunc(rw http.ResponseWriter, r *http.Request) {
 		e.Type = EventSetAdministrator
 	}
 
-	_, err = events.SaveEvent(e)
+	_, err = saveEvent(e)
+	if err != nil {
+		log.Printf("Can not save event %+v: %s", e, err.Error())
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/usermanagement.html#user%s", config.ServerPath, name), http.StatusFound)
+}
+
+func usermanagementAdminResetTOTPHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin := false
+	if loggedIn {
+		var err error
+		isAdmin, err = database.IsAdmin(user)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	if !isAdmin {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	q := r.URL.Query()
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	name := q.Get("name")
+	if name == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	err := database.DisableTOTP(name)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	e := events.Event{
+		Type:         EventTOTPResetByAdmin,
+		User:         name,
+		AffectedUser: user,
+		Topic:        eventAdminPseudoTopic,
+		Date:         time.Now(),
+	}
+
+	_, err = saveEvent(e)
 	if err != nil {
 		log.Printf("Can not save event %+v: %s", e, err.Error())
 	}
@@ -288,18 +521,13 @@ func usermanagementAdminResetPasswortHandleFunc(rw http.ResponseWriter, r *http.
 
 	newPW := base64.StdEncoding.EncodeToString(b)
 
-	err = database.EditPassword(name, newPW)
+	err = editPassword(name, newPW)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
 		return
 	}
 
-	_, err = authtoken.DeleteUserToken(name)
-	if err != nil {
-		log.Printf("Can not delete auth tokens for '%s' after forced password change: %s", user, err.Error())
-	}
-
 	rw.Write([]byte(fmt.Sprintf("%s: %s\n%s: %s\n%s%s/usermanagement.html#user%s", t.User, name, t.Password, newPW, config.ServerPrefix, config.ServerPath, name)))
 }
 
@@ -397,7 +625,7 @@ func usermanagementAdminRegisterUserHandleFunc(rw http.ResponseWriter, r *http.R
 		Date:         time.Now(),
 	}
 
-	_, err = events.SaveEvent(e)
+	_, err = saveEvent(e)
 	if err != nil {
 		log.Printf("Can not save event %+v: %s", e, err.Error())
 	}
@@ -467,7 +695,14 @@ func usermanagementAdminDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Req
 		return
 	}
 
-	userfiles, err := files.GetFilesForUser(name)
+	userfiles, err := files.GetFileMetadataForUser(name)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	conversations, err := database.GetConversationsOfUser(name)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		rw.Write([]byte(err.Error()))
@@ -488,6 +723,9 @@ func usermanagementAdminDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Req
 	}
 
 	for i := range userfiles {
+		if _, ok := conversationOfFile(userfiles[i].Topic); ok {
+			continue
+		}
 		e = append(e, events.Event{
 			Type:  EventFileDeleted,
 			User:  name,
@@ -496,6 +734,7 @@ func usermanagementAdminDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Req
 		})
 	}
 
+	// Webhooks are only triggered by the deletion event of the user
 	err = events.SaveEvents(e)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -529,6 +768,15 @@ func usermanagementAdminDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Req
 
 	count += c
 
+	c, err = deleteAbandonedConversationFiles(conversations)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	count += c
+
 	c, err = events.AnonymiseUserEvents(name)
 	if err != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -538,6 +786,33 @@ func usermanagementAdminDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Req
 
 	count += c
 
+	c, err = notifications.DeleteUser(name)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	count += c
+
+	c, err = authtoken.DeleteUserPersonalToken(name)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	count += c
+
+	c, err = authtoken.DeleteUserFeedToken(name)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	count += c
+
 	for i := range topics {
 		c, err = files.DeleteTopicFiles(topics[i].ID)
 		if err != nil {
@@ -554,6 +829,14 @@ func usermanagementAdminDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Req
 			return
 		}
 		count += c
+
+		c, err = notifications.DeleteTopicNotifications(topics[i].ID)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		count += c
 	}
 
 	deletionEvent := events.Event{
@@ -564,7 +847,7 @@ func usermanagementAdminDeleteUserHandleFunc(rw http.ResponseWriter, r *http.Req
 		Date:         time.Now(),
 	}
 
-	_, err = events.SaveEvent(deletionEvent)
+	_, err = saveEvent(deletionEvent)
 	if err != nil {
 		log.Printf("Can not save event %+v: %s", deletionEvent, err.Error())
 	}
@@ -619,3 +902,289 @@ func usermanagementAdminDeleteAllInvitationsHandleFunc(rw http.ResponseWriter, r
 	}
 	http.Redirect(rw, r, fmt.Sprintf("%s/usermanagement.html#inv", config.ServerPath), http.StatusFound)
 }
+
+func usermanagementAdminRebuildSearchIndexHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	q := r.URL.Query()
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	err = database.RebuildSearchIndex()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	err = files.RebuildSearchIndex()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Println("Search index rebuilt by", user)
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/usermanagement.html#search", config.ServerPath), http.StatusFound)
+}
+
+func usermanagementAdminUnlockHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin := false
+	if loggedIn {
+		var err error
+		isAdmin, err = database.IsAdmin(user)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	if !isAdmin {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	q := r.URL.Query()
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	key := q.Get("key")
+	if key == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	switch q.Get("type") {
+	case "account":
+		accountLimiter.Reset(key)
+	case "ip":
+		ipLimiter.Reset(key)
+	case "registration":
+		registrationLimiter.Reset(key)
+	default:
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	log.Printf("%s unlocked %s '%s'", user, q.Get("type"), key)
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/usermanagement.html#locked", config.ServerPath), http.StatusFound)
+}
+
+func usermanagementAdminDeleteFilesHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	token := r.Form.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	var count int64
+
+	for _, name := range r.Form["user"] {
+		if name == "" {
+			continue
+		}
+
+		userfiles, err := files.GetFileMetadataForUser(name)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+
+		c, err := deleteFilesAsAdmin(user, userfiles, func() (int64, error) { return files.DeleteUserFiles(name) })
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		count += c
+
+		e := events.Event{
+			Type:         EventFilesAdminDeleted,
+			User:         user,
+			AffectedUser: name,
+			Topic:        eventAdminPseudoTopic,
+			Date:         time.Now(),
+			Data:         []byte(name),
+		}
+		_, err = saveEvent(e)
+		if err != nil {
+			log.Printf("Can not save event %+v: %s", e, err.Error())
+		}
+	}
+
+	for _, topic := range r.Form["topic"] {
+		if topic == "" {
+			continue
+		}
+
+		topicfiles, err := files.GetFileMetadataOfTopic(topic)
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+
+		c, err := deleteFilesAsAdmin(user, topicfiles, func() (int64, error) { return files.DeleteTopicFiles(topic) })
+		if err != nil {
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte(err.Error()))
+			return
+		}
+		count += c
+
+		name := t.Conversation
+		if _, ok := conversationOfFile(topic); !ok {
+			topicData, err := database.GetTopic(topic)
+			if err != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+				rw.Write([]byte(err.Error()))
+				return
+			}
+			name = topicData.Name
+		}
+
+		e := events.Event{
+			Type:  EventFilesAdminDeleted,
+			User:  user,
+			Topic: eventAdminPseudoTopic,
+			Date:  time.Now(),
+			Data:  []byte(name),
+		}
+		_, err = saveEvent(e)
+		if err != nil {
+			log.Printf("Can not save event %+v: %s", e, err.Error())
+		}
+	}
+
+	log.Printf("%s deleted %d files", user, count)
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/usermanagement.html#storage", config.ServerPath), http.StatusFound)
+}
+
+// deleteFilesAsAdmin adds the deletion events of admin for fs and deletes them using del.
+// Files of conversations are private, so no events are added for them.
+func deleteFilesAsAdmin(admin string, fs []files.File, del func() (int64, error)) (int64, error) {
+	fs = withoutConversationFiles(fs)
+	e := make([]events.Event, 0, len(fs))
+	for i := range fs {
+		e = append(e, events.Event{
+			Type:  EventFileDeleted,
+			User:  admin,
+			Topic: fs[i].Topic,
+			Date:  fs[i].Date,
+		})
+	}
+
+	// Webhooks are only triggered by the event of the administrator
+	err := events.SaveEvents(e)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := del()
+	if err != nil {
+		return 0, err
+	}
+
+	updated := make(map[string]bool)
+	for i := range fs {
+		if !updated[fs[i].Topic] {
+			database.SetLastUpdate(fs[i].Topic)
+			updated[fs[i].Topic] = true
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,391 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Top-Ranger/auth/data"
+	"github.com/Top-Ranger/discussiongo/database"
+	"github.com/Top-Ranger/discussiongo/events"
+	"github.com/Top-Ranger/discussiongo/webhooks"
+)
+
+// maxDeliveriesShown is the number of deliveries shown in the delivery log.
+const maxDeliveriesShown = 50
+
+// Webhook events which are not represented by an event of the events package.
+const (
+	webhookTopicCreated = "topic.created"
+	webhookPostCreated  = "post.created"
+	webhookFileUploaded = "file.uploaded"
+)
+
+// webhookEventTypes contains the name of all events sent to webhooks by their type.
+var webhookEventTypes = map[int]string{
+	EventCloseTopic:            "topic.closed",
+	EventOpenTopic:             "topic.opened",
+	EventPinTopic:              "topic.pinned",
+	EventUnpinTopic:            "topic.unpinned",
+	EventTopicRenamed:          "topic.renamed",
+	EventPostDeleted:           "post.deleted",
+	EventFileDeleted:           "file.deleted",
+	EventUserRegistered:        "user.registered",
+	EventUserInvited:           "user.invited",
+	EventUserDeleted:           "user.deleted",
+	EventUserAdminDeleted:      "admin.user_deleted",
+	EventTopicDeleted:          "admin.topic_deleted",
+	EventUserRegisteredByAdmin: "admin.user_registered",
+	EventSetAdministrator:      "admin.administrator_set",
+	EventRemoveAdministrator:   "admin.administrator_removed",
+	EventPostEdited:            "post.edited",
+	EventTOTPResetByAdmin:      "admin.totp_reset",
+	EventTopicMoved:            "topic.moved",
+	EventTopicTagsChanged:      "topic.tags_changed",
+	EventFilesAdminDeleted:     "admin.files_deleted",
+	EventUploadRejected:        "admin.upload_rejected",
+}
+
+type webhookTopicData struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Creator  string `json:"creator"`
+	Category string `json:"category"`
+	URL      string `json:"url"`
+}
+
+type webhookPostData struct {
+	ID      string `json:"id"`
+	Topic   string `json:"topic"`
+	Poster  string `json:"poster"`
+	Content string `json:"content"`
+	URL     string `json:"url"`
+}
+
+type webhookFileData struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	User  string `json:"user"`
+	Topic string `json:"topic"`
+	URL   string `json:"url"`
+}
+
+type webhookEventData struct {
+	ID           string   `json:"id"`
+	User         string   `json:"user"`
+	AffectedUser string   `json:"affectedUser,omitempty"`
+	Topic        string   `json:"topic,omitempty"`
+	URL          string   `json:"url,omitempty"`
+	OldName      string   `json:"oldName,omitempty"`
+	NewName      string   `json:"newName,omitempty"`
+	OldCategory  string   `json:"oldCategory,omitempty"`
+	NewCategory  string   `json:"newCategory,omitempty"`
+	OldTags      []string `json:"oldTags,omitempty"`
+	NewTags      []string `json:"newTags,omitempty"`
+	TopicName    string   `json:"topicName,omitempty"`
+	Post         string   `json:"post,omitempty"`
+}
+
+type webhookTemplateData struct {
+	ID      string
+	URL     string
+	Secret  string
+	Events  string
+	Created string
+}
+
+type deliveryTemplateData struct {
+	ID          string
+	URL         string
+	Event       string
+	Created     string
+	Status      string
+	Failed      bool
+	Attempts    int
+	LastAttempt string
+	NextAttempt string
+	StatusCode  int
+	LastError   string
+}
+
+func init() {
+	http.HandleFunc("/adminAddWebhook.html", adminAddWebhookHandleFunc)
+	http.HandleFunc("/adminDeleteWebhook.html", adminDeleteWebhookHandleFunc)
+	http.HandleFunc("/adminRetryDelivery.html", adminRetryDeliveryHandleFunc)
+}
+
+// webhookEventNames returns the names of all events which can be sent to webhooks, sorted by name.
+func webhookEventNames() []string {
+	names := []string{webhookTopicCreated, webhookPostCreated, webhookFileUploaded}
+	for _, n := range webhookEventTypes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// topicURL returns the absolute URL of a topic.
+func topicURL(id string) string {
+	return absoluteURL(fmt.Sprintf("/topic.html?id=%s", id))
+}
+
+// triggerWebhook queues an event for all matching webhooks.
+// Errors are only logged since webhooks must never prevent the action itself.
+func triggerWebhook(event string, data interface{}) {
+	err := webhooks.Trigger(event, data)
+	if err != nil {
+		log.Printf("Can not trigger webhook %s: %s", event, err.Error())
+	}
+}
+
+// saveEvent saves an event and triggers the corresponding webhooks.
+// It should be used instead of events.SaveEvent unless the event is a duplicate of an already saved event.
+func saveEvent(e events.Event) (string, error) {
+	id, err := events.SaveEvent(e)
+	if err != nil {
+		return id, err
+	}
+
+	name, ok := webhookEventTypes[e.Type]
+	if !ok {
+		return id, nil
+	}
+
+	d := webhookEventData{
+		ID:           id,
+		User:         e.User,
+		AffectedUser: e.AffectedUser,
+	}
+	if e.Topic != eventAdminPseudoTopic {
+		d.Topic = e.Topic
+		d.URL = topicURL(e.Topic)
+	}
+	switch e.Type {
+	case EventTopicRenamed:
+		split := strings.Split(string(e.Data), "﷐")
+		if len(split) == 2 {
+			d.OldName, d.NewName = split[0], split[1]
+		}
+	case EventTopicMoved:
+		split := strings.Split(string(e.Data), "﷐")
+		if len(split) == 2 {
+			d.OldCategory, d.NewCategory = split[0], split[1]
+		}
+	case EventTopicTagsChanged:
+		split := strings.Split(string(e.Data), "﷐")
+		if len(split) == 2 {
+			d.OldTags, d.NewTags = splitEventTags(split[0]), splitEventTags(split[1])
+		}
+	case EventTopicDeleted:
+		d.TopicName = string(e.Data)
+	case EventFilesAdminDeleted:
+		if e.AffectedUser == "" {
+			d.TopicName = string(e.Data)
+		}
+	case EventPostEdited:
+		d.Post = string(e.Data)
+	}
+
+	triggerWebhook(name, d)
+	return id, nil
+}
+
+func adminAddWebhookHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	q := r.Form
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	u := strings.TrimSpace(q.Get("url"))
+	if u == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	// An empty filter delivers all events
+	known := webhookEventNames()
+	filter := q["event"]
+	for i := range filter {
+		idx := sort.SearchStrings(known, filter[i])
+		if idx == len(known) || known[idx] != filter[i] {
+			rw.WriteHeader(http.StatusBadRequest)
+			rw.Write([]byte(t.InvalidRequest))
+			return
+		}
+	}
+
+	hook, err := webhooks.AddWebhook(u, filter)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("%s added webhook %s (%s)", user, hook.ID, hook.URL)
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/usermanagement.html#webhooks", config.ServerPath), http.StatusFound)
+}
+
+func adminDeleteWebhookHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	q := r.URL.Query()
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	id := q.Get("id")
+	if id == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	err = webhooks.DeleteWebhook(id)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	log.Printf("%s deleted webhook %s", user, id)
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/usermanagement.html#webhooks", config.ServerPath), http.StatusFound)
+}
+
+func adminRetryDeliveryHandleFunc(rw http.ResponseWriter, r *http.Request) {
+	t := GetDefaultTranslation()
+	loggedIn, user := TestUser(r, rw)
+
+	if !loggedIn {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	isAdmin, err := database.IsAdmin(user)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	if !isAdmin {
+		http.Redirect(rw, r, fmt.Sprintf("%s/", config.ServerPath), http.StatusFound)
+		return
+	}
+
+	q := r.URL.Query()
+	token := q.Get("token")
+	if token == "" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	valid := data.VerifyStringsTimed(token, fmt.Sprintf("%s;Token", user), time.Now(), authentificationDuration)
+	if !valid {
+		rw.WriteHeader(http.StatusUnauthorized)
+		rw.Write([]byte(t.TokenInvalid))
+		return
+	}
+
+	id := q.Get("id")
+	if id == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(t.InvalidRequest))
+		return
+	}
+
+	err = webhooks.RetryDelivery(id)
+	if err != nil {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	http.Redirect(rw, r, fmt.Sprintf("%s/usermanagement.html#deliveries", config.ServerPath), http.StatusFound)
+}
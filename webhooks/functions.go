@@ -0,0 +1,323 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status of a delivery.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// secretLength is the length of a newly generated secret in bytes.
+const secretLength = 32
+
+// Webhook represents a registered URL which receives events.
+// If Events is empty, all events are delivered.
+type Webhook struct {
+	ID      string
+	URL     string
+	Secret  string
+	Events  []string
+	Created time.Time
+}
+
+// Delivery represents a single delivery of an event to a webhook.
+type Delivery struct {
+	ID          string
+	Webhook     string
+	Event       string
+	Payload     []byte
+	Created     time.Time
+	Status      string
+	Attempts    int
+	NextAttempt time.Time
+	LastAttempt time.Time
+	StatusCode  int
+	LastError   string
+}
+
+// payload is the body sent to the webhooks.
+type payload struct {
+	Event string      `json:"event"`
+	Date  time.Time   `json:"date"`
+	Data  interface{} `json:"data"`
+}
+
+// Matches returns whether the event should be delivered to the webhook.
+func (w Webhook) Matches(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for i := range w.Events {
+		if w.Events[i] == event {
+			return true
+		}
+	}
+	return false
+}
+
+// AddWebhook registers a new webhook with a random secret and returns it.
+// Only http and https URLs are allowed.
+func AddWebhook(u string, events []string) (Webhook, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("invalid url: %w", err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return Webhook{}, fmt.Errorf("invalid url '%s': only absolute http and https URLs are allowed", u)
+	}
+	for i := range events {
+		if events[i] == "" || strings.Contains(events[i], ",") {
+			return Webhook{}, fmt.Errorf("invalid event '%s'", events[i])
+		}
+	}
+
+	b := make([]byte, secretLength)
+	_, err = rand.Read(b)
+	if err != nil {
+		return Webhook{}, err
+	}
+	secret := hex.EncodeToString(b)
+	now := time.Now()
+
+	r, err := db.Exec("INSERT INTO webhooks (url, secret, events, created) VALUES (?, ?, ?, ?)", u, secret, strings.Join(events, ","), now.Unix())
+	if err != nil {
+		return Webhook{}, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	id, err := r.LastInsertId()
+	if err != nil {
+		return Webhook{}, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	return Webhook{
+		ID:      strconv.FormatInt(id, 10),
+		URL:     u,
+		Secret:  secret,
+		Events:  events,
+		Created: now,
+	}, nil
+}
+
+// GetWebhooks returns all registered webhooks.
+func GetWebhooks() ([]Webhook, error) {
+	rows, err := db.Query("SELECT id, url, secret, events, created FROM webhooks ORDER BY id ASC")
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	w := make([]Webhook, 0)
+	for rows.Next() {
+		hook, err := scanWebhook(rows.Scan)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintln("Database error:", err))
+		}
+		w = append(w, hook)
+	}
+	return w, nil
+}
+
+// getWebhook returns a single webhook.
+func getWebhook(id string) (Webhook, error) {
+	hook, err := scanWebhook(db.QueryRow("SELECT id, url, secret, events, created FROM webhooks WHERE id=?", id).Scan)
+	if err != nil {
+		return Webhook{}, err
+	}
+	return hook, nil
+}
+
+// DeleteWebhook removes a webhook together with all of its deliveries.
+func DeleteWebhook(id string) error {
+	var successful bool
+
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Transaction error:", err))
+	}
+
+	defer func() {
+		if !successful {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec("DELETE FROM deliveries WHERE webhook=?", id)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	_, err = tx.Exec("DELETE FROM webhooks WHERE id=?", id)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Commit error:", err))
+	}
+
+	successful = true
+	return nil
+}
+
+// Trigger queues the event for all matching webhooks.
+// data is marshalled to JSON and sent as part of the payload.
+// The actual delivery is done asynchronously by the worker.
+func Trigger(event string, data interface{}) error {
+	hooks, err := GetWebhooks()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var b []byte
+
+	for i := range hooks {
+		if !hooks[i].Matches(event) {
+			continue
+		}
+		if b == nil {
+			b, err = json.Marshal(payload{Event: event, Date: now, Data: data})
+			if err != nil {
+				return fmt.Errorf("can not marshal payload: %w", err)
+			}
+		}
+		_, err = db.Exec("INSERT INTO deliveries (webhook, event, payload, created, status, attempts, nextAttempt) VALUES (?, ?, ?, ?, ?, ?, ?)", hooks[i].ID, event, b, now.Unix(), StatusPending, 0, now.Unix())
+		if err != nil {
+			return errors.New(fmt.Sprintln("Database error:", err))
+		}
+	}
+
+	if b != nil {
+		wakeWorker()
+	}
+	return nil
+}
+
+// GetDeliveries returns the newest deliveries of all webhooks, up to limit.
+func GetDeliveries(limit int) ([]Delivery, error) {
+	return queryDeliveries("SELECT id, webhook, event, payload, created, status, attempts, nextAttempt, lastAttempt, statusCode, lastError FROM deliveries ORDER BY id DESC LIMIT ?", limit)
+}
+
+// RetryDelivery queues a delivery again, regardless of its current status.
+// The number of attempts is reset.
+func RetryDelivery(id string) error {
+	r, err := db.Exec("UPDATE deliveries SET status=?, attempts=0, nextAttempt=? WHERE id=?", StatusPending, time.Now().Unix(), id)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database count error:", err))
+	}
+	if count == 0 {
+		return errors.New("No such delivery")
+	}
+
+	wakeWorker()
+	return nil
+}
+
+// pendingDeliveries returns pending deliveries which are due at the given time, oldest first.
+func pendingDeliveries(now time.Time, limit int) ([]Delivery, error) {
+	return queryDeliveries("SELECT id, webhook, event, payload, created, status, attempts, nextAttempt, lastAttempt, statusCode, lastError FROM deliveries WHERE status=? AND nextAttempt<=? ORDER BY nextAttempt ASC, id ASC LIMIT ?", StatusPending, now.Unix(), limit)
+}
+
+// saveAttempt saves the result of a delivery attempt.
+func saveAttempt(d Delivery) error {
+	_, err := db.Exec("UPDATE deliveries SET status=?, attempts=?, nextAttempt=?, lastAttempt=?, statusCode=?, lastError=? WHERE id=?", d.Status, d.Attempts, d.NextAttempt.Unix(), d.LastAttempt.Unix(), d.StatusCode, d.LastError, d.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintln("Database error:", err))
+	}
+	return nil
+}
+
+// deleteDeliveriesBefore removes all finished deliveries created before t.
+func deleteDeliveriesBefore(t time.Time) (int64, error) {
+	r, err := db.Exec("DELETE FROM deliveries WHERE status<>? AND created<?", StatusPending, t.Unix())
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database error:", err))
+	}
+
+	count, err := r.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintln("Database count error:", err))
+	}
+	return count, nil
+}
+
+func queryDeliveries(query string, args ...interface{}) ([]Delivery, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Database error:", err))
+	}
+	defer rows.Close()
+
+	deliveries := make([]Delivery, 0)
+	for rows.Next() {
+		var d Delivery
+		var id, webhook, created, nextAttempt, lastAttempt int64
+		err = rows.Scan(&id, &webhook, &d.Event, &d.Payload, &created, &d.Status, &d.Attempts, &nextAttempt, &lastAttempt, &d.StatusCode, &d.LastError)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintln("Database error:", err))
+		}
+		d.ID = strconv.FormatInt(id, 10)
+		d.Webhook = strconv.FormatInt(webhook, 10)
+		d.Created = time.Unix(created, 0)
+		d.NextAttempt = time.Unix(nextAttempt, 0)
+		if lastAttempt != 0 {
+			d.LastAttempt = time.Unix(lastAttempt, 0)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+func scanWebhook(scan func(dest ...interface{}) error) (Webhook, error) {
+	var w Webhook
+	var id, created int64
+	var events string
+	err := scan(&id, &w.URL, &w.Secret, &events, &created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return w, errors.New("No such webhook")
+		}
+		return w, err
+	}
+	w.ID = strconv.FormatInt(id, 10)
+	w.Created = time.Unix(created, 0)
+	w.Events = make([]string, 0)
+	if events != "" {
+		w.Events = strings.Split(events, ",")
+	}
+	return w, nil
+}
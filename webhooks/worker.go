@@ -0,0 +1,267 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxAttempts is the number of attempts before a delivery is marked as failed.
+	maxAttempts = 10
+
+	// baseRetryDelay is the delay after the first failed attempt. It doubles with every further attempt.
+	baseRetryDelay = 30 * time.Second
+
+	// maxRetryDelay is the maximal delay between two attempts.
+	maxRetryDelay = 6 * time.Hour
+
+	// requestTimeout is the maximal duration of a single attempt.
+	requestTimeout = 10 * time.Second
+
+	// pollInterval is the interval in which the worker looks for due deliveries.
+	pollInterval = 15 * time.Second
+
+	// batchSize is the maximal number of deliveries read from the database at once.
+	batchSize = 20
+
+	// keepDeliveries is the duration finished deliveries are kept for the delivery log.
+	keepDeliveries = 30 * 24 * time.Hour
+
+	// maxErrorLength is the maximal number of bytes of a saved error message.
+	maxErrorLength = 500
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderEvent     = "X-DiscussionGo-Event"
+	HeaderDelivery  = "X-DiscussionGo-Delivery"
+	HeaderSignature = "X-DiscussionGo-Signature"
+)
+
+var (
+	workerStarted = sync.Once{}
+	wake          = make(chan struct{}, 1)
+	client        = &http.Client{
+		Timeout: requestTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// Redirects are treated as failure
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+// StartWorker starts the worker delivering the queued events.
+// Deliveries which were pending when the server stopped are resumed.
+func StartWorker() {
+	workerStarted.Do(func() {
+		go worker()
+	})
+}
+
+// Sign returns the value of the signature header for a body sent at t and signed with secret.
+// The header has the form 't=<unix time>,v1=<hex>', where the HMAC-SHA256 covers '<unix time>.<body>'.
+// Receivers should compute the same value, compare it in constant time and reject deliveries with an old timestamp,
+// so that captured deliveries can not be replayed. Verify implements these checks.
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, signature(secret, timestamp, body))
+}
+
+// Verify checks the value of a signature header created by Sign for body.
+// It returns an error if the signature does not match or if its timestamp differs from now by more than tolerance.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			timestamp = v
+		case "v1":
+			signatures = append(signatures, v)
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("webhooks: signature has no valid timestamp")
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return errors.New("webhooks: signature timestamp outside of tolerance")
+	}
+
+	expected := signature(secret, timestamp, body)
+	for i := range signatures {
+		if hmac.Equal([]byte(signatures[i]), []byte(expected)) {
+			return nil
+		}
+	}
+	return errors.New("webhooks: signature does not match")
+}
+
+// signature returns the hex encoded HMAC-SHA256 of timestamp and body.
+func signature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay returns the delay before the next attempt after the given number of failed attempts.
+func retryDelay(attempts int) time.Duration {
+	d := baseRetryDelay
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return d
+}
+
+func wakeWorker() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+func worker() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	var lastCleanup time.Time
+
+	for {
+		deliverPending()
+
+		if time.Since(lastCleanup) > time.Hour {
+			c, err := deleteDeliveriesBefore(time.Now().Add(-keepDeliveries))
+			if err != nil {
+				log.Println("webhooks: can not delete old deliveries:", err)
+			}
+			if c != 0 {
+				log.Printf("webhooks: deleted %d old deliveries", c)
+			}
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverPending sends all deliveries which are currently due.
+func deliverPending() {
+	for {
+		deliveries, err := pendingDeliveries(time.Now(), batchSize)
+		if err != nil {
+			log.Println("webhooks: can not read pending deliveries:", err)
+			return
+		}
+
+		for i := range deliveries {
+			d := attempt(deliveries[i])
+			err = saveAttempt(d)
+			if err != nil {
+				log.Printf("webhooks: can not save delivery %s: %s", d.ID, err.Error())
+				return
+			}
+		}
+
+		if len(deliveries) < batchSize {
+			return
+		}
+	}
+}
+
+// attempt sends a delivery once and returns it with the updated state.
+func attempt(d Delivery) Delivery {
+	now := time.Now()
+	d.Attempts++
+	d.LastAttempt = now
+	d.StatusCode = 0
+	d.LastError = ""
+
+	hook, err := getWebhook(d.Webhook)
+	if err != nil {
+		d.Status = StatusFailed
+		d.LastError = err.Error()
+		return d
+	}
+
+	d.StatusCode, err = send(hook, d)
+	if err == nil {
+		d.Status = StatusDelivered
+		return d
+	}
+
+	d.LastError = err.Error()
+	if len(d.LastError) > maxErrorLength {
+		d.LastError = d.LastError[:maxErrorLength]
+	}
+
+	if d.Attempts >= maxAttempts {
+		d.Status = StatusFailed
+		return d
+	}
+	d.NextAttempt = now.Add(retryDelay(d.Attempts))
+	return d
+}
+
+// send posts the payload of a delivery to the webhook.
+// It returns the status code of the response, which is 0 if no response was received.
+// Status codes other than 2xx are returned as an error.
+func send(hook Webhook, d Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DiscussionGo-Webhook")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, d.ID)
+	// Every attempt is signed with its own timestamp so that retries are not rejected as stale
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, time.Now(), d.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Read some of the body so that the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
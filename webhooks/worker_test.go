@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2026 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"topic.created"}`)
+	date := time.Unix(1767268800, 0)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(`1767268800.{"event":"topic.created"}`))
+	want := "t=1767268800,v1=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := Sign("secret", date, body); got != want {
+		t.Fatalf("got %s, expected %s", got, want)
+	}
+	if Sign("secret", date.Add(time.Second), body) == want {
+		t.Fatal("timestamp not signed")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"event":"topic.created"}`)
+	date := time.Unix(1767268800, 0)
+	header := Sign("secret", date, body)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   string
+		now    time.Time
+		valid  bool
+	}{
+		{name: "valid", secret: "secret", header: header, body: string(body), now: date, valid: true},
+		{name: "within tolerance", secret: "secret", header: header, body: string(body), now: date.Add(4 * time.Minute), valid: true},
+		{name: "clock skew", secret: "secret", header: header, body: string(body), now: date.Add(-4 * time.Minute), valid: true},
+		{name: "additional signatures", secret: "secret", header: "t=1767268800,v1=00," + header[len("t=1767268800,"):] + ",v0=ab", body: string(body), now: date, valid: true},
+		{name: "replayed", secret: "secret", header: header, body: string(body), now: date.Add(6 * time.Minute), valid: false},
+		{name: "future", secret: "secret", header: header, body: string(body), now: date.Add(-6 * time.Minute), valid: false},
+		{name: "changed body", secret: "secret", header: header, body: `{"event":"topic.deleted"}`, now: date, valid: false},
+		{name: "wrong secret", secret: "other", header: header, body: string(body), now: date, valid: false},
+		{name: "changed timestamp", secret: "secret", header: "t=1767268801" + header[len("t=1767268800"):], body: string(body), now: date, valid: false},
+		{name: "no timestamp", secret: "secret", header: header[len("t=1767268800,"):], body: string(body), now: date, valid: false},
+		{name: "no signature", secret: "secret", header: "t=1767268800", body: string(body), now: date, valid: false},
+		{name: "old format", secret: "secret", header: "sha256=" + header[len("t=1767268800,v1="):], body: string(body), now: date, valid: false},
+		{name: "empty", secret: "secret", header: "", body: string(body), now: date, valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Verify(tc.secret, tc.header, []byte(tc.body), tc.now, 5*time.Minute)
+			if (err == nil) != tc.valid {
+				t.Fatalf("got error %v", err)
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	payload := []byte(`{"event":"post.created"}`)
+	var header http.Header
+	var body []byte
+	status := http.StatusNoContent
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		rw.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	hook := Webhook{ID: "1", URL: server.URL, Secret: "secret"}
+	d := Delivery{ID: "42", Webhook: "1", Event: "post.created", Payload: payload}
+
+	code, err := send(hook, d)
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("got %d, %v", code, err)
+	}
+	if header.Get(HeaderEvent) != "post.created" || header.Get(HeaderDelivery) != "42" || header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers %v", header)
+	}
+	err = Verify("secret", header.Get(HeaderSignature), body, time.Now(), time.Minute)
+	if err != nil {
+		t.Errorf("invalid signature %s: %s", header.Get(HeaderSignature), err)
+	}
+
+	status = http.StatusInternalServerError
+	code, err = send(hook, d)
+	if err == nil || code != http.StatusInternalServerError {
+		t.Fatalf("got %d, %v", code, err)
+	}
+}